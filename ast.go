@@ -0,0 +1,647 @@
+package main
+
+// Узлы абстрактного синтаксического дерева.
+// Каждый узел хранит токен, по которому определяется его позиция в исходном тексте.
+
+type Node interface {
+	Pos() Token
+}
+
+// Оператор (statement)
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Выражение
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Программа:
+//
+//	program [ uses <модуль> { , <модуль> } ; ] [ const <объявления констант> ]
+//	[ type <объявления типов> ] var <объявления> { <подпрограмма> } begin <операторы> end.
+type Program struct {
+	Tok         Token
+	Uses        []Token // имена подключённых модулей
+	Units       []*Unit // все модули, от которых зависит программа, в порядке инициализации
+	Consts      []*ConstDecl
+	Types       []*TypeDecl
+	Decls       []*VarDecl
+	Subprograms []*Subprogram
+	Body        []Stmt
+	End         Token
+}
+
+// Модуль (файл <имя>.tfu):
+//
+//	unit <имя> ; [ uses <модуль> { , <модуль> } ; ]
+//	interface [ const ... ] [ type ... ] { <заголовок подпрограммы> ; }
+//	implementation [ var <объявления> ] { <подпрограмма> } end.
+//
+// Константы, типы и подпрограммы интерфейса видны в программах и модулях,
+// подключающих модуль; переменные и подпрограммы раздела implementation — только в нём.
+type Unit struct {
+	Tok         Token // unit
+	Name        Token
+	Path        string  // абсолютный путь к файлу модуля
+	Uses        []Token // имена подключённых модулей
+	Deps        []*Unit // подключённые модули в порядке Uses
+	Consts      []*ConstDecl
+	Types       []*TypeDecl
+	Headings    []*Subprogram // подпрограммы интерфейса
+	Impl        Token         // implementation
+	Decls       []*VarDecl
+	Subprograms []*Subprogram
+	End         Token
+}
+
+// Модули, от которых зависят модули units (включая их самих), в порядке инициализации:
+// каждый модуль следует после своих зависимостей
+func unitOrder(units []*Unit) []*Unit {
+	var order []*Unit
+	seen := make(map[*Unit]bool)
+	var visit func(u *Unit)
+	visit = func(u *Unit) {
+		if seen[u] {
+			return
+		}
+		seen[u] = true
+		for _, dep := range u.Deps {
+			visit(dep)
+		}
+		order = append(order, u)
+	}
+	for _, u := range units {
+		visit(u)
+	}
+	return order
+}
+
+// Объявление переменных: <идентификатор> { , <идентификатор> } : <тип> ;
+type VarDecl struct {
+	Names []Token
+	Type  *TypeSpec
+}
+
+// Объявление константы: <идентификатор> = <константное выражение> ;
+// Выражение может содержать литералы, ранее объявленные константы и операции.
+type ConstDecl struct {
+	Name  Token
+	Value Expr
+}
+
+// Объявление типа: <идентификатор> = <тип> ;
+type TypeDecl struct {
+	Name Token
+	Type *TypeSpec
+}
+
+// Тип: int | float | bool | string | char | <имя типа> |
+// array [ <граница> .. <граница> ] of <тип> |
+// record <объявления полей> end
+type TypeSpec struct {
+	Tok    Token // имя типа, 'array' или 'record'
+	Low    Expr  // границы индекса массива (целые константы)
+	High   Expr
+	Elem   *TypeSpec  // тип элементов массива
+	Fields []*VarDecl // поля записи
+	End    Token      // 'end' записи
+	Def    *TypeSpec  // тип из объявления, если Tok — имя объявленного типа
+}
+
+// Тип с раскрытыми именами объявленных типов
+func (t *TypeSpec) Underlying() *TypeSpec {
+	for t.Def != nil {
+		t = t.Def
+	}
+	return t
+}
+
+func (t *TypeSpec) IsArray() bool {
+	return t.Underlying().Elem != nil
+}
+
+func (t *TypeSpec) IsRecord() bool {
+	return t.Underlying().Tok.Lexeme == "record"
+}
+
+// Номер и тип поля записи; -1, если такого поля нет
+func (t *TypeSpec) Field(name string) (int, *TypeSpec) {
+	i := 0
+	for _, decl := range t.Underlying().Fields {
+		for _, field := range decl.Names {
+			if field.Lexeme == name {
+				return i, decl.Type
+			}
+			i++
+		}
+	}
+	return -1, nil
+}
+
+// Имена полей записи по порядку
+func (t *TypeSpec) FieldNames() []string {
+	var names []string
+	for _, decl := range t.Underlying().Fields {
+		for _, field := range decl.Names {
+			names = append(names, field.Lexeme)
+		}
+	}
+	return names
+}
+
+// Границы индекса массива; ok ложно, если границы не являются целыми константами
+func arrayBounds(t *TypeSpec) (low, high int64, ok bool) {
+	t = t.Underlying()
+	low, okLow := constInt(t.Low)
+	high, okHigh := constInt(t.High)
+	return low, high, okLow && okHigh
+}
+
+// Значение целого константного выражения
+func constInt(e Expr) (int64, bool) {
+	val, err := evalConst(e)
+	if err != nil || val.Kind != KindInt || val.Big != nil {
+		return 0, false
+	}
+	return val.Int, true
+}
+
+// Значение константного выражения: литералы, константы и операции над ними.
+// Для выражения с переменными или вызовами возвращается ошибка.
+func evalConst(e Expr) (Value, error) {
+	switch e := e.(type) {
+	case *NumberLit:
+		return e.Tok.Value, nil
+	case *BoolLit:
+		return Value{Kind: KindBool, Bool: e.Value}, nil
+	case *StringLit:
+		return e.Tok.Value, nil
+	case *Ident:
+		if e.Const != nil {
+			return evalConst(e.Const.Value)
+		}
+	case *UnaryExpr:
+		x, err := evalConst(e.X)
+		if err != nil {
+			return Value{}, err
+		}
+		return unary(e.Op, x)
+	case *BinaryExpr:
+		left, err := evalConst(e.Left)
+		if err != nil {
+			return Value{}, err
+		}
+		right, err := evalConst(e.Right)
+		if err != nil {
+			return Value{}, err
+		}
+		return binary(e.Op, left, right)
+	}
+	return Value{}, &RuntimeError{Tok: e.Pos(), Msg: sprintf("'%s' не является константой", exprString(e))}
+}
+
+// Обход выражения и всех его подвыражений
+func walkExpr(e Expr, f func(Expr)) {
+	if e == nil {
+		return
+	}
+	f(e)
+	switch e := e.(type) {
+	case *BinaryExpr:
+		walkExpr(e.Left, f)
+		walkExpr(e.Right, f)
+	case *UnaryExpr:
+		walkExpr(e.X, f)
+	case *IndexExpr:
+		walkExpr(e.X, f)
+		walkExpr(e.Index, f)
+	case *FieldExpr:
+		walkExpr(e.X, f)
+	case *CallExpr:
+		for _, arg := range e.Args {
+			walkExpr(arg, f)
+		}
+	}
+}
+
+// Обход оператора и всех вложенных в него операторов
+func inspectStmt(s Stmt, f func(Stmt)) {
+	if s == nil {
+		return
+	}
+	f(s)
+	switch s := s.(type) {
+	case *IfStmt:
+		inspectStmt(s.Then, f)
+		inspectStmt(s.Else, f)
+	case *ForStmt:
+		inspectStmt(s.Init, f)
+		inspectStmt(s.Body, f)
+	case *WhileStmt:
+		inspectStmt(s.Body, f)
+	case *RepeatStmt:
+		for _, stmt := range s.Body {
+			inspectStmt(stmt, f)
+		}
+	case *CaseStmt:
+		for _, branch := range s.Branches {
+			inspectStmt(branch.Body, f)
+		}
+		inspectStmt(s.Else, f)
+	case *CompoundStmt:
+		for _, stmt := range s.Body {
+			inspectStmt(stmt, f)
+		}
+	}
+}
+
+// Обход всех выражений оператора, включая вложенные операторы
+// и границы массивов в объявлениях блоков
+func walkStmt(s Stmt, f func(Expr)) {
+	inspectStmt(s, func(s Stmt) {
+		switch s := s.(type) {
+		case *AssignStmt:
+			walkExpr(s.Target, f)
+			walkExpr(s.Value, f)
+		case *IfStmt:
+			walkExpr(s.Cond, f)
+		case *ForStmt:
+			walkExpr(s.To, f)
+			walkExpr(s.Step, f)
+		case *WhileStmt:
+			walkExpr(s.Cond, f)
+		case *RepeatStmt:
+			walkExpr(s.Cond, f)
+		case *CaseStmt:
+			walkExpr(s.Selector, f)
+			for _, branch := range s.Branches {
+				for _, label := range branch.Labels {
+					walkExpr(label.Low, f)
+					walkExpr(label.High, f)
+				}
+			}
+		case *ReadStmt:
+			for _, target := range s.Targets {
+				walkExpr(target, f)
+			}
+		case *WriteStmt:
+			for _, arg := range s.Args {
+				walkExpr(arg, f)
+			}
+		case *CompoundStmt:
+			for _, decl := range s.Decls {
+				walkType(decl.Type, f)
+			}
+		case *CallStmt:
+			walkExpr(s.Call, f)
+		case *ReturnStmt:
+			walkExpr(s.Value, f)
+		}
+	})
+}
+
+// Обход выражений типа (границ массивов, в том числе в полях записей)
+func walkType(t *TypeSpec, f func(Expr)) {
+	switch {
+	case t.Elem != nil:
+		walkExpr(t.Low, f)
+		walkExpr(t.High, f)
+		walkType(t.Elem, f)
+	case t.Fields != nil:
+		for _, field := range t.Fields {
+			walkType(field.Type, f)
+		}
+	}
+}
+
+// Процедура или функция:
+//
+//	procedure <имя> ( [ <параметры> ] ) ; [ var <объявления> ] begin <операторы> end ;
+//	function <имя> ( [ <параметры> ] ) : <тип> ; [ var <объявления> ] begin <операторы> end ;
+//
+// Параметры передаются по значению и записываются как объявления,
+// разделённые ';': a, b : int; c : float
+type Subprogram struct {
+	Tok    Token // procedure или function
+	Name   Token
+	Params []*VarDecl
+	Result *TypeSpec // тип результата функции; nil для процедуры
+	Decls  []*VarDecl
+	Body   []Stmt
+	End    Token
+	Unit   *Unit       // модуль, в котором объявлена подпрограмма; nil для подпрограммы программы
+	Impl   *Subprogram // реализация подпрограммы, объявленной в интерфейсе модуля
+}
+
+func (s *Subprogram) IsFunction() bool {
+	return s.Tok.Lexeme == "function"
+}
+
+// Имена параметров по порядку
+func (s *Subprogram) ParamNames() []Token {
+	var names []Token
+	for _, p := range s.Params {
+		names = append(names, p.Names...)
+	}
+	return names
+}
+
+// Тип параметра с номером i
+func (s *Subprogram) ParamType(i int) *TypeSpec {
+	for _, p := range s.Params {
+		if i < len(p.Names) {
+			return p.Type
+		}
+		i -= len(p.Names)
+	}
+	return nil
+}
+
+// Присваивание: <переменная> as <выражение>,
+// где переменная — идентификатор, элемент массива или поле записи
+type AssignStmt struct {
+	Target Expr
+	Value  Expr
+}
+
+// Условный оператор: if <выражение> then <оператор> [ else <оператор> ]
+type IfStmt struct {
+	Tok  Token
+	Cond Expr
+	Then Stmt
+	Else Stmt
+}
+
+// Цикл с параметром:
+//
+//	for <присваивание> (to | downto) <выражение> [ step <выражение> ] do <оператор>
+//
+// Граница и шаг вычисляются один раз перед первой итерацией
+type ForStmt struct {
+	Tok  Token
+	Init *AssignStmt
+	Dir  Token // 'to' или 'downto'
+	To   Expr
+	Step Expr // nil — шаг 1
+	Body Stmt
+}
+
+// Цикл со счётчиком, убывающим до границы
+func (s *ForStmt) Down() bool {
+	return s.Dir.Lexeme == "downto"
+}
+
+// Цикл с предусловием: while <выражение> do <оператор>
+type WhileStmt struct {
+	Tok  Token
+	Cond Expr
+	Body Stmt
+}
+
+// Оператор выбора:
+//
+//	case <выражение> of <ветвь> { ; <ветвь> } [ ; ] [ else <оператор> [ ; ] ] end
+type CaseStmt struct {
+	Tok      Token
+	Selector Expr
+	Branches []*CaseBranch
+	Else     Stmt // nil, если ветви else нет
+	End      Token
+}
+
+// Ветвь оператора выбора: <метка> { , <метка> } : <оператор>
+type CaseBranch struct {
+	Labels []*CaseLabel
+	Body   Stmt
+}
+
+// Метка ветви: целая или логическая константа либо диапазон <константа> .. <константа>
+type CaseLabel struct {
+	Low  Expr
+	High Expr // nil, если метка не диапазон
+}
+
+// Границы метки в виде порядковых номеров (false и true имеют номера 0 и 1)
+// и вид значений метки
+func (l *CaseLabel) Bounds() (low, high int64, kind ValueKind, err error) {
+	lowVal, err := evalConst(l.Low)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	highVal := lowVal
+	if l.High != nil {
+		highVal, err = evalConst(l.High)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	low, okLow := ordinal(lowVal)
+	high, okHigh := ordinal(highVal)
+	if !okLow || !okHigh || lowVal.Kind != highVal.Kind {
+		return 0, 0, 0, &RuntimeError{Tok: l.Low.Pos(), Msg: sprintf("некорректная метка case '%s'", caseLabelString(l))}
+	}
+	return low, high, lowVal.Kind, nil
+}
+
+// Запись метки: <константа> или <константа>..<константа>
+func caseLabelString(l *CaseLabel) string {
+	if l.High == nil {
+		return exprString(l.Low)
+	}
+	return exprString(l.Low) + ".." + exprString(l.High)
+}
+
+// Имя типа для вида значения метки case
+func ordinalTypeName(k ValueKind) string {
+	if k == KindBool {
+		return "bool"
+	}
+	return "int"
+}
+
+// Порядковый номер целого или логического значения; целое вне диапазона int64
+// порядкового номера не имеет
+func ordinal(v Value) (int64, bool) {
+	switch v.Kind {
+	case KindInt:
+		return v.Int, v.Big == nil
+	case KindBool:
+		if v.Bool {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (b *CaseBranch) Pos() Token { return b.Labels[0].Low.Pos() }
+
+// Ввод: read ( <переменная> { , <переменная> } )
+type ReadStmt struct {
+	Tok     Token
+	Targets []Expr
+}
+
+// Вывод: write ( <выражение> { , <выражение> } )
+type WriteStmt struct {
+	Tok  Token
+	Args []Expr
+}
+
+// Вызов процедуры или функции как оператор
+type CallStmt struct {
+	Call *CallExpr
+}
+
+// Цикл с постусловием: repeat <оператор> { ; <оператор> } until <выражение>
+type RepeatStmt struct {
+	Tok   Token
+	Body  []Stmt
+	Until Token
+	Cond  Expr
+}
+
+// Выход из ближайшего цикла: break
+type BreakStmt struct {
+	Tok Token
+}
+
+// Переход к следующей итерации ближайшего цикла: continue
+type ContinueStmt struct {
+	Tok Token
+}
+
+// Возврат из подпрограммы: return [ <выражение> ]
+type ReturnStmt struct {
+	Tok   Token
+	Value Expr // nil в процедуре
+}
+
+// Составной оператор (блок):
+//
+//	[ [ var <объявление> { <объявление> } ] <оператор> { (: | ;) <оператор> } ]
+//
+// Переменные блока видны только внутри него и создаются заново при каждом входе
+type CompoundStmt struct {
+	Tok   Token
+	Decls []*VarDecl // локальные переменные блока
+	Body  []Stmt
+	End   Token
+}
+
+// Вызов подпрограммы: <имя> ( [ <выражение> { , <выражение> } ] )
+type CallExpr struct {
+	Name Token
+	Args []Expr
+	End  Token       // закрывающая скобка
+	Sub  *Subprogram // вызываемая подпрограмма; nil для встроенной или неизвестной
+}
+
+// Элемент массива: <переменная> [ <выражение> ]
+type IndexExpr struct {
+	X     Expr // идентификатор массива или элемент внешнего массива
+	Index Expr
+	End   Token // ']'
+}
+
+// Поле записи: <переменная> . <идентификатор>
+type FieldExpr struct {
+	X    Expr // запись: идентификатор, элемент массива или поле внешней записи
+	Name Token
+}
+
+// Бинарное выражение
+type BinaryExpr struct {
+	Op    Token
+	Left  Expr
+	Right Expr
+}
+
+// Унарное выражение (~)
+type UnaryExpr struct {
+	Op Token
+	X  Expr
+}
+
+// Идентификатор
+type Ident struct {
+	Tok   Token
+	Const *ConstDecl // объявление, если идентификатор обозначает константу
+}
+
+// Числовая константа
+type NumberLit struct {
+	Tok Token
+}
+
+// Логическая константа
+type BoolLit struct {
+	Tok   Token
+	Value bool
+}
+
+// Строковая ("...") или символьная ('.') константа; лексема включает кавычки
+type StringLit struct {
+	Tok Token
+}
+
+func (e *StringLit) IsChar() bool {
+	return e.Tok.Type == TokenChar
+}
+
+func (s *Program) Pos() Token      { return s.Tok }
+func (s *VarDecl) Pos() Token      { return s.Names[0] }
+func (s *ConstDecl) Pos() Token    { return s.Name }
+func (s *TypeDecl) Pos() Token     { return s.Name }
+func (s *TypeSpec) Pos() Token     { return s.Tok }
+func (s *AssignStmt) Pos() Token   { return s.Target.Pos() }
+func (s *IfStmt) Pos() Token       { return s.Tok }
+func (s *ForStmt) Pos() Token      { return s.Tok }
+func (s *WhileStmt) Pos() Token    { return s.Tok }
+func (s *CaseStmt) Pos() Token     { return s.Tok }
+func (s *RepeatStmt) Pos() Token   { return s.Tok }
+func (s *BreakStmt) Pos() Token    { return s.Tok }
+func (s *ContinueStmt) Pos() Token { return s.Tok }
+func (s *ReadStmt) Pos() Token     { return s.Tok }
+func (s *WriteStmt) Pos() Token    { return s.Tok }
+func (s *CompoundStmt) Pos() Token { return s.Tok }
+func (s *CallStmt) Pos() Token     { return s.Call.Name }
+func (s *ReturnStmt) Pos() Token   { return s.Tok }
+func (s *Subprogram) Pos() Token   { return s.Tok }
+func (e *CallExpr) Pos() Token     { return e.Name }
+func (e *IndexExpr) Pos() Token    { return e.X.Pos() }
+func (e *FieldExpr) Pos() Token    { return e.X.Pos() }
+func (e *BinaryExpr) Pos() Token   { return e.Left.Pos() }
+func (e *UnaryExpr) Pos() Token    { return e.Op }
+func (e *Ident) Pos() Token        { return e.Tok }
+func (e *NumberLit) Pos() Token    { return e.Tok }
+func (e *BoolLit) Pos() Token      { return e.Tok }
+func (e *StringLit) Pos() Token    { return e.Tok }
+
+func (*AssignStmt) stmtNode()   {}
+func (*IfStmt) stmtNode()       {}
+func (*ForStmt) stmtNode()      {}
+func (*WhileStmt) stmtNode()    {}
+func (*CaseStmt) stmtNode()     {}
+func (*RepeatStmt) stmtNode()   {}
+func (*BreakStmt) stmtNode()    {}
+func (*ContinueStmt) stmtNode() {}
+func (*ReadStmt) stmtNode()     {}
+func (*WriteStmt) stmtNode()    {}
+func (*CompoundStmt) stmtNode() {}
+func (*CallStmt) stmtNode()     {}
+func (*ReturnStmt) stmtNode()   {}
+
+func (*BinaryExpr) exprNode() {}
+func (*UnaryExpr) exprNode()  {}
+func (*Ident) exprNode()      {}
+func (*NumberLit) exprNode()  {}
+func (*BoolLit) exprNode()    {}
+func (*StringLit) exprNode()  {}
+func (*CallExpr) exprNode()   {}
+func (*IndexExpr) exprNode()  {}
+func (*FieldExpr) exprNode()  {}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// Команда bench: сравнение табличного лексического анализатора (scan)
+// и анализатора, сгенерированного по спецификации tfi.lex, с посимвольным
+// эталоном (scanReference) на большом тексте. Текст собирается
+// повторением указанных файлов (или встроенного примера) до заданного размера;
+// перед замером проверяется, что все анализаторы дают одинаковые токены.
+
+// Пример программы для замера, если файлы не указаны: все виды токенов
+const benchSample = `program
+const limit = 100d; mask = 0FFh; bits = 1010b; perm = 755o;
+type Point = record x, y : float end;
+var
+    i, j, total : int;
+    ratio : float;
+    name : string; ch : char;
+    grid : array [1d..10d] of Point;
+    done : bool;
+{ вычисление суммы и среднего }
+function average(a, b : float) : float;
+begin
+    return (a plus b) div 2.0
+end;
+begin
+    total as 0d; name as "матрица\t\"grid\""; ch as 'x';
+    for i as 1d to limit step 3d do
+    [
+        var k : int;
+        k as i mult 2d min 1d;
+        if (k GE 10d) and ~(k EQ 15d) or (i LT 3d) then
+            total as total plus k
+        else
+            continue
+    ];
+    ratio as average(1.5e3, 2.25) mult 3.14;
+    repeat j as j plus 1d until j GT 5d;
+    case total of
+        0d..9d: write("small");
+        10d, 20d: write("round")
+    else
+        write(total, ratio, name, ch)
+    end;
+    grid[1d].x as ratio; done as total NE 0d
+end.
+`
+
+// Текст для замера: файлы корпуса (или пример), повторённые до size байт
+func benchText(corpus [][]byte, size int) []byte {
+	if len(corpus) == 0 {
+		corpus = [][]byte{[]byte(benchSample)}
+	}
+	var buf bytes.Buffer
+	for buf.Len() < size {
+		for _, src := range corpus {
+			buf.Write(src)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	size := fs.Int("size", 4, tr("размер текста для замера в мегабайтах"))
+	fs.StringVar(&lang, "lang", lang, tr("язык сообщений: ru, en"))
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if lang != "ru" && lang != "en" {
+		fmt.Fprintf(os.Stderr, tr("Неизвестный язык '%s'\n"), lang)
+		return exitUsage
+	}
+	if *size <= 0 {
+		fmt.Fprintf(os.Stderr, tr("Размер текста должен быть положительным, получено %d\n"), *size)
+		return exitUsage
+	}
+
+	var corpus [][]byte
+	for _, name := range fs.Args() {
+		src, err := readSource(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return exitError
+		}
+		corpus = append(corpus, src)
+	}
+	src := benchText(corpus, *size<<20)
+
+	tokens, err := scan(bytes.NewReader(src), nil)
+	want, errRef := scanReference(bytes.NewReader(src), nil)
+	if err != nil || errRef != nil {
+		// Ошибка в тексте: замер имеет смысл, только если оба анализатора её находят
+		if asDiagnostic(err) == nil || asDiagnostic(errRef) == nil || err.Error() != errRef.Error() {
+			fmt.Fprintf(os.Stderr, tr("Результаты анализаторов различаются: %v; эталон: %v\n"), err, errRef)
+			return exitError
+		}
+		renderError(os.Stderr, "bench", src, err)
+		return exitLexical
+	}
+	generated, _ := generatedDFA.scan(bytes.NewReader(src), nil)
+	if !reflect.DeepEqual(tokens, want) || !reflect.DeepEqual(generated, want) {
+		fmt.Fprintln(os.Stderr, tr("Результаты анализаторов различаются"))
+		return exitError
+	}
+	fmt.Printf(tr("Текст: %.1f МБ, токенов: %d; результаты анализаторов совпадают\n"), float64(len(src))/(1<<20), len(tokens))
+
+	scanners := []struct {
+		name string
+		scan func(io.Reader, *[]Token) ([]Token, error)
+	}{
+		{"reference", scanReference},
+		{"dfa", scan},
+		{"generated", generatedDFA.scan},
+	}
+	fmt.Printf("%-10s %12s %10s %14s %14s\n", tr("анализатор"), tr("мс/проход"), tr("МБ/с"), tr("выделений"), tr("байт"))
+	var base float64
+	for _, s := range scanners {
+		res := testing.Benchmark(func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.scan(bytes.NewReader(src), nil)
+			}
+		})
+		perOp := float64(res.NsPerOp()) / 1e6
+		mbps := float64(res.Bytes) * float64(res.N) / 1e6 / res.T.Seconds()
+		fmt.Printf("%-10s %12.1f %10.1f %14d %14d", s.name, perOp, mbps, res.AllocsPerOp(), res.AllocedBytesPerOp())
+		if base == 0 {
+			base = perOp
+			fmt.Println()
+		} else {
+			fmt.Printf(tr("  (быстрее в %.1f раза)\n"), base/perOp)
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// Те же замеры, что и в команде tfi bench, на тексте размером 1 МБ:
+// go test -bench . -run '^$'
+
+func benchmarkScan(b *testing.B, scan func(io.Reader, *[]Token) ([]Token, error)) {
+	src := benchText(nil, 1<<20)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := scan(bytes.NewReader(src), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	benchmarkScan(b, scan)
+}
+
+func BenchmarkReference(b *testing.B) {
+	benchmarkScan(b, scanReference)
+}
+
+func BenchmarkGenerated(b *testing.B) {
+	benchmarkScan(b, generatedDFA.scan)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"math/big"
+)
+
+// Целые числа. По умолчанию тип int — 64-битный: константа вне диапазона
+// [-2^63, 2^63-1] является ошибкой разбора, а переполнение в plus, min, mult, div —
+// ошибкой выполнения. С флагом -bigint целые не ограничены: значение, которое
+// не помещается в Value.Int, хранится в Value.Big (math/big). Малые значения
+// по-прежнему хранятся в Int, поэтому индексы массивов, границы и шаг цикла for
+// и аргументы встроенных функций обрабатываются как раньше.
+
+// Режим целых произвольной длины; задаётся флагом -bigint
+var bigInts = false
+
+// Целое значение; число, помещающееся в 64 бита, хранится в Int
+func bigValue(n *big.Int) Value {
+	if n.IsInt64() {
+		return Value{Kind: KindInt, Int: n.Int64()}
+	}
+	return Value{Kind: KindInt, Big: n}
+}
+
+// Целое значение в виде big.Int
+func (v Value) bigInt() *big.Int {
+	if v.Big != nil {
+		return v.Big
+	}
+	return big.NewInt(v.Int)
+}
+
+// Арифметика над целыми: в 64-битном режиме переполнение — ошибка выполнения,
+// в режиме -bigint результат вычисляется точно
+func intArith(tok Token, op string, left, right Value) (Value, error) {
+	if op == "div" && right.Big == nil && right.Int == 0 {
+		return Value{}, &RuntimeError{Tok: tok, Msg: tr("деление на ноль")}
+	}
+	if left.Big == nil && right.Big == nil {
+		n, ok := checkedArith(op, left.Int, right.Int)
+		if ok {
+			return Value{Kind: KindInt, Int: n}, nil
+		}
+		if !bigInts {
+			return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("целочисленное переполнение: %d %s %d не помещается в 64 бита", left.Int, op, right.Int)}
+		}
+	}
+	a, b := left.bigInt(), right.bigInt()
+	n := new(big.Int)
+	switch op {
+	case "plus":
+		n.Add(a, b)
+	case "min":
+		n.Sub(a, b)
+	case "mult":
+		n.Mul(a, b)
+	case "div":
+		// Деление с отбрасыванием дробной части, как для 64-битных целых
+		n.Quo(a, b)
+	}
+	return bigValue(n), nil
+}
+
+// Операция над 64-битными целыми; ok ложно при переполнении
+func checkedArith(op string, a, b int64) (int64, bool) {
+	switch op {
+	case "plus":
+		n := a + b
+		return n, (n > a) == (b > 0)
+	case "min":
+		n := a - b
+		return n, (n < a) == (b > 0)
+	case "mult":
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		n := a * b
+		return n, n/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64)
+	case "div":
+		return a / b, !(a == math.MinInt64 && b == -1)
+	}
+	return 0, false
+}
+
+// Сравнение целых значений: -1, 0 или 1
+func cmpInt(a, b Value) int {
+	if a.Big == nil && b.Big == nil {
+		return cmpOrdered(a.Int, b.Int)
+	}
+	return a.bigInt().Cmp(b.bigInt())
+}
+
+// Вещественное значение целого; для очень больших целых — ближайшее или ±Inf
+func intToFloat(v Value) float64 {
+	if v.Big == nil {
+		return float64(v.Int)
+	}
+	f, _ := new(big.Float).SetInt(v.Big).Float64()
+	return f
+}
+
+// Цикл for, у которого начальное значение, граница или шаг не помещаются в 64 бита;
+// переменная v уже проверена на выход за границу
+func (in *Interpreter) execBigFor(s *ForStmt, v *Value, limit, step *big.Int) error {
+	dist := new(big.Int)
+	for {
+		stop, err := in.execLoopBody(s.Body)
+		if err != nil || stop {
+			return err
+		}
+		cur := v.bigInt()
+		if s.Down() {
+			dist.Sub(cur, limit)
+		} else {
+			dist.Sub(limit, cur)
+		}
+		if dist.Cmp(step) < 0 {
+			return nil
+		}
+		next := new(big.Int)
+		if s.Down() {
+			next.Sub(cur, step)
+		} else {
+			next.Add(cur, step)
+		}
+		*v = bigValue(next)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// Встроенные функции. Они видны в любой подпрограмме, если имя не занято
+// объявлением программы:
+//
+//	length(s) — число символов строки;
+//	substr(s, i, n) — n символов строки s начиная с i-го (символы нумеруются с 1).
+type builtin struct {
+	Params int
+	Call   func(c *CallExpr, args []Value) (Value, error)
+}
+
+var builtins = map[string]*builtin{
+	"length": {Params: 1, Call: builtinLength},
+	"substr": {Params: 3, Call: builtinSubstr},
+}
+
+// Имена встроенных функций в алфавитном порядке
+func builtinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (in *Interpreter) callBuiltin(c *CallExpr, b *builtin) (*Value, error) {
+	if len(c.Args) != b.Params {
+		return nil, &RuntimeError{Tok: c.Name, Msg: sprintf("Подпрограмма '%s' ожидает аргументов: %d, передано: %d", c.Name.Lexeme, b.Params, len(c.Args))}
+	}
+	args := make([]Value, len(c.Args))
+	for i, arg := range c.Args {
+		val, err := in.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = val
+	}
+	val, err := b.Call(c, args)
+	if err != nil {
+		return nil, err
+	}
+	return &val, nil
+}
+
+// Проверка вида аргумента встроенной функции
+func builtinArg(c *CallExpr, i int, args []Value, want string) error {
+	ok := false
+	switch want {
+	case "string":
+		ok = isText(args[i])
+	case "int":
+		ok = args[i].Kind == KindInt
+	}
+	if !ok {
+		return &RuntimeError{Tok: c.Args[i].Pos(), Msg: sprintf("аргумент %d функции '%s' должен иметь тип %s, получено %s", i+1, c.Name.Lexeme, want, args[i])}
+	}
+	return nil
+}
+
+func builtinLength(c *CallExpr, args []Value) (Value, error) {
+	err := builtinArg(c, 0, args, "string")
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{Kind: KindInt, Int: int64(utf8.RuneCountInString(args[0].Str))}, nil
+}
+
+func builtinSubstr(c *CallExpr, args []Value) (Value, error) {
+	for i, want := range []string{"string", "int", "int"} {
+		err := builtinArg(c, i, args, want)
+		if err != nil {
+			return Value{}, err
+		}
+	}
+	runes := []rune(args[0].Str)
+	start, count := args[1].Int, args[2].Int
+	if args[1].Big != nil || args[2].Big != nil {
+		return Value{}, &RuntimeError{Tok: c.Name, Msg: sprintf("подстрока [%d, %d символов] вне строки длины %d", args[1].bigInt(), args[2].bigInt(), len(runes))}
+	}
+	if start < 1 || count < 0 || start-1 > int64(len(runes))-count {
+		return Value{}, &RuntimeError{Tok: c.Name, Msg: sprintf("подстрока [%d, %d символов] вне строки длины %d", start, count, len(runes))}
+	}
+	return Value{Kind: KindString, Str: string(runes[start-1 : start-1+count])}, nil
+}
@@ -0,0 +1,657 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Сервер протокола Debug Adapter Protocol (DAP).
+// Сообщения передаются через пару потоков (обычно stdin/stdout) в формате
+// "Content-Length: N\r\n\r\n<JSON>".
+
+const dapThreadID = 1
+
+// Входящее сообщение (запрос клиента)
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type dapResponse struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Command    string `json:"command"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// Строка исходного файла: программы, модуля или включённого файла
+type dapLine struct {
+	Path string // абсолютный путь к файлу
+	Line int
+}
+
+// Режим продолжения выполнения после остановки
+type stepMode int
+
+const (
+	stepContinue stepMode = iota
+	stepNext
+	stepIn
+	stepOut
+	stepTerminate
+)
+
+var errTerminated = errors.New("выполнение прервано отладчиком")
+
+type DAPServer struct {
+	r  *bufio.Reader
+	w  io.Writer
+	mu sync.Mutex
+	// seq защищён mu
+	seq int
+
+	path   string
+	main   string // абсолютный путь к файлу программы
+	prog   *Program
+	interp *Interpreter
+	input  string
+
+	launched    bool
+	configured  bool
+	stopOnEntry bool
+	running     bool
+	stmtLines   map[dapLine]Stmt
+	breakpoints map[dapLine]bool
+
+	// Состояние пошагового выполнения (защищено mu)
+	mode      stepMode
+	stepDepth int
+	pause     bool
+	terminate bool
+	started   bool
+	stopped   bool
+
+	resume  chan stepMode
+	handles []func() []dapVariable // содержимое по ссылке variablesReference (номер + 1)
+	done    chan struct{}
+}
+
+func NewDAPServer(r io.Reader, w io.Writer) *DAPServer {
+	return &DAPServer{
+		r:           bufio.NewReader(r),
+		w:           w,
+		breakpoints: make(map[dapLine]bool),
+		resume:      make(chan stepMode),
+		done:        make(chan struct{}),
+	}
+}
+
+// Основной цикл обработки запросов
+func (s *DAPServer) Serve() error {
+	for {
+		req, err := s.readRequest()
+		if err != nil {
+			if err == io.EOF {
+				s.shutdown()
+				return nil
+			}
+			return err
+		}
+		quit := s.handle(req)
+		if quit {
+			return nil
+		}
+	}
+}
+
+func (s *DAPServer) readRequest() (*dapRequest, error) {
+	length := -1
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, errorf("DAP: некорректный заголовок '%s'", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errorf("DAP: отсутствует заголовок Content-Length")
+	}
+	buf := make([]byte, length)
+	_, err := io.ReadFull(s.r, buf)
+	if err != nil {
+		return nil, err
+	}
+	var req dapRequest
+	err = json.Unmarshal(buf, &req)
+	if err != nil {
+		return nil, errorf("DAP: некорректное сообщение: %v", err)
+	}
+	return &req, nil
+}
+
+func (s *DAPServer) send(msg any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	switch m := msg.(type) {
+	case *dapResponse:
+		m.Seq = s.seq
+	case *dapEvent:
+		m.Seq = s.seq
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *DAPServer) respond(req *dapRequest, body any) {
+	s.send(&dapResponse{Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body})
+}
+
+func (s *DAPServer) fail(req *dapRequest, msg string) {
+	s.send(&dapResponse{Type: "response", RequestSeq: req.Seq, Success: false, Command: req.Command, Message: msg})
+}
+
+func (s *DAPServer) event(name string, body any) {
+	s.send(&dapEvent{Type: "event", Event: name, Body: body})
+}
+
+// Обработка одного запроса; возвращает true, если сеанс завершён
+func (s *DAPServer) handle(req *dapRequest) bool {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsTerminateRequest":         true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+			NoDebug     bool   `json:"noDebug"`
+			Input       string `json:"input"`
+			BigInt      bool   `json:"bigint"`
+		}
+		err := json.Unmarshal(req.Arguments, &args)
+		if err != nil {
+			s.fail(req, err.Error())
+			return false
+		}
+		bigInts = args.BigInt
+		err = s.load(args.Program)
+		if err != nil {
+			s.fail(req, err.Error())
+			return false
+		}
+		s.stopOnEntry = args.StopOnEntry && !args.NoDebug
+		s.input = args.Input
+		if args.NoDebug {
+			s.breakpoints = make(map[dapLine]bool)
+		}
+		s.launched = true
+		s.respond(req, nil)
+		if s.configured {
+			s.start()
+		}
+	case "setBreakpoints":
+		var args struct {
+			Source      dapSource `json:"source"`
+			Breakpoints []struct {
+				Line int `json:"line"`
+			} `json:"breakpoints"`
+			Lines []int `json:"lines"`
+		}
+		err := json.Unmarshal(req.Arguments, &args)
+		if err != nil {
+			s.fail(req, err.Error())
+			return false
+		}
+		lines := args.Lines
+		if args.Breakpoints != nil {
+			lines = lines[:0]
+			for _, bp := range args.Breakpoints {
+				lines = append(lines, bp.Line)
+			}
+		}
+		// Запрос заменяет точки останова только в своём файле; без пути (или до
+		// запуска, пока путь программы неизвестен) файлом считается сама программа
+		path := absPath(args.Source.Path)
+		if args.Source.Path == "" {
+			path = s.main
+		}
+		result := make([]map[string]any, 0, len(lines))
+		s.mu.Lock()
+		for bp := range s.breakpoints {
+			if bp.Path == path {
+				delete(s.breakpoints, bp)
+			}
+		}
+		for _, line := range lines {
+			bp := dapLine{path, line}
+			verified := s.stmtLines == nil || s.stmtLines[bp] != nil
+			if verified {
+				s.breakpoints[bp] = true
+			}
+			result = append(result, map[string]any{"verified": verified, "line": line})
+		}
+		s.mu.Unlock()
+		s.respond(req, map[string]any{"breakpoints": result})
+	case "setExceptionBreakpoints":
+		s.respond(req, map[string]any{"breakpoints": []any{}})
+	case "configurationDone":
+		s.configured = true
+		s.respond(req, nil)
+		if s.launched {
+			s.start()
+		}
+	case "threads":
+		s.respond(req, map[string]any{"threads": []map[string]any{{"id": dapThreadID, "name": "main"}}})
+	case "stackTrace":
+		if !s.isStopped() {
+			s.fail(req, tr("программа не остановлена"))
+			return false
+		}
+		frames := make([]map[string]any, 0, len(s.interp.Frames))
+		for i := len(s.interp.Frames) - 1; i >= 0; i-- {
+			frame := s.interp.Frames[i]
+			line, col := 0, 0
+			if frame.Current != nil {
+				line, col = frame.Current.Pos().LineNum, frame.Current.Pos().ColNum
+			}
+			frames = append(frames, map[string]any{
+				"id":     i + 1,
+				"name":   frame.Name,
+				"line":   line,
+				"column": col,
+				"source": s.frameSource(frame),
+			})
+		}
+		s.respond(req, map[string]any{"stackFrames": frames, "totalFrames": len(frames)})
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		if !s.isStopped() || args.FrameID < 1 || args.FrameID > len(s.interp.Frames) {
+			s.fail(req, tr("неизвестный кадр"))
+			return false
+		}
+		scopes := []map[string]any{{
+			"name":               "Locals",
+			"variablesReference": s.frameHandle(s.interp.Frames[args.FrameID-1]),
+			"expensive":          false,
+		}}
+		if args.FrameID > 1 {
+			// В кадре подпрограммы глобальные переменные (программы или модуля) показываются отдельно
+			scopes = append(scopes, map[string]any{
+				"name":               "Globals",
+				"variablesReference": s.frameHandle(s.interp.globals(s.interp.Frames[args.FrameID-1])),
+				"expensive":          false,
+			})
+		}
+		s.respond(req, map[string]any{"scopes": scopes})
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		if !s.isStopped() || args.VariablesReference < 1 || args.VariablesReference > len(s.handles) {
+			s.fail(req, tr("неизвестная ссылка на переменные"))
+			return false
+		}
+		s.respond(req, map[string]any{"variables": s.handles[args.VariablesReference-1]()})
+	case "continue":
+		s.respond(req, map[string]any{"allThreadsContinued": true})
+		s.resumeWith(stepContinue)
+	case "next":
+		s.respond(req, nil)
+		s.resumeWith(stepNext)
+	case "stepIn":
+		s.respond(req, nil)
+		s.resumeWith(stepIn)
+	case "stepOut":
+		s.respond(req, nil)
+		s.resumeWith(stepOut)
+	case "pause":
+		s.mu.Lock()
+		s.pause = true
+		s.mu.Unlock()
+		s.respond(req, nil)
+	case "terminate":
+		s.respond(req, nil)
+		s.shutdown()
+	case "disconnect":
+		s.shutdown()
+		s.respond(req, nil)
+		return true
+	default:
+		s.fail(req, sprintf("неподдерживаемый запрос '%s'", req.Command))
+	}
+	return false
+}
+
+// Загрузка и разбор отлаживаемой программы
+func (s *DAPServer) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errorf("Ошибка при открытии файла: %v", err)
+	}
+	defer file.Close()
+
+	tokens, err := LexFile(path, file)
+	if err != nil {
+		return errorf("Ошибка лексического анализа: %v", err)
+	}
+	parser := Syntax{tokens: tokens, pos: 0, file: path}
+	prog, err := parser.ParseProgram()
+	if err != nil {
+		return errorf("Ошибка синтаксического анализа: %v", err)
+	}
+
+	s.path = path
+	s.main = absPath(path)
+	s.prog = prog
+	main := s.main
+	s.stmtLines = make(map[dapLine]Stmt)
+	for _, unit := range prog.Units {
+		for _, sub := range unit.Subprograms {
+			collectStmtLines(sub.Body, main, s.stmtLines)
+		}
+	}
+	for _, sub := range prog.Subprograms {
+		collectStmtLines(sub.Body, main, s.stmtLines)
+	}
+	collectStmtLines(prog.Body, main, s.stmtLines)
+	// Точки останова, заданные до запуска, проверяются повторно; заданные без
+	// пути относятся к программе
+	for bp := range s.breakpoints {
+		delete(s.breakpoints, bp)
+		if bp.Path == "" {
+			bp.Path = main
+		}
+		if s.stmtLines[bp] != nil {
+			s.breakpoints[bp] = true
+		}
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// Строка оператора; токены модулей и включённых файлов хранят путь к своему
+// файлу, токены программы — пустой путь
+func stmtLine(stmt Stmt, main string) dapLine {
+	path := stmt.Pos().File
+	if path == "" {
+		path = main
+	}
+	return dapLine{path, stmt.Pos().LineNum}
+}
+
+// Первый оператор каждой строки: точка останова срабатывает только на нём,
+// чтобы вложенные операторы той же строки не останавливали программу повторно
+func collectStmtLines(stmts []Stmt, main string, lines map[dapLine]Stmt) {
+	for _, stmt := range stmts {
+		line := stmtLine(stmt, main)
+		if _, ok := stmt.(*CompoundStmt); !ok && lines[line] == nil {
+			lines[line] = stmt
+		}
+		switch stmt := stmt.(type) {
+		case *CompoundStmt:
+			collectStmtLines(stmt.Body, main, lines)
+		case *IfStmt:
+			collectStmtLines([]Stmt{stmt.Then}, main, lines)
+			if stmt.Else != nil {
+				collectStmtLines([]Stmt{stmt.Else}, main, lines)
+			}
+		case *ForStmt:
+			collectStmtLines([]Stmt{stmt.Body}, main, lines)
+		case *WhileStmt:
+			collectStmtLines([]Stmt{stmt.Body}, main, lines)
+		case *RepeatStmt:
+			collectStmtLines(stmt.Body, main, lines)
+		case *CaseStmt:
+			for _, branch := range stmt.Branches {
+				collectStmtLines([]Stmt{branch.Body}, main, lines)
+			}
+			if stmt.Else != nil {
+				collectStmtLines([]Stmt{stmt.Else}, main, lines)
+			}
+		}
+	}
+}
+
+func (s *DAPServer) source() dapSource {
+	return dapSource{Name: filepath.Base(s.path), Path: s.main}
+}
+
+// Исходный файл кадра: файл модуля или включённый файл ({$include}),
+// которому принадлежит текущий оператор
+func (s *DAPServer) frameSource(frame *Frame) dapSource {
+	path := ""
+	if frame.Current != nil {
+		path = frame.Current.Pos().File
+	}
+	if path == "" && frame.Unit != nil {
+		path = frame.Unit.Path
+	}
+	if path == "" {
+		return s.source()
+	}
+	return dapSource{Name: filepath.Base(path), Path: path}
+}
+
+// Вывод программы передаётся клиенту событиями output
+type dapOutput struct {
+	s *DAPServer
+}
+
+func (o dapOutput) Write(p []byte) (int, error) {
+	o.s.event("output", map[string]any{"category": "stdout", "output": string(p)})
+	return len(p), nil
+}
+
+// Запуск программы в отдельной горутине
+func (s *DAPServer) start() {
+	if s.running {
+		return
+	}
+	s.running = true
+	s.interp = NewInterpreter(strings.NewReader(s.input), dapOutput{s})
+	s.interp.OnStmt = s.onStmt
+	if s.stopOnEntry {
+		s.mode = stepIn
+	} else {
+		s.mode = stepContinue
+	}
+
+	go func() {
+		defer close(s.done)
+		exitCode := 0
+		err := s.interp.Run(s.prog)
+		if err != nil && err != errTerminated {
+			s.event("output", map[string]any{"category": "stderr", "output": err.Error() + "\n"})
+			exitCode = 1
+		}
+		s.event("exited", map[string]any{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// Вызывается интерпретатором перед каждым оператором
+func (s *DAPServer) onStmt(stmt Stmt) error {
+	line := stmtLine(stmt, s.main)
+	depth := len(s.interp.Frames)
+
+	s.mu.Lock()
+	if s.terminate {
+		s.mu.Unlock()
+		return errTerminated
+	}
+	reason := ""
+	switch {
+	case s.pause:
+		reason = "pause"
+	case s.breakpoints[line] && s.stmtLines[line] == stmt:
+		reason = "breakpoint"
+	case s.mode == stepIn:
+		reason = "step"
+	case s.mode == stepNext && depth <= s.stepDepth:
+		reason = "step"
+	case s.mode == stepOut && depth < s.stepDepth:
+		reason = "step"
+	}
+	if reason == "step" && !s.started {
+		reason = "entry"
+	}
+	s.started = true
+	if reason == "" {
+		s.mu.Unlock()
+		return nil
+	}
+	s.pause = false
+	s.stopped = true
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]any{"reason": reason, "threadId": dapThreadID, "allThreadsStopped": true})
+	mode := <-s.resume
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mode == stepTerminate {
+		return errTerminated
+	}
+	s.mode = mode
+	s.stepDepth = depth
+	return nil
+}
+
+func (s *DAPServer) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// Ссылка на переменные кадра; за ними следуют переменные блоков, в которых
+// находится текущий оператор (от внешнего к внутреннему)
+func (s *DAPServer) frameHandle(frame *Frame) int {
+	return s.newHandle(func() []dapVariable {
+		vars := make([]dapVariable, 0, len(frame.Order))
+		for _, f := range append([]*Frame{frame}, frame.Blocks...) {
+			for _, name := range f.Order {
+				v := f.Vars[name]
+				vars = append(vars, s.variable(v.Name, v.Value, v.Type))
+			}
+		}
+		return vars
+	})
+}
+
+func (s *DAPServer) newHandle(vars func() []dapVariable) int {
+	s.handles = append(s.handles, vars)
+	return len(s.handles)
+}
+
+// Описание переменной; элементы массива раскрываются по отдельной ссылке
+func (s *DAPServer) variable(name string, val Value, t *TypeSpec) dapVariable {
+	v := dapVariable{Name: name, Value: val.String(), Type: typeString(t)}
+	switch {
+	case val.Kind == KindArray && t.IsArray():
+		v.VariablesReference = s.newHandle(func() []dapVariable {
+			elems := make([]dapVariable, len(val.Elems))
+			for i, elem := range val.Elems {
+				elems[i] = s.variable(fmt.Sprintf("[%d]", val.Low+int64(i)), elem, t.Underlying().Elem)
+			}
+			return elems
+		})
+	case val.Kind == KindRecord && t.IsRecord():
+		v.VariablesReference = s.newHandle(func() []dapVariable {
+			fields := make([]dapVariable, len(val.Elems))
+			for i, elem := range val.Elems {
+				_, ft := t.Field(val.Names[i])
+				fields[i] = s.variable(val.Names[i], elem, ft)
+			}
+			return fields
+		})
+	}
+	return v
+}
+
+func (s *DAPServer) resumeWith(mode stepMode) {
+	s.mu.Lock()
+	if !s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = false
+	s.mu.Unlock()
+	s.handles = nil
+	s.resume <- mode
+}
+
+// Прерывание выполнения программы и ожидание завершения горутины
+func (s *DAPServer) shutdown() {
+	if !s.running {
+		return
+	}
+	s.mu.Lock()
+	s.terminate = true
+	s.mu.Unlock()
+	s.resumeWith(stepTerminate)
+	<-s.done
+	s.running = false
+}
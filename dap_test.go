@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Клиент DAP для тестов: запросы и ответы передаются серверу через io.Pipe
+type dapClient struct {
+	t      *testing.T
+	w      io.WriteCloser
+	seq    int
+	msgs   chan map[string]any
+	output strings.Builder
+	done   chan error
+}
+
+func newDAPClient(t *testing.T) *dapClient {
+	t.Helper()
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	c := &dapClient{t: t, w: reqW, msgs: make(chan map[string]any, 100), done: make(chan error, 1)}
+	server := NewDAPServer(reqR, respW)
+	go func() {
+		c.done <- server.Serve()
+		respW.Close()
+	}()
+	go func() {
+		defer close(c.msgs)
+		r := bufio.NewReader(respR)
+		for {
+			msg, err := readDAPMessage(r)
+			if err != nil {
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		reqW.Close()
+		select {
+		case <-c.done:
+		case <-time.After(5 * time.Second):
+			t.Error("сервер не завершился")
+		}
+	})
+	return c
+}
+
+func readDAPMessage(r *bufio.Reader) (map[string]any, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			length, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	var msg map[string]any
+	err := json.Unmarshal(buf, &msg)
+	return msg, err
+}
+
+func (c *dapClient) send(command string, args any) {
+	c.t.Helper()
+	c.seq++
+	data, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// Ожидание события или ответа name; события output накапливаются в c.output,
+// прочие сообщения пропускаются
+func (c *dapClient) wait(kind, name string) map[string]any {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.msgs:
+			if !ok {
+				c.t.Fatalf("соединение закрыто до %s '%s'", kind, name)
+			}
+			if msg["type"] == "event" && msg["event"] == "output" {
+				body := msg["body"].(map[string]any)
+				c.output.WriteString(body["output"].(string))
+			}
+			if msg["type"] != kind || (msg["event"] != name && msg["command"] != name) {
+				continue
+			}
+			if kind == "response" && msg["success"] != true {
+				c.t.Fatalf("запрос '%s' не выполнен: %v", name, msg["message"])
+			}
+			body, _ := msg["body"].(map[string]any)
+			return body
+		case <-timeout:
+			c.t.Fatalf("нет %s '%s'", kind, name)
+		}
+	}
+}
+
+// Запрос и тело ответа на него
+func (c *dapClient) request(command string, args any) map[string]any {
+	c.t.Helper()
+	c.send(command, args)
+	return c.wait("response", command)
+}
+
+func (c *dapClient) stopped(reason string) {
+	c.t.Helper()
+	body := c.wait("event", "stopped")
+	if body["reason"] != reason {
+		c.t.Fatalf("остановка по причине '%v', ожидалось '%s'", body["reason"], reason)
+	}
+}
+
+// Кадры стека от верхнего к нижнему: имя и строка
+func (c *dapClient) stack() []string {
+	c.t.Helper()
+	var frames []string
+	for _, f := range c.request("stackTrace", map[string]any{"threadId": dapThreadID})["stackFrames"].([]any) {
+		frame := f.(map[string]any)
+		frames = append(frames, fmt.Sprintf("%s:%v", frame["name"], frame["line"]))
+	}
+	return frames
+}
+
+// Переменные кадра frameID в виде "имя=значение"
+func (c *dapClient) locals(frameID int) []string {
+	c.t.Helper()
+	scopes := c.request("scopes", map[string]any{"frameId": frameID})["scopes"].([]any)
+	ref := scopes[0].(map[string]any)["variablesReference"]
+	var vars []string
+	for _, v := range c.request("variables", map[string]any{"variablesReference": ref})["variables"].([]any) {
+		variable := v.(map[string]any)
+		vars = append(vars, fmt.Sprintf("%s=%s", variable["name"], variable["value"]))
+	}
+	return vars
+}
+
+func writeFile(t *testing.T, dir, name, text string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func expectList(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("%s: %v, ожидалось %v", what, got, want)
+	}
+}
+
+func TestDAPSession(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "prog.txt", `program var i, s : int;
+procedure add(k : int);
+begin
+    s as s plus k
+end;
+begin
+    s as 0d;
+    for i as 1d to 2d do
+        add(i);
+    write(s)
+end.
+`)
+	c := newDAPClient(t)
+	c.request("initialize", map[string]any{"adapterID": "tfi"})
+	c.wait("event", "initialized")
+	c.request("launch", map[string]any{"program": path})
+	bps := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []any{map[string]any{"line": 9}, map[string]any{"line": 3}},
+	})["breakpoints"].([]any)
+	if bps[0].(map[string]any)["verified"] != true || bps[1].(map[string]any)["verified"] != false {
+		t.Fatalf("точки останова %v: строка 9 должна быть подтверждена, строка 3 — нет", bps)
+	}
+	c.request("configurationDone", nil)
+
+	c.stopped("breakpoint")
+	expectList(t, "стек", c.stack(), "program:9")
+	expectList(t, "переменные", c.locals(1), "i=1", "s=0")
+
+	c.request("stepIn", map[string]any{"threadId": dapThreadID})
+	c.stopped("step")
+	expectList(t, "стек", c.stack(), "add:4", "program:9")
+	// Номер кадра — его глубина: кадр программы первый
+	expectList(t, "переменные", c.locals(2), "k=1")
+
+	// next выходит из процедуры и останавливается на точке останова второй итерации
+	c.request("next", map[string]any{"threadId": dapThreadID})
+	c.stopped("breakpoint")
+	expectList(t, "переменные", c.locals(1), "i=2", "s=1")
+
+	c.request("next", map[string]any{"threadId": dapThreadID})
+	c.stopped("step")
+	expectList(t, "стек", c.stack(), "program:10")
+
+	c.request("continue", map[string]any{"threadId": dapThreadID})
+	exited := c.wait("event", "exited")
+	c.wait("event", "terminated")
+	if exited["exitCode"] != 0.0 {
+		t.Errorf("код завершения %v", exited["exitCode"])
+	}
+	if got := c.output.String(); got != "3\n" {
+		t.Errorf("вывод программы %q, ожидалось %q", got, "3\n")
+	}
+	c.request("disconnect", nil)
+}
+
+// Точки останова относятся к файлу из запроса: строка модуля не останавливает
+// программу на той же строке основного файла, а запрос для одного файла не
+// сбрасывает точки останова другого
+func TestDAPBreakpointsInUnit(t *testing.T) {
+	dir := t.TempDir()
+	unit := writeFile(t, dir, "mathx.tfu", `unit mathx;
+interface
+const limit = 10d;
+function sqr(x : int) : int;
+implementation
+function sqr(x : int) : int;
+begin
+    return x mult x
+end;
+end.
+`)
+	path := writeFile(t, dir, "prog.txt", `program uses mathx; var r : int;
+begin
+    r as sqr(3d);
+    write(r)
+end.
+`)
+	verified := func(body map[string]any) []string {
+		var list []string
+		for _, bp := range body["breakpoints"].([]any) {
+			list = append(list, fmt.Sprint(bp.(map[string]any)["verified"]))
+		}
+		return list
+	}
+	c := newDAPClient(t)
+	c.request("initialize", map[string]any{"adapterID": "tfi"})
+	c.request("launch", map[string]any{"program": path})
+	expectList(t, "точки останова модуля", verified(c.request("setBreakpoints", map[string]any{
+		"source": map[string]any{"path": unit},
+		"lines":  []int{3, 8},
+	})), "false", "true")
+	expectList(t, "точки останова программы", verified(c.request("setBreakpoints", map[string]any{
+		"source": map[string]any{"path": path},
+		"lines":  []int{4},
+	})), "true")
+	c.request("configurationDone", nil)
+
+	c.stopped("breakpoint")
+	expectList(t, "стек", c.stack(), "sqr:8", "program:3")
+	frames := c.request("stackTrace", map[string]any{"threadId": dapThreadID})["stackFrames"].([]any)
+	if source := frames[0].(map[string]any)["source"].(map[string]any); source["path"] != unit {
+		t.Errorf("файл кадра %v, ожидался %s", source["path"], unit)
+	}
+	c.request("continue", map[string]any{"threadId": dapThreadID})
+	c.stopped("breakpoint")
+	expectList(t, "стек", c.stack(), "program:4")
+	c.request("continue", map[string]any{"threadId": dapThreadID})
+	c.wait("event", "terminated")
+	if got := c.output.String(); got != "9\n" {
+		t.Errorf("вывод программы %q, ожидалось %q", got, "9\n")
+	}
+	c.request("disconnect", nil)
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Стадии анализа, на которых обнаружена ошибка
+const (
+	StageLexical = "lexical"
+	StageSyntax  = "syntax"
+	StageRuntime = "runtime"
+)
+
+// Дополнительная пометка к диагностике (например, место открывающей скобки)
+type Label struct {
+	Tok     Token
+	Message string
+}
+
+// Правка текста: замена Length байтов начиная со смещения Offset на NewText
+type TextEdit struct {
+	Offset  int
+	Length  int
+	NewText string
+}
+
+// Исправление, которое можно применить автоматически. Manual — только
+// предложение (замена имени на похожее меняет смысл программы): tfi fix
+// его не применяет, а в SARIF оно не попадает
+type Fix struct {
+	Message string
+	Edits   []TextEdit
+	Manual  bool
+}
+
+// Диагностическое сообщение с позицией токена, к которому оно относится
+type Diagnostic struct {
+	Stage   string
+	Code    string // идентификатор правила (см. rules)
+	Tok     Token
+	Message string
+	Labels  []Label
+	Help    string // подсказка, например "возможно, имелось в виду 'while'"
+	Fixes   []Fix
+	Warning bool // предупреждение: не мешает выполнению программы
+}
+
+func (d *Diagnostic) Error() string {
+	return sprintf("%s на строке %d столбце %d", d.Message, d.Tok.LineNum, d.Tok.ColNum)
+}
+
+// Диагностика с сообщением по строке формата; код правила определяется по той же строке
+func newDiagnostic(stage string, tok Token, format string, args ...any) *Diagnostic {
+	return &Diagnostic{Stage: stage, Code: ruleCode(format), Tok: tok, Message: sprintf(format, args...)}
+}
+
+// Правило — вид диагностики с постоянным кодом (используется в SARIF и JSON)
+type Rule struct {
+	Code        string
+	Name        string
+	Format      string // строка формата сообщения
+	Description string
+}
+
+var rules = []Rule{
+	// Лексический анализ
+	{"L001", "invalidNumber", "некорректное число '%s'", "Некорректная запись числа"},
+	{"L002", "unknownOperator", "Неизвестная операция '%s'", "Неизвестный знак операции"},
+	{"L003", "unknownCharacter", "Неизвестный символ '%c'", "Символ, недопустимый в программе"},
+	{"L004", "unterminatedComment", "Некорректный комментарий: ожидался '}'", "Незакрытый комментарий"},
+	{"L005", "unknownEscape", "Неизвестная escape-последовательность '%s'", "Недопустимая escape-последовательность в строке"},
+	{"L006", "unterminatedString", "Незакрытая константа: ожидалась %c", "Незакрытая строковая или символьная константа"},
+	{"L007", "invalidChar", "Символьная константа должна содержать один символ, получено %d", "Символьная константа длиной не в один символ"},
+	{"L008", "directiveArgument", "Директива {$%s} требует аргумента", "Директива препроцессора без имени или файла"},
+	{"L009", "unmatchedDirective", "Директива {$%s} без {$ifdef}", "{$else} или {$endif} без открывающей директивы"},
+	{"L010", "duplicateElse", "Повторная директива {$else}", "Две директивы {$else} в одном условном фрагменте"},
+	{"L011", "unknownDirective", "Неизвестная директива '%s'", "Неизвестная директива препроцессора"},
+	{"L012", "unterminatedCondition", "Не закрыта директива {$%s}: ожидалась {$endif}", "{$ifdef} или {$ifndef} без {$endif}"},
+	{"L013", "recursiveInclude", "Рекурсивное включение файла '%s'", "Файл включает сам себя напрямую или через другие файлы"},
+	{"L014", "includeError", "Не удалось включить файл '%s': %v", "Включаемый файл не удалось прочитать"},
+	{"L015", "formatDirectives", "Текст с директивами препроцессора нельзя отформатировать", "Форматирование текста с директивами препроцессора"},
+	{"L016", "invalidDigit", "цифра '%c' недопустима в числе '%s' с основанием %d", "Цифра, которой нет в системе счисления числа"},
+	{"L017", "missingHexSuffix", "шестнадцатеричное число '%s' должно оканчиваться на 'h'", "Шестнадцатеричные цифры в числе без суффикса h"},
+	{"L018", "invalidNumberChar", "недопустимый символ '%c' в числе '%s'", "Посторонний символ в записи числа"},
+	{"L019", "missingFraction", "после десятичной точки в числе '%s' ожидалась цифра", "Вещественное число без цифр после точки"},
+	{"L020", "missingExponent", "в порядке числа '%s' ожидалась цифра", "Вещественное число без цифр порядка"},
+
+	// Синтаксический анализ
+	{"S001", "unexpectedToken", "Ожидалось %s '%s', получено %s '%s'", "Пропущен обязательный токен"},
+	{"S002", "expectedIdentifier", "Ожидался идентификатор, получено %s '%s'", "Ожидалось имя переменной в объявлении"},
+	{"S003", "duplicateVariable", "Переменная '%s' уже объявлена", "Повторное объявление переменной"},
+	{"S004", "expectedDeclarationSeparator", "Ожидалось ',' или ':', получено %s '%s'", "Ожидался разделитель в объявлении"},
+	{"S005", "expectedType", "Ожидался тип, получено %s '%s'", "Ожидался тип переменной"},
+	{"S006", "expectedSemicolon", "Ожидалось ';', получено %s '%s'", "Пропущена ';' после объявления"},
+	{"S007", "expectedStatementSeparator", "Ожидалось ';' или 'end', получено %s '%s'", "Пропущен разделитель операторов"},
+	{"S008", "unknownStatement", "Неизвестный оператор '%s'", "Неизвестный оператор"},
+	{"S009", "expectedStatement", "Ожидался оператор, получено %s '%s'", "Ожидался оператор"},
+	{"S010", "expectedCompoundSeparator", "Ожидалось ':' или ']' в составном операторе, получено %s '%s'", "Пропущен разделитель в составном операторе"},
+	{"S011", "expectedAssignmentTarget", "Ожидался идентификатор в присваивании, получено %s '%s'", "Ожидалось имя переменной в присваивании"},
+	{"S012", "undeclaredVariable", "Необъявленная переменная '%s'", "Использование необъявленной переменной"},
+	{"S013", "expectedReadTarget", "Ожидался идентификатор в read, получено %s '%s'", "Ожидалось имя переменной в read"},
+	{"S014", "expectedArgumentSeparator", "Ожидалось ',' или ')', получено %s '%s'", "Пропущен разделитель в списке аргументов"},
+	{"S015", "expectedFactor", "Ожидался фактор, получено %s '%s'", "Ожидалось выражение"},
+	{"S016", "duplicateName", "Имя '%s' уже объявлено", "Повторное объявление имени"},
+	{"S017", "expectedSubprogramName", "Ожидалось имя подпрограммы, получено %s '%s'", "Ожидалось имя процедуры или функции"},
+	{"S018", "expectedParameterSeparator", "Ожидалось ';' или ')', получено %s '%s'", "Пропущен разделитель в списке параметров"},
+	{"S019", "notVariable", "'%s' не является переменной", "Имя подпрограммы использовано как переменная"},
+	{"S020", "notSubprogram", "'%s' не является процедурой или функцией", "Вызов переменной"},
+	{"S021", "undeclaredSubprogram", "Необъявленная подпрограмма '%s'", "Вызов необъявленной подпрограммы"},
+	{"S022", "procedureValue", "Процедура '%s' не возвращает значения", "Процедура использована в выражении"},
+	{"S023", "argumentCount", "Подпрограмма '%s' ожидает аргументов: %d, передано: %d", "Неверное число аргументов"},
+	{"S024", "returnOutsideSubprogram", "Оператор return допустим только в процедуре или функции", "return вне подпрограммы"},
+	{"S025", "expectedArrayBound", "Ожидалась целая константа в границе массива, получено %s '%s'", "Граница массива не является целой константой"},
+	{"S026", "invalidArrayBounds", "Нижняя граница массива %d больше верхней %d", "Пустой диапазон индексов массива"},
+	{"S027", "notArray", "'%s' не является массивом", "Индексирование переменной, не являющейся массивом"},
+	{"S028", "indexOutOfBounds", "Индекс %d вне границ массива '%s' [%d..%d]", "Константный индекс вне границ массива"},
+	{"S029", "readStructured", "Переменную '%s' типа %s нельзя прочитать", "Чтение массива или записи целиком"},
+	{"S030", "unknownType", "Неизвестный тип '%s'", "Использование необъявленного типа"},
+	{"S031", "notType", "'%s' не является типом", "Имя переменной или подпрограммы использовано как тип"},
+	{"S032", "duplicateField", "Поле '%s' уже объявлено", "Повторное объявление поля записи"},
+	{"S033", "notRecord", "'%s' не является записью", "Выбор поля у переменной, не являющейся записью"},
+	{"S034", "expectedFieldName", "Ожидалось имя поля, получено %s '%s'", "Ожидалось имя поля после '.'"},
+	{"S035", "unknownField", "Запись '%s' не содержит поля '%s'", "Обращение к несуществующему полю записи"},
+	{"S036", "invalidConstant", "Некорректное константное выражение: %s", "Выражение константы нельзя вычислить при компиляции"},
+	{"S037", "assignConstant", "Нельзя изменить константу '%s'", "Присваивание константе или чтение в неё"},
+	{"S038", "invalidCaseLabel", "Метка case должна быть целой или логической константой, получено %s", "Метка case не является целой или логической константой"},
+	{"S039", "caseLabelType", "Метка case '%s' имеет тип %s, а первая метка — тип %s", "Метки case разных типов"},
+	{"S040", "emptyCaseRange", "Пустой диапазон меток %s..%s", "Нижняя граница диапазона меток больше верхней"},
+	{"S041", "duplicateCaseLabel", "Значение %s уже встречается среди меток case", "Повторяющаяся метка case"},
+	{"S042", "expectedCaseSeparator", "Ожидалось ';', 'else' или 'end' в операторе case, получено %s '%s'", "Пропущен разделитель ветвей оператора case"},
+	{"S043", "jumpOutsideLoop", "Оператор %s допустим только внутри цикла", "break или continue вне цикла"},
+	{"S044", "expectedRepeatSeparator", "Ожидалось ';' или 'until', получено %s '%s'", "Пропущен разделитель операторов в цикле repeat"},
+	{"S045", "forVariableNotSimple", "Переменной цикла for должна быть простая переменная, получено '%s'", "Элемент массива или поле записи в качестве переменной цикла for"},
+	{"S046", "forVariableType", "Переменная цикла for '%s' должна быть целой, а не %s", "Переменная цикла for не целого типа"},
+	{"S047", "invalidForStep", "Шаг цикла for должен быть положительным целым, получено %s", "Нулевой, отрицательный или дробный шаг цикла for"},
+	{"S048", "assignLoopVariable", "Нельзя изменить переменную цикла '%s' в теле цикла", "Присваивание переменной цикла for в его теле"},
+	{"S049", "expectedUnitName", "Ожидалось имя модуля, получено %s '%s'", "Ожидалось имя модуля"},
+	{"S050", "unitNameMismatch", "Имя модуля '%s' не совпадает с именем файла '%s'", "Имя модуля отличается от имени его файла"},
+	{"S051", "unitNotFound", "Модуль '%s' не найден", "Файл подключаемого модуля не найден в пути поиска"},
+	{"S052", "unitCycle", "Циклическая зависимость модулей: %s", "Модули подключают друг друга по кругу"},
+	{"S053", "duplicateUnit", "Модуль '%s' уже подключён", "Повторное подключение модуля в списке uses"},
+	{"S054", "headingMismatch", "Заголовок подпрограммы '%s' не совпадает с объявлением в интерфейсе", "Реализация подпрограммы модуля отличается от её заголовка в интерфейсе"},
+	{"S055", "unimplementedHeading", "Подпрограмма '%s' объявлена в интерфейсе, но не реализована", "Подпрограмма интерфейса модуля без реализации"},
+	{"S056", "unitReadError", "Ошибка чтения модуля '%s': %v", "Файл модуля не удалось прочитать"},
+	{"S057", "intLiteralOverflow", "Целое число '%s' не помещается в 64 бита", "Целая константа вне диапазона 64-битных целых"},
+	{"S058", "floatLiteralRange", "Вещественное число '%s' вне допустимого диапазона", "Вещественная константа вне диапазона float"},
+	{"S059", "caseLabelOverflow", "Метка case %s не помещается в 64 бита", "Метка case вне диапазона 64-битных целых"},
+	{"S060", "grammarUnexpectedToken", "Ожидалось %s, получено %s '%s'", "Токен не допускается грамматикой tfi.ebnf в этом месте (анализаторы ll и lalr)"},
+	{"S061", "arrayTooLarge", "Массив из %s элементов слишком велик (не более %d)", "Массив с числом элементов больше допустимого"},
+
+	// Предупреждения
+	{"W001", "incompleteBoolCase", "Оператор case по логическому выражению не охватывает значение %s", "Оператор case по логическому выражению без ветви для true или false"},
+	{"W002", "shadowedName", "Имя '%s' скрывает внешнее объявление", "Переменная блока скрывает имя из объемлющей области (ошибка при -shadow=error)"},
+}
+
+// Код правила по строке формата сообщения
+func ruleCode(format string) string {
+	for _, r := range rules {
+		if r.Format == format {
+			return r.Code
+		}
+	}
+	return ""
+}
+
+// Добавление пометки к диагностике; прочие ошибки возвращаются без изменений
+func withLabel(err error, tok Token, message string) error {
+	if d, ok := err.(*Diagnostic); ok {
+		d.Labels = append(d.Labels, Label{Tok: tok, Message: message})
+	}
+	return err
+}
+
+// Приведение ошибки анализа или выполнения к диагностике (nil для прочих ошибок)
+func asDiagnostic(err error) *Diagnostic {
+	switch e := err.(type) {
+	case *Diagnostic:
+		return e
+	case *RuntimeError:
+		return &Diagnostic{Stage: StageRuntime, Tok: e.Tok, Message: e.Msg}
+	}
+	return nil
+}
+
+func stageTitle(stage string) string {
+	switch stage {
+	case StageLexical:
+		return tr("лексическая ошибка")
+	case StageSyntax:
+		return tr("синтаксическая ошибка")
+	case StageRuntime:
+		return tr("ошибка выполнения")
+	}
+	return tr("ошибка")
+}
+
+// Заголовок диагностики: стадия для ошибки или "предупреждение"
+func diagnosticTitle(d *Diagnostic) string {
+	if d.Warning {
+		return tr("предупреждение")
+	}
+	return stageTitle(d.Stage)
+}
+
+// Цвета ANSI
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[1;31m"
+	ansiYellow = "\033[1;33m"
+	ansiBlue   = "\033[1;34m"
+)
+
+// Режим раскраски: "auto" — только если вывод идёт в терминал; задаётся флагом -color
+var colorMode = "auto"
+
+func useColor(w io.Writer) bool {
+	switch colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Примечания «файл включён здесь» для ошибки во включённом файле: по одному на
+// каждое место включения от файла ошибки до основного файла или модуля. Места,
+// уже отмеченные пометкой ошибки (лексические ошибки получают её при включении),
+// не повторяются.
+func includeNotes(d *Diagnostic) []Label {
+	var notes []Label
+	file := d.Tok.File
+	for i := 0; i < len(includedFrom); i++ {
+		site, ok := includedFrom[file]
+		if !ok {
+			break
+		}
+		labeled := false
+		for _, l := range d.Labels {
+			labeled = labeled || (l.Tok.LineNum == site.LineNum && l.Tok.ColNum == site.ColNum && l.Message == tr("файл включён здесь"))
+		}
+		if !labeled {
+			notes = append(notes, Label{Tok: site, Message: tr("файл включён здесь")})
+		}
+		file = site.File
+	}
+	return notes
+}
+
+// Вывод ошибки в стиле rustc: заголовок file:line:col, строка исходного текста
+// с подчёркиванием токена и дополнительные пометки.
+//
+//	test.txt:12:5: синтаксическая ошибка: Ожидалось ';', получено Identifier 'x'
+//	   |
+//	12 |     x as 1d
+//	   |     ^
+func renderError(w io.Writer, name string, src []byte, err error) {
+	d := asDiagnostic(err)
+	if d == nil {
+		fmt.Fprintf(w, "%s: %v\n", name, err)
+		return
+	}
+	color := useColor(w)
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	accent := ansiRed
+	if d.Warning {
+		accent = ansiYellow
+	}
+	// Ошибка в модуле выводится вместе с текстом модуля; пометки в других файлах
+	// выводятся после фрагмента текста отдельными примечаниями
+	file := name
+	name, src = sourceOf(d.Tok, name, src)
+	var labels, notes []Label
+	for _, l := range d.Labels {
+		if l.Tok.File == d.Tok.File {
+			labels = append(labels, l)
+		} else {
+			notes = append(notes, l)
+		}
+	}
+	notes = append(notes, includeNotes(d)...)
+	printNotes := func(indent string) {
+		for _, l := range notes {
+			at := file
+			if l.Tok.File != "" {
+				at = displayPath(l.Tok.File)
+			}
+			fmt.Fprintf(w, "%s%s %s %s:%d:%d: %s\n", indent, paint(ansiBlue, "="), paint(ansiBold, tr("примечание")+":"), at, l.Tok.LineNum, l.Tok.ColNum, l.Message)
+		}
+	}
+	fmt.Fprintf(w, "%s %s %s\n",
+		paint(ansiBold, fmt.Sprintf("%s:%d:%d:", displayPath(name), d.Tok.LineNum, d.Tok.ColNum)),
+		paint(accent, diagnosticTitle(d)+":"),
+		paint(ansiBold, d.Message))
+
+	lines := strings.Split(string(src), "\n")
+	if src == nil || d.Tok.LineNum < 1 || d.Tok.LineNum > len(lines) {
+		if d.Help != "" {
+			fmt.Fprintf(w, "  %s %s\n", paint(ansiBlue, "="), paint(ansiBold, tr("помощь")+":")+" "+d.Help)
+		}
+		printNotes("  ")
+		return
+	}
+
+	// Основная позиция и пометки выводятся в порядке следования строк
+	type mark struct {
+		tok     Token
+		message string
+		primary bool
+	}
+	marks := []mark{{tok: d.Tok, primary: true}}
+	for _, l := range labels {
+		if l.Tok.LineNum >= 1 && l.Tok.LineNum <= len(lines) {
+			marks = append(marks, mark{tok: l.Tok, message: l.Message})
+		}
+	}
+	sort.SliceStable(marks, func(i, j int) bool {
+		return before(marks[i].tok, marks[j].tok)
+	})
+
+	width := len(fmt.Sprint(marks[len(marks)-1].tok.LineNum))
+	gutter := strings.Repeat(" ", width) + " |"
+	fmt.Fprintln(w, paint(ansiBlue, gutter))
+	prevLine := 0
+	for _, m := range marks {
+		line := strings.TrimRight(lines[m.tok.LineNum-1], "\r")
+		if m.tok.LineNum != prevLine {
+			if prevLine != 0 && m.tok.LineNum > prevLine+1 {
+				fmt.Fprintln(w, paint(ansiBlue, strings.Repeat(" ", width)+"..."))
+			}
+			fmt.Fprintf(w, "%s %s\n", paint(ansiBlue, fmt.Sprintf("%*d |", width, m.tok.LineNum)), strings.ReplaceAll(line, "\t", " "))
+			prevLine = m.tok.LineNum
+		}
+
+		// Отступ до начала токена: табуляции заменяются пробелами так же, как в строке выше
+		col := m.tok.ColNum - 1
+		if col < 0 {
+			col = 0
+		}
+		length := utf8.RuneCountInString(m.tok.Lexeme)
+		if length == 0 {
+			length = 1
+		}
+		var underline string
+		if m.primary {
+			underline = paint(accent, "^"+strings.Repeat("~", length-1))
+		} else {
+			underline = paint(ansiBlue, strings.Repeat("-", length))
+		}
+		if m.message != "" {
+			underline += " " + paint(ansiBlue, m.message)
+		}
+		fmt.Fprintf(w, "%s %s%s\n", paint(ansiBlue, gutter), strings.Repeat(" ", col), underline)
+	}
+	if d.Help != "" {
+		fmt.Fprintf(w, "%s %s %s\n", strings.Repeat(" ", width), paint(ansiBlue, "="), paint(ansiBold, tr("помощь")+":")+" "+d.Help)
+	}
+	printNotes(strings.Repeat(" ", width) + " ")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Описания и сообщения правил переведены: иначе SARIF и сообщения с -lang=en
+// выводятся по-русски
+func TestRulesTranslated(t *testing.T) {
+	for _, r := range rules {
+		if _, ok := messagesEn[r.Description]; !ok {
+			t.Errorf("%s: нет перевода описания %q", r.Code, r.Description)
+		}
+		if _, ok := messagesEn[r.Format]; !ok {
+			t.Errorf("%s: нет перевода сообщения %q", r.Code, r.Format)
+		}
+	}
+}
+
+// Метка «функция должна вернуть значение» ставится, только если после return
+// нет выражения; ошибки внутри выражения не получают её
+func TestReturnValueLabel(t *testing.T) {
+	const label = "функция должна вернуть значение"
+	cases := map[string]bool{
+		"return":             true,
+		"[return]":           true,
+		"return plus 1d":     false,
+		"return undeclared":  false,
+		"return 1d plus (2d": false,
+	}
+	for ret, labeled := range cases {
+		src := "program var r : int;\nfunction f() : int;\nbegin\n    " + ret + "\nend;\nbegin\n    r as f()\nend.\n"
+		_, err := checkSource("prog.txt", []byte(src))
+		d := asDiagnostic(err)
+		if d == nil {
+			t.Fatalf("%s: ожидалась ошибка, получено %v", ret, err)
+		}
+		got := false
+		for _, l := range d.Labels {
+			got = got || l.Message == label
+		}
+		if got != labeled {
+			t.Errorf("%s: метки %+v, ожидалась метка «%s»: %v", ret, d.Labels, label, labeled)
+		}
+	}
+}
+
+// Ошибка во включённом файле выводится с примечаниями о местах включения на
+// всех стадиях, а не только лексической
+func TestIncludeNotes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.txt":  "program var\n{$include 'decls.txt'}\nbegin\n{$include 'body.txt'}\n    write(a)\nend.\n",
+		"decls.txt": "a : int;\n{$include 'more.txt'}\n",
+		"more.txt":  "b : ;\n",
+		"body.txt":  "    a as 1d div 0d;\n",
+	}
+	notes := []string{"decls.txt:2:1: файл включён здесь", "main.txt:2:1: файл включён здесь"}
+	d := checkFiles(t, dir, "main.txt", files)
+	expectNotes(t, d, notes...)
+
+	files["more.txt"] = "b : int;\n"
+	writeFile(t, dir, "more.txt", files["more.txt"])
+	path := filepath.Join(dir, "main.txt")
+	tokens, err := LexFile(path, strings.NewReader(files["main.txt"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := Syntax{tokens: tokens, pos: 0, file: path}
+	prog, err := parser.ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewInterpreter(strings.NewReader(""), io.Discard).Run(prog)
+	expectNotes(t, asDiagnostic(err), "main.txt:4:1: файл включён здесь")
+}
+
+func expectNotes(t *testing.T, d *Diagnostic, notes ...string) {
+	t.Helper()
+	if d == nil {
+		t.Fatal("ожидалась диагностика")
+	}
+	var buf strings.Builder
+	renderError(&buf, "main.txt", nil, d)
+	out := buf.String()
+	if strings.Count(out, "файл включён здесь") != len(notes) {
+		t.Errorf("примечаний о включении не %d:\n%s", len(notes), out)
+	}
+	for _, note := range notes {
+		if !strings.Contains(out, note) {
+			t.Errorf("нет примечания %q:\n%s", note, out)
+		}
+	}
+}
@@ -0,0 +1,1621 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Машиночитаемый вывод результатов анализа
+
+// Запись о токене для JSON Lines
+type tokenRecord struct {
+	Type   string `json:"type"`
+	Lexeme string `json:"lexeme"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+	Value  any    `json:"value,omitempty"`
+	Base   int    `json:"base,omitempty"`
+	Kind   string `json:"kind,omitempty"` // вид числа: int или float
+	Error  string `json:"error,omitempty"`
+}
+
+func newTokenRecord(t Token) tokenRecord {
+	rec := tokenRecord{
+		Type:   TokenTypeToString(t.Type),
+		Lexeme: t.Lexeme,
+		Line:   t.LineNum,
+		Column: t.ColNum,
+		Offset: t.Offset,
+	}
+	if t.Type == TokenString || t.Type == TokenChar {
+		rec.Value = t.Value.Str
+	}
+	if t.Type == TokenNumber {
+		rec.Kind = "int"
+		if t.Value.Kind == KindFloat {
+			rec.Kind = "float"
+		}
+		err := checkNumberRange(t.Lexeme, t.Value)
+		if err != nil {
+			rec.Error = err.Error()
+		} else {
+			rec.Value, rec.Base = numberValue(t.Value), t.Base
+		}
+	}
+	return rec
+}
+
+// Значение числа для вывода в JSON: int64, float64 или *big.Int
+func numberValue(v Value) any {
+	if v.Kind == KindFloat {
+		return v.Float
+	}
+	if v.Big != nil {
+		return v.Big
+	}
+	return v.Int
+}
+
+// Вывод токенов в формате JSON Lines: один JSON-объект на строку
+func writeTokensJSONL(w io.Writer, tokens []Token) error {
+	enc := json.NewEncoder(w)
+	for _, t := range tokens {
+		err := enc.Encode(newTokenRecord(t))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Вывод токенов в формате CSV с заголовком
+func writeTokensCSV(w io.Writer, tokens []Token) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"type", "lexeme", "line", "column", "offset", "value", "base", "kind"})
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		rec := newTokenRecord(t)
+		value, base := "", ""
+		switch v := rec.Value.(type) {
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		case *big.Int:
+			value = v.String()
+		case float64:
+			value = strconv.FormatFloat(v, 'g', -1, 64)
+		case string:
+			value = v
+		}
+		if rec.Base != 0 {
+			base = strconv.Itoa(rec.Base)
+		}
+		err = cw.Write([]string{rec.Type, rec.Lexeme, strconv.Itoa(rec.Line), strconv.Itoa(rec.Column), strconv.Itoa(rec.Offset), value, base, rec.Kind})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Сериализация дерева разбора в JSON. Каждый узел содержит вид (kind) и позицию
+// своего первого токена; токены внутри узла хранятся вместе с позициями, поэтому
+// дерево можно загрузить обратно функцией ReadProgramJSON.
+
+type jsonToken struct {
+	Text   string `json:"text"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+type jsonNode struct {
+	Kind   string `json:"kind"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+
+	Name        *jsonToken   `json:"name,omitempty"`
+	Uses        []*jsonToken `json:"uses,omitempty"`
+	Consts      []*jsonNode  `json:"consts,omitempty"`
+	Types       []*jsonNode  `json:"types,omitempty"`
+	Params      []*jsonNode  `json:"params,omitempty"`
+	Subprograms []*jsonNode  `json:"subprograms,omitempty"`
+	Call        *jsonNode    `json:"call,omitempty"`
+
+	Decls  []*jsonNode  `json:"decls,omitempty"`
+	Body   []*jsonNode  `json:"body,omitempty"`
+	End    *jsonToken   `json:"end,omitempty"`
+	Names  []*jsonToken `json:"names,omitempty"`
+	Type   *jsonNode    `json:"type,omitempty"`
+	Low    *jsonNode    `json:"low,omitempty"`
+	High   *jsonNode    `json:"high,omitempty"`
+	Elem   *jsonNode    `json:"elem,omitempty"`
+	Fields []*jsonNode  `json:"fields,omitempty"`
+
+	Target  *jsonNode   `json:"target,omitempty"`
+	Targets []*jsonNode `json:"targets,omitempty"`
+	Array   *jsonNode   `json:"array,omitempty"`
+	Index   *jsonNode   `json:"index,omitempty"`
+	Record  *jsonNode   `json:"record,omitempty"`
+	Value   *jsonNode   `json:"value,omitempty"`
+	Cond    *jsonNode   `json:"cond,omitempty"`
+	Then    *jsonNode   `json:"then,omitempty"`
+	Else    *jsonNode   `json:"else,omitempty"`
+	Init    *jsonNode   `json:"init,omitempty"`
+	To      *jsonNode   `json:"to,omitempty"`
+	Args    []*jsonNode `json:"args,omitempty"`
+
+	Dir      *jsonToken  `json:"dir,omitempty"`
+	Step     *jsonNode   `json:"step,omitempty"`
+	Selector *jsonNode   `json:"selector,omitempty"`
+	Branches []*jsonNode `json:"branches,omitempty"`
+	Labels   []*jsonNode `json:"labels,omitempty"`
+
+	Op         *jsonToken `json:"op,omitempty"`
+	Precedence int        `json:"precedence,omitempty"`
+	Left       *jsonNode  `json:"left,omitempty"`
+	Right      *jsonNode  `json:"right,omitempty"`
+	Operand    *jsonNode  `json:"operand,omitempty"`
+	Token      *jsonToken `json:"token,omitempty"`
+	Number     any        `json:"number,omitempty"`
+	String     *string    `json:"string,omitempty"`
+	Base       int        `json:"base,omitempty"`
+}
+
+func toJSONToken(t Token) *jsonToken {
+	return &jsonToken{Text: t.Lexeme, Line: t.LineNum, Column: t.ColNum, Offset: t.Offset}
+}
+
+func newJSONNode(kind string, pos Token) *jsonNode {
+	return &jsonNode{Kind: kind, Line: pos.LineNum, Column: pos.ColNum, Offset: pos.Offset}
+}
+
+func programToJSON(prog *Program) *jsonNode {
+	n := newJSONNode("Program", prog.Tok)
+	for _, name := range prog.Uses {
+		n.Uses = append(n.Uses, toJSONToken(name))
+	}
+	for _, decl := range prog.Consts {
+		c := newJSONNode("ConstDecl", decl.Pos())
+		c.Name = toJSONToken(decl.Name)
+		c.Value = exprToJSON(decl.Value)
+		n.Consts = append(n.Consts, c)
+	}
+	for _, decl := range prog.Types {
+		t := newJSONNode("TypeDecl", decl.Pos())
+		t.Name = toJSONToken(decl.Name)
+		t.Type = typeToJSON(decl.Type)
+		n.Types = append(n.Types, t)
+	}
+	n.Decls = declsToJSON(prog.Decls)
+	for _, sub := range prog.Subprograms {
+		n.Subprograms = append(n.Subprograms, subprogramToJSON(sub))
+	}
+	for _, s := range prog.Body {
+		n.Body = append(n.Body, stmtToJSON(s))
+	}
+	n.End = toJSONToken(prog.End)
+	return n
+}
+
+func declsToJSON(decls []*VarDecl) []*jsonNode {
+	var list []*jsonNode
+	for _, decl := range decls {
+		d := newJSONNode("VarDecl", decl.Pos())
+		for _, name := range decl.Names {
+			d.Names = append(d.Names, toJSONToken(name))
+		}
+		d.Type = typeToJSON(decl.Type)
+		list = append(list, d)
+	}
+	return list
+}
+
+// Тип: узел Type с именем типа, ArrayType с границами и типом элементов
+// или RecordType с полями
+func typeToJSON(t *TypeSpec) *jsonNode {
+	switch {
+	case t.Elem != nil:
+		n := newJSONNode("ArrayType", t.Pos())
+		n.Low = exprToJSON(t.Low)
+		n.High = exprToJSON(t.High)
+		n.Elem = typeToJSON(t.Elem)
+		return n
+	case t.Fields != nil:
+		n := newJSONNode("RecordType", t.Pos())
+		n.Fields = declsToJSON(t.Fields)
+		n.End = toJSONToken(t.End)
+		return n
+	}
+	n := newJSONNode("Type", t.Pos())
+	n.Token = toJSONToken(t.Tok)
+	return n
+}
+
+// Подпрограмма: вид Procedure или Function, для функции type — тип результата
+func subprogramToJSON(sub *Subprogram) *jsonNode {
+	kind := "Procedure"
+	if sub.IsFunction() {
+		kind = "Function"
+	}
+	n := newJSONNode(kind, sub.Pos())
+	n.Name = toJSONToken(sub.Name)
+	n.Params = declsToJSON(sub.Params)
+	if sub.IsFunction() {
+		n.Type = typeToJSON(sub.Result)
+	}
+	n.Decls = declsToJSON(sub.Decls)
+	for _, s := range sub.Body {
+		n.Body = append(n.Body, stmtToJSON(s))
+	}
+	n.End = toJSONToken(sub.End)
+	return n
+}
+
+func stmtToJSON(s Stmt) *jsonNode {
+	switch s := s.(type) {
+	case *AssignStmt:
+		n := newJSONNode("Assign", s.Pos())
+		n.Target = exprToJSON(s.Target)
+		n.Value = exprToJSON(s.Value)
+		return n
+	case *IfStmt:
+		n := newJSONNode("If", s.Pos())
+		n.Cond = exprToJSON(s.Cond)
+		n.Then = stmtToJSON(s.Then)
+		if s.Else != nil {
+			n.Else = stmtToJSON(s.Else)
+		}
+		return n
+	case *ForStmt:
+		n := newJSONNode("For", s.Pos())
+		n.Init = stmtToJSON(s.Init)
+		n.Dir = toJSONToken(s.Dir)
+		n.To = exprToJSON(s.To)
+		if s.Step != nil {
+			n.Step = exprToJSON(s.Step)
+		}
+		n.Body = []*jsonNode{stmtToJSON(s.Body)}
+		return n
+	case *WhileStmt:
+		n := newJSONNode("While", s.Pos())
+		n.Cond = exprToJSON(s.Cond)
+		n.Body = []*jsonNode{stmtToJSON(s.Body)}
+		return n
+	case *RepeatStmt:
+		n := newJSONNode("Repeat", s.Pos())
+		for _, st := range s.Body {
+			n.Body = append(n.Body, stmtToJSON(st))
+		}
+		n.End = toJSONToken(s.Until)
+		n.Cond = exprToJSON(s.Cond)
+		return n
+	case *BreakStmt:
+		return newJSONNode("Break", s.Pos())
+	case *ContinueStmt:
+		return newJSONNode("Continue", s.Pos())
+	case *CaseStmt:
+		n := newJSONNode("Case", s.Pos())
+		n.Selector = exprToJSON(s.Selector)
+		for _, branch := range s.Branches {
+			b := newJSONNode("CaseBranch", branch.Pos())
+			for _, label := range branch.Labels {
+				l := newJSONNode("CaseLabel", label.Low.Pos())
+				l.Low = exprToJSON(label.Low)
+				if label.High != nil {
+					l.High = exprToJSON(label.High)
+				}
+				b.Labels = append(b.Labels, l)
+			}
+			b.Body = []*jsonNode{stmtToJSON(branch.Body)}
+			n.Branches = append(n.Branches, b)
+		}
+		if s.Else != nil {
+			n.Else = stmtToJSON(s.Else)
+		}
+		n.End = toJSONToken(s.End)
+		return n
+	case *ReadStmt:
+		n := newJSONNode("Read", s.Pos())
+		for _, target := range s.Targets {
+			n.Targets = append(n.Targets, exprToJSON(target))
+		}
+		return n
+	case *WriteStmt:
+		n := newJSONNode("Write", s.Pos())
+		for _, arg := range s.Args {
+			n.Args = append(n.Args, exprToJSON(arg))
+		}
+		return n
+	case *CompoundStmt:
+		n := newJSONNode("Compound", s.Pos())
+		n.Decls = declsToJSON(s.Decls)
+		for _, st := range s.Body {
+			n.Body = append(n.Body, stmtToJSON(st))
+		}
+		n.End = toJSONToken(s.End)
+		return n
+	case *CallStmt:
+		n := newJSONNode("CallStmt", s.Pos())
+		n.Call = exprToJSON(s.Call)
+		return n
+	case *ReturnStmt:
+		n := newJSONNode("Return", s.Pos())
+		if s.Value != nil {
+			n.Value = exprToJSON(s.Value)
+		}
+		return n
+	}
+	return nil
+}
+
+func exprToJSON(e Expr) *jsonNode {
+	switch e := e.(type) {
+	case *BinaryExpr:
+		n := newJSONNode("Binary", e.Pos())
+		n.Op = toJSONToken(e.Op)
+		n.Precedence = precedence(e)
+		n.Left = exprToJSON(e.Left)
+		n.Right = exprToJSON(e.Right)
+		return n
+	case *UnaryExpr:
+		n := newJSONNode("Unary", e.Pos())
+		n.Op = toJSONToken(e.Op)
+		n.Operand = exprToJSON(e.X)
+		return n
+	case *Ident:
+		kind := "Ident"
+		if e.Const != nil {
+			kind = "Const"
+		}
+		n := newJSONNode(kind, e.Pos())
+		n.Token = toJSONToken(e.Tok)
+		return n
+	case *IndexExpr:
+		n := newJSONNode("Index", e.Pos())
+		n.Array = exprToJSON(e.X)
+		n.Index = exprToJSON(e.Index)
+		n.End = toJSONToken(e.End)
+		return n
+	case *FieldExpr:
+		n := newJSONNode("Field", e.Pos())
+		n.Record = exprToJSON(e.X)
+		n.Name = toJSONToken(e.Name)
+		return n
+	case *NumberLit:
+		n := newJSONNode("Number", e.Pos())
+		n.Token = toJSONToken(e.Tok)
+		if checkNumberRange(e.Tok.Lexeme, e.Tok.Value) == nil {
+			n.Base = e.Tok.Base
+			n.Number = numberValue(e.Tok.Value)
+		}
+		return n
+	case *BoolLit:
+		n := newJSONNode("Bool", e.Pos())
+		n.Token = toJSONToken(e.Tok)
+		return n
+	case *StringLit:
+		kind := "String"
+		if e.IsChar() {
+			kind = "Char"
+		}
+		n := newJSONNode(kind, e.Pos())
+		n.Token = toJSONToken(e.Tok)
+		str := e.Tok.Value.Str
+		n.String = &str
+		return n
+	case *CallExpr:
+		n := newJSONNode("Call", e.Pos())
+		n.Name = toJSONToken(e.Name)
+		for _, arg := range e.Args {
+			n.Args = append(n.Args, exprToJSON(arg))
+		}
+		n.End = toJSONToken(e.End)
+		return n
+	}
+	return nil
+}
+
+func writeProgramJSON(w io.Writer, prog *Program) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(programToJSON(prog))
+}
+
+// Загрузка дерева разбора из JSON, записанного writeProgramJSON; подключённые
+// модули ищутся так же, как при разборе текста программы из файла name.
+// Дерево проверяется так же, как текст программы (см. checkTree)
+func ReadProgramJSON(r io.Reader, name string) (*Program, error) {
+	prog, _, err := readProgramJSON(r, name)
+	return prog, err
+}
+
+// То же, что ReadProgramJSON, но возвращает и предупреждения проверки
+func readProgramJSON(r io.Reader, name string) (*Program, []*Diagnostic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	prog, err := loadProgramJSON(data, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings, err := checkTree(prog, name, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prog, warnings, nil
+}
+
+func loadProgramJSON(data []byte, name string) (*Program, error) {
+	var root jsonNode
+	err := json.Unmarshal(data, &root)
+	if err != nil {
+		return nil, errorf("некорректный JSON: %v", err)
+	}
+	if root.Kind != "Program" {
+		return nil, errorf("ожидался узел Program, получен '%s'", root.Kind)
+	}
+	prog := &Program{Tok: keywordToken(&root, "program")}
+	var deps []*Unit
+	for _, n := range root.Uses {
+		if n == nil {
+			return nil, nullNode("uses")
+		}
+		tok := fromJSONToken(n, TokenIdentifier)
+		unit, err := loader.load(name, tok)
+		if err != nil {
+			return nil, err
+		}
+		prog.Uses = append(prog.Uses, tok)
+		deps = append(deps, unit)
+	}
+	prog.Units = unitOrder(deps)
+	for _, n := range root.Consts {
+		if n == nil {
+			return nil, nullNode("consts")
+		}
+		if n.Kind != "ConstDecl" || n.Name == nil {
+			return nil, errorf("некорректный узел '%s' на строке %d", n.Kind, n.Line)
+		}
+		value, err := exprFromJSON(n.Value)
+		if err != nil {
+			return nil, err
+		}
+		prog.Consts = append(prog.Consts, &ConstDecl{Name: fromJSONToken(n.Name, TokenIdentifier), Value: value})
+	}
+	for _, n := range root.Types {
+		if n == nil {
+			return nil, nullNode("types")
+		}
+		if n.Kind != "TypeDecl" || n.Name == nil || n.Type == nil {
+			return nil, errorf("некорректный узел '%s' на строке %d", n.Kind, n.Line)
+		}
+		typ, err := typeFromJSON(n.Type)
+		if err != nil {
+			return nil, err
+		}
+		prog.Types = append(prog.Types, &TypeDecl{Name: fromJSONToken(n.Name, TokenIdentifier), Type: typ})
+	}
+	prog.Decls, err = declsFromJSON("decls", root.Decls)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range root.Subprograms {
+		if n == nil {
+			return nil, nullNode("subprograms")
+		}
+		sub, err := subprogramFromJSON(n)
+		if err != nil {
+			return nil, err
+		}
+		prog.Subprograms = append(prog.Subprograms, sub)
+	}
+	prog.Body, err = stmtsFromJSON(root.Body)
+	if err != nil {
+		return nil, err
+	}
+	if root.End != nil {
+		prog.End = fromJSONToken(root.End, TokenKeyword)
+	}
+	err = linkNames(prog, deps)
+	if err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+// Семантическая проверка загруженного дерева: оно печатается в каноническом
+// виде (как tfi fmt) и разбирается тем же анализатором, что и текст программы,
+// поэтому проверяются объявления, типы и области видимости. Токенам
+// напечатанного текста возвращаются позиции соответствующих токенов дерева
+// (data — исходный JSON), так что ошибки указывают на место в исходной программе.
+func checkTree(prog *Program, name string, data []byte) ([]*Diagnostic, error) {
+	tokens, err := LexFile(name, strings.NewReader(Format(prog, nil)))
+	if err != nil {
+		return nil, errorf("дерево содержит некорректный токен: %v", err)
+	}
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, errorf("некорректный JSON: %v", err)
+	}
+	retarget(tokens, treeTokens(root))
+	parser := Syntax{tokens: tokens, pos: 0, file: name}
+	_, err = parser.ParseProgram()
+	// Правки исправлений относятся к тексту программы, которого нет
+	for _, d := range append(parser.Warnings, asDiagnostic(err)) {
+		if d != nil && d.Tok.File == "" {
+			d.Fixes = nil
+		}
+	}
+	return parser.Warnings, err
+}
+
+// Первые токены узлов, которые хранятся в JSON только позицией узла
+var nodeKeywords = map[string]string{
+	"Program": "program", "RecordType": "record", "ArrayType": "array",
+	"Procedure": "procedure", "Function": "function",
+	"If": "if", "For": "for", "While": "while", "Repeat": "repeat", "Break": "break",
+	"Continue": "continue", "Case": "case", "Read": "read", "Write": "write",
+	"Compound": "[", "Return": "return",
+}
+
+// Токены дерева из JSON с известными позициями в порядке следования в тексте
+func treeTokens(root any) []Token {
+	var list []Token
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			line, _ := v["line"].(float64)
+			column, _ := v["column"].(float64)
+			offset, _ := v["offset"].(float64)
+			lexeme, ok := v["text"].(string)
+			if !ok {
+				kind, _ := v["kind"].(string)
+				lexeme, ok = nodeKeywords[kind]
+			}
+			if ok && line > 0 {
+				list = append(list, Token{Lexeme: lexeme, LineNum: int(line), ColNum: int(column), Offset: int(offset)})
+			}
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(root)
+	sort.SliceStable(list, func(i, j int) bool { return before(list[i], list[j]) })
+	return list
+}
+
+// Перенос позиций токенов дерева tree на токены напечатанного текста: токены
+// сопоставляются по порядку и лексеме. Токен, которого нет в дереве (разделитель
+// или ключевое слово, восстановленное при печати), получает позицию предыдущего.
+func retarget(tokens, tree []Token) {
+	var last Token
+	for i := range tokens {
+		// Токен, записанный в нескольких узлах, сопоставляется один раз
+		for len(tree) > 0 && !before(last, tree[0]) {
+			tree = tree[1:]
+		}
+		if len(tree) > 0 && tree[0].Lexeme == tokens[i].Lexeme {
+			last = tree[0]
+			tree = tree[1:]
+		}
+		tokens[i].LineNum, tokens[i].ColNum, tokens[i].Offset = last.LineNum, last.ColNum, last.Offset
+	}
+}
+
+// Связывание имён типов и констант с их объявлениями (при разборе текста
+// программы это делает синтаксический анализатор). Узел Const загружается как
+// идентификатор с заготовкой объявления, в которой известно только имя.
+// Имена из интерфейсов модулей deps связываются, если программа их не скрывает;
+// вызовы подпрограмм модулей связываются с заголовками из интерфейсов.
+func linkNames(prog *Program, deps []*Unit) error {
+	types := make(map[string]*TypeSpec)
+	consts := make(map[string]*ConstDecl)
+	imported := make(map[string]*Subprogram)
+	for name, sym := range importScope(deps).symbols {
+		switch sym.Kind {
+		case symConst:
+			consts[name] = sym.Const
+		case symType:
+			types[name] = sym.Type
+		case symSubprogram:
+			imported[name] = sym.Sub
+		}
+	}
+	for _, sub := range prog.Subprograms {
+		delete(imported, sub.Name.Lexeme)
+	}
+	var err error
+	linkExpr := func(e Expr) {
+		walkExpr(e, func(e Expr) {
+			if call, ok := e.(*CallExpr); ok && call.Sub == nil {
+				call.Sub = imported[call.Name.Lexeme]
+			}
+			id, ok := e.(*Ident)
+			if !ok || id.Const == nil || id.Const.Value != nil || err != nil {
+				return
+			}
+			decl, ok := consts[id.Tok.Lexeme]
+			if !ok {
+				err = errorf("неизвестная константа '%s' на строке %d", id.Tok.Lexeme, id.Tok.LineNum)
+				return
+			}
+			id.Const = decl
+		})
+	}
+	var link func(t *TypeSpec) error
+	linkDecls := func(decls []*VarDecl) error {
+		for _, decl := range decls {
+			err := link(decl.Type)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	link = func(t *TypeSpec) error {
+		switch {
+		case t.Elem != nil:
+			linkExpr(t.Low)
+			linkExpr(t.High)
+			if err != nil {
+				return err
+			}
+			return link(t.Elem)
+		case t.Fields != nil:
+			return linkDecls(t.Fields)
+		case t.Tok.Type == TokenIdentifier:
+			def, ok := types[t.Tok.Lexeme]
+			if !ok {
+				return errorf("неизвестный тип '%s' на строке %d", t.Tok.Lexeme, t.Tok.LineNum)
+			}
+			t.Def = def
+		}
+		return nil
+	}
+
+	// Выражения операторов и типы переменных, объявленных в блоках
+	linkBody := func(body []Stmt) error {
+		for _, s := range body {
+			inspectStmt(s, func(s Stmt) {
+				if block, ok := s.(*CompoundStmt); ok && err == nil {
+					err = linkDecls(block.Decls)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			walkStmt(s, linkExpr)
+		}
+		return err
+	}
+
+	for _, decl := range prog.Consts {
+		linkExpr(decl.Value)
+		if err != nil {
+			return err
+		}
+		consts[decl.Name.Lexeme] = decl
+	}
+	for _, decl := range prog.Types {
+		err := link(decl.Type)
+		if err != nil {
+			return err
+		}
+		types[decl.Name.Lexeme] = decl.Type
+	}
+	err = linkDecls(prog.Decls)
+	if err != nil {
+		return err
+	}
+	for _, sub := range prog.Subprograms {
+		err = linkDecls(sub.Params)
+		if err != nil {
+			return err
+		}
+		err = linkDecls(sub.Decls)
+		if err != nil {
+			return err
+		}
+		if sub.Result != nil {
+			err = link(sub.Result)
+			if err != nil {
+				return err
+			}
+		}
+		err = linkBody(sub.Body)
+		if err != nil {
+			return err
+		}
+	}
+	return linkBody(prog.Body)
+}
+
+// Объявления переменных из списка field узла (decls, params или fields)
+func declsFromJSON(field string, list []*jsonNode) ([]*VarDecl, error) {
+	var decls []*VarDecl
+	for _, d := range list {
+		if d == nil {
+			return nil, nullNode(field)
+		}
+		if d.Kind != "VarDecl" || len(d.Names) == 0 || d.Type == nil {
+			return nil, errorf("некорректный узел '%s' на строке %d", d.Kind, d.Line)
+		}
+		typ, err := typeFromJSON(d.Type)
+		if err != nil {
+			return nil, err
+		}
+		decl := &VarDecl{Type: typ}
+		for _, name := range d.Names {
+			if name == nil {
+				return nil, nullNode("names")
+			}
+			decl.Names = append(decl.Names, fromJSONToken(name, TokenIdentifier))
+		}
+		decls = append(decls, decl)
+	}
+	return decls, nil
+}
+
+func typeFromJSON(n *jsonNode) (*TypeSpec, error) {
+	bad := errorf("некорректный узел '%s' на строке %d", n.Kind, n.Line)
+	switch n.Kind {
+	case "Type":
+		if n.Token == nil {
+			return nil, bad
+		}
+		typ := TokenIdentifier
+		if isKeyword(n.Token.Text) {
+			typ = TokenKeyword
+		}
+		return &TypeSpec{Tok: fromJSONToken(n.Token, typ)}, nil
+	case "RecordType":
+		if len(n.Fields) == 0 {
+			return nil, bad
+		}
+		t := &TypeSpec{Tok: keywordToken(n, "record")}
+		var err error
+		t.Fields, err = declsFromJSON("fields", n.Fields)
+		if err != nil {
+			return nil, err
+		}
+		if n.End != nil {
+			t.End = fromJSONToken(n.End, TokenKeyword)
+		}
+		return t, nil
+	case "ArrayType":
+		if n.Elem == nil {
+			return nil, bad
+		}
+		t := &TypeSpec{Tok: keywordToken(n, "array")}
+		var err error
+		t.Low, err = exprFromJSON(n.Low)
+		if err != nil {
+			return nil, err
+		}
+		t.High, err = exprFromJSON(n.High)
+		if err != nil {
+			return nil, err
+		}
+		t.Elem, err = typeFromJSON(n.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	return nil, bad
+}
+
+func stmtsFromJSON(list []*jsonNode) ([]Stmt, error) {
+	var stmts []Stmt
+	for _, n := range list {
+		s, err := stmtFromJSON(n)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+func subprogramFromJSON(n *jsonNode) (*Subprogram, error) {
+	if (n.Kind != "Procedure" && n.Kind != "Function") || n.Name == nil || (n.Kind == "Function" && n.Type == nil) {
+		return nil, errorf("некорректный узел '%s' на строке %d", n.Kind, n.Line)
+	}
+	sub := &Subprogram{Tok: keywordToken(n, strings.ToLower(n.Kind)), Name: fromJSONToken(n.Name, TokenIdentifier)}
+	var err error
+	if n.Type != nil {
+		sub.Result, err = typeFromJSON(n.Type)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sub.Params, err = declsFromJSON("params", n.Params)
+	if err != nil {
+		return nil, err
+	}
+	sub.Decls, err = declsFromJSON("decls", n.Decls)
+	if err != nil {
+		return nil, err
+	}
+	sub.Body, err = stmtsFromJSON(n.Body)
+	if err != nil {
+		return nil, err
+	}
+	if n.End != nil {
+		sub.End = fromJSONToken(n.End, TokenKeyword)
+	}
+	return sub, nil
+}
+
+// Элемент списка, записанный в JSON как null
+func nullNode(field string) error {
+	return errorf("некорректный узел null в списке '%s'", field)
+}
+
+func fromJSONToken(t *jsonToken, typ TokenType) Token {
+	return Token{Type: typ, Lexeme: t.Text, LineNum: t.Line, ColNum: t.Column, Offset: t.Offset}
+}
+
+func keywordToken(n *jsonNode, lexeme string) Token {
+	typ := TokenKeyword
+	if lexeme == "[" {
+		typ = TokenDelimiter
+	}
+	return Token{Type: typ, Lexeme: lexeme, LineNum: n.Line, ColNum: n.Column, Offset: n.Offset}
+}
+
+func stmtFromJSON(n *jsonNode) (Stmt, error) {
+	if n == nil {
+		return nil, errorf("отсутствует обязательный узел оператора")
+	}
+	bad := errorf("некорректный узел '%s' на строке %d", n.Kind, n.Line)
+	switch n.Kind {
+	case "Assign":
+		target, err := targetFromJSON(n.Target)
+		if err != nil {
+			return nil, err
+		}
+		value, err := exprFromJSON(n.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &AssignStmt{Target: target, Value: value}, nil
+	case "If":
+		s := &IfStmt{Tok: keywordToken(n, "if")}
+		var err error
+		s.Cond, err = exprFromJSON(n.Cond)
+		if err != nil {
+			return nil, err
+		}
+		s.Then, err = stmtFromJSON(n.Then)
+		if err != nil {
+			return nil, err
+		}
+		if n.Else != nil {
+			s.Else, err = stmtFromJSON(n.Else)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case "For":
+		if len(n.Body) != 1 {
+			return nil, bad
+		}
+		s := &ForStmt{Tok: keywordToken(n, "for")}
+		init, err := stmtFromJSON(n.Init)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		s.Init, ok = init.(*AssignStmt)
+		if !ok {
+			return nil, bad
+		}
+		s.Dir = Token{Type: TokenKeyword, Lexeme: "to"}
+		if n.Dir != nil {
+			if n.Dir.Text != "to" && n.Dir.Text != "downto" {
+				return nil, bad
+			}
+			s.Dir = fromJSONToken(n.Dir, TokenKeyword)
+		}
+		s.To, err = exprFromJSON(n.To)
+		if err != nil {
+			return nil, err
+		}
+		if n.Step != nil {
+			s.Step, err = exprFromJSON(n.Step)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s.Body, err = stmtFromJSON(n.Body[0])
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "While":
+		if len(n.Body) != 1 {
+			return nil, bad
+		}
+		s := &WhileStmt{Tok: keywordToken(n, "while")}
+		var err error
+		s.Cond, err = exprFromJSON(n.Cond)
+		if err != nil {
+			return nil, err
+		}
+		s.Body, err = stmtFromJSON(n.Body[0])
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "Repeat":
+		s := &RepeatStmt{Tok: keywordToken(n, "repeat")}
+		for _, child := range n.Body {
+			st, err := stmtFromJSON(child)
+			if err != nil {
+				return nil, err
+			}
+			s.Body = append(s.Body, st)
+		}
+		var err error
+		s.Cond, err = exprFromJSON(n.Cond)
+		if err != nil {
+			return nil, err
+		}
+		if n.End != nil {
+			s.Until = fromJSONToken(n.End, TokenKeyword)
+		}
+		return s, nil
+	case "Break":
+		return &BreakStmt{Tok: keywordToken(n, "break")}, nil
+	case "Continue":
+		return &ContinueStmt{Tok: keywordToken(n, "continue")}, nil
+	case "Case":
+		if len(n.Branches) == 0 {
+			return nil, bad
+		}
+		s := &CaseStmt{Tok: keywordToken(n, "case")}
+		var err error
+		s.Selector, err = exprFromJSON(n.Selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range n.Branches {
+			if b == nil {
+				return nil, nullNode("branches")
+			}
+			if b.Kind != "CaseBranch" || len(b.Labels) == 0 || len(b.Body) != 1 {
+				return nil, bad
+			}
+			branch := &CaseBranch{}
+			for _, l := range b.Labels {
+				if l == nil {
+					return nil, nullNode("labels")
+				}
+				if l.Kind != "CaseLabel" {
+					return nil, bad
+				}
+				label := &CaseLabel{}
+				label.Low, err = exprFromJSON(l.Low)
+				if err != nil {
+					return nil, err
+				}
+				if l.High != nil {
+					label.High, err = exprFromJSON(l.High)
+					if err != nil {
+						return nil, err
+					}
+				}
+				branch.Labels = append(branch.Labels, label)
+			}
+			branch.Body, err = stmtFromJSON(b.Body[0])
+			if err != nil {
+				return nil, err
+			}
+			s.Branches = append(s.Branches, branch)
+		}
+		if n.Else != nil {
+			s.Else, err = stmtFromJSON(n.Else)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if n.End != nil {
+			s.End = fromJSONToken(n.End, TokenKeyword)
+		}
+		return s, nil
+	case "Read":
+		if len(n.Targets) == 0 {
+			return nil, bad
+		}
+		s := &ReadStmt{Tok: keywordToken(n, "read")}
+		for _, child := range n.Targets {
+			target, err := targetFromJSON(child)
+			if err != nil {
+				return nil, err
+			}
+			s.Targets = append(s.Targets, target)
+		}
+		return s, nil
+	case "Write":
+		if len(n.Args) == 0 {
+			return nil, bad
+		}
+		s := &WriteStmt{Tok: keywordToken(n, "write")}
+		for _, arg := range n.Args {
+			e, err := exprFromJSON(arg)
+			if err != nil {
+				return nil, err
+			}
+			s.Args = append(s.Args, e)
+		}
+		return s, nil
+	case "Compound":
+		if len(n.Body) == 0 {
+			return nil, bad
+		}
+		s := &CompoundStmt{Tok: keywordToken(n, "[")}
+		var err error
+		s.Decls, err = declsFromJSON("decls", n.Decls)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range n.Body {
+			st, err := stmtFromJSON(child)
+			if err != nil {
+				return nil, err
+			}
+			s.Body = append(s.Body, st)
+		}
+		if n.End != nil {
+			s.End = fromJSONToken(n.End, TokenDelimiter)
+		}
+		return s, nil
+	case "CallStmt":
+		e, err := exprFromJSON(n.Call)
+		if err != nil {
+			return nil, err
+		}
+		call, ok := e.(*CallExpr)
+		if !ok {
+			return nil, bad
+		}
+		return &CallStmt{Call: call}, nil
+	case "Return":
+		s := &ReturnStmt{Tok: keywordToken(n, "return")}
+		if n.Value != nil {
+			var err error
+			s.Value, err = exprFromJSON(n.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+	return nil, bad
+}
+
+// Переменная в присваивании или read: идентификатор или элемент массива
+func targetFromJSON(n *jsonNode) (Expr, error) {
+	e, err := exprFromJSON(n)
+	if err != nil {
+		return nil, err
+	}
+	switch e.(type) {
+	case *Ident, *IndexExpr, *FieldExpr:
+		return e, nil
+	}
+	return nil, errorf("некорректный узел '%s' на строке %d", n.Kind, n.Line)
+}
+
+func exprFromJSON(n *jsonNode) (Expr, error) {
+	if n == nil {
+		return nil, errorf("отсутствует обязательный узел выражения")
+	}
+	bad := errorf("некорректный узел '%s' на строке %d", n.Kind, n.Line)
+	switch n.Kind {
+	case "Binary":
+		if n.Op == nil {
+			return nil, bad
+		}
+		left, err := exprFromJSON(n.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := exprFromJSON(n.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpr{Op: fromJSONToken(n.Op, TokenOperator), Left: left, Right: right}, nil
+	case "Unary":
+		if n.Op == nil {
+			return nil, bad
+		}
+		x, err := exprFromJSON(n.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: fromJSONToken(n.Op, TokenOperator), X: x}, nil
+	case "Ident":
+		if n.Token == nil {
+			return nil, bad
+		}
+		return &Ident{Tok: fromJSONToken(n.Token, TokenIdentifier)}, nil
+	case "Const":
+		if n.Token == nil {
+			return nil, bad
+		}
+		// Объявление подставляется при связывании имён (linkNames)
+		tok := fromJSONToken(n.Token, TokenIdentifier)
+		return &Ident{Tok: tok, Const: &ConstDecl{Name: tok}}, nil
+	case "Index":
+		x, err := targetFromJSON(n.Array)
+		if err != nil {
+			return nil, err
+		}
+		index, err := exprFromJSON(n.Index)
+		if err != nil {
+			return nil, err
+		}
+		e := &IndexExpr{X: x, Index: index}
+		if n.End != nil {
+			e.End = fromJSONToken(n.End, TokenDelimiter)
+		}
+		return e, nil
+	case "Field":
+		if n.Name == nil {
+			return nil, bad
+		}
+		x, err := targetFromJSON(n.Record)
+		if err != nil {
+			return nil, err
+		}
+		return &FieldExpr{X: x, Name: fromJSONToken(n.Name, TokenIdentifier)}, nil
+	case "Number":
+		if n.Token == nil {
+			return nil, bad
+		}
+		val, base, err := decodeNumber(n.Token.Text)
+		if _, ok := err.(*numberError); ok {
+			return nil, bad
+		}
+		if err != nil {
+			// Значение вне диапазона (например, дерево записано в режиме -bigint)
+			return nil, err
+		}
+		tok := fromJSONToken(n.Token, TokenNumber)
+		tok.Value, tok.Base = val, base
+		return &NumberLit{Tok: tok}, nil
+	case "String", "Char":
+		if n.Token == nil {
+			return nil, bad
+		}
+		str, err := decodeString(n.Token.Text)
+		quote := "\""
+		typ := TokenString
+		if n.Kind == "Char" {
+			quote, typ = "'", TokenChar
+		}
+		if err != nil || !strings.HasPrefix(n.Token.Text, quote) || (typ == TokenChar && utf8.RuneCountInString(str) != 1) {
+			return nil, bad
+		}
+		tok := fromJSONToken(n.Token, typ)
+		tok.Value = Value{Kind: KindString, Str: str}
+		if typ == TokenChar {
+			tok.Value.Kind = KindChar
+		}
+		return &StringLit{Tok: tok}, nil
+	case "Bool":
+		if n.Token == nil || (n.Token.Text != "true" && n.Token.Text != "false") {
+			return nil, bad
+		}
+		return &BoolLit{Tok: fromJSONToken(n.Token, TokenKeyword), Value: n.Token.Text == "true"}, nil
+	case "Call":
+		if n.Name == nil {
+			return nil, bad
+		}
+		call := &CallExpr{Name: fromJSONToken(n.Name, TokenIdentifier)}
+		for _, arg := range n.Args {
+			e, err := exprFromJSON(arg)
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, e)
+		}
+		if n.End != nil {
+			call.End = fromJSONToken(n.End, TokenDelimiter)
+		}
+		return call, nil
+	}
+	return nil, bad
+}
+
+// Запись дерева в виде S-выражений. Позиции не выводятся: этот формат
+// предназначен для сравнения структуры деревьев (diff), а позиции меняются
+// при любой правке текста.
+func writeProgramSexpr(w io.Writer, prog *Program) error {
+	var sb strings.Builder
+	sb.WriteString("(program")
+	if len(prog.Uses) > 0 {
+		sb.WriteString("\n  (uses")
+		for _, name := range prog.Uses {
+			sb.WriteString(" " + name.Lexeme)
+		}
+		sb.WriteString(")")
+	}
+	if len(prog.Consts) > 0 {
+		sb.WriteString("\n  (const")
+		for _, decl := range prog.Consts {
+			sb.WriteString("\n    (" + decl.Name.Lexeme + " = " + exprSexpr(decl.Value) + ")")
+		}
+		sb.WriteString(")")
+	}
+	if len(prog.Types) > 0 {
+		sb.WriteString("\n  (type")
+		for _, decl := range prog.Types {
+			sb.WriteString("\n    (" + decl.Name.Lexeme + " = " + typeSexpr(decl.Type) + ")")
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString("\n  (var")
+	for _, decl := range prog.Decls {
+		sb.WriteString("\n    " + declSexpr(decl))
+	}
+	sb.WriteString(")")
+	for _, sub := range prog.Subprograms {
+		sb.WriteString("\n  (" + sub.Tok.Lexeme + " " + sub.Name.Lexeme + " (")
+		for i, param := range sub.Params {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(declSexpr(param))
+		}
+		sb.WriteString(")")
+		if sub.IsFunction() {
+			sb.WriteString(" : " + typeSexpr(sub.Result))
+		}
+		sb.WriteString("\n    (var")
+		for _, decl := range sub.Decls {
+			sb.WriteString(" " + declSexpr(decl))
+		}
+		sb.WriteString(")\n    (begin")
+		for _, s := range sub.Body {
+			sb.WriteString("\n      " + stmtSexpr(s))
+		}
+		sb.WriteString("))")
+	}
+	sb.WriteString("\n  (begin")
+	for _, s := range prog.Body {
+		sb.WriteString("\n    ")
+		sb.WriteString(stmtSexpr(s))
+	}
+	sb.WriteString("))\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func declSexpr(decl *VarDecl) string {
+	names := make([]string, len(decl.Names))
+	for i, name := range decl.Names {
+		names[i] = name.Lexeme
+	}
+	return "(" + strings.Join(names, " ") + " : " + typeSexpr(decl.Type) + ")"
+}
+
+func typeSexpr(t *TypeSpec) string {
+	switch {
+	case t.Elem != nil:
+		return "(array " + exprSexpr(t.Low) + " " + exprSexpr(t.High) + " " + typeSexpr(t.Elem) + ")"
+	case t.Fields != nil:
+		res := "(record"
+		for _, field := range t.Fields {
+			res += " " + declSexpr(field)
+		}
+		return res + ")"
+	}
+	return t.Tok.Lexeme
+}
+
+func stmtSexpr(s Stmt) string {
+	switch s := s.(type) {
+	case *AssignStmt:
+		return "(as " + exprSexpr(s.Target) + " " + exprSexpr(s.Value) + ")"
+	case *IfStmt:
+		res := "(if " + exprSexpr(s.Cond) + " " + stmtSexpr(s.Then)
+		if s.Else != nil {
+			res += " " + stmtSexpr(s.Else)
+		}
+		return res + ")"
+	case *ForStmt:
+		res := "(for " + stmtSexpr(s.Init) + " (" + forDir(s) + " " + exprSexpr(s.To) + ")"
+		if s.Step != nil {
+			res += " (step " + exprSexpr(s.Step) + ")"
+		}
+		return res + " " + stmtSexpr(s.Body) + ")"
+	case *WhileStmt:
+		return "(while " + exprSexpr(s.Cond) + " " + stmtSexpr(s.Body) + ")"
+	case *RepeatStmt:
+		res := "(repeat"
+		for _, st := range s.Body {
+			res += " " + stmtSexpr(st)
+		}
+		return res + " (until " + exprSexpr(s.Cond) + "))"
+	case *BreakStmt:
+		return "(break)"
+	case *ContinueStmt:
+		return "(continue)"
+	case *CaseStmt:
+		res := "(case " + exprSexpr(s.Selector)
+		for _, branch := range s.Branches {
+			labels := make([]string, len(branch.Labels))
+			for i, label := range branch.Labels {
+				labels[i] = exprSexpr(label.Low)
+				if label.High != nil {
+					labels[i] = "(range " + labels[i] + " " + exprSexpr(label.High) + ")"
+				}
+			}
+			res += " ((" + strings.Join(labels, " ") + ") " + stmtSexpr(branch.Body) + ")"
+		}
+		if s.Else != nil {
+			res += " (else " + stmtSexpr(s.Else) + ")"
+		}
+		return res + ")"
+	case *ReadStmt:
+		res := "(read"
+		for _, target := range s.Targets {
+			res += " " + exprSexpr(target)
+		}
+		return res + ")"
+	case *WriteStmt:
+		res := "(write"
+		for _, arg := range s.Args {
+			res += " " + exprSexpr(arg)
+		}
+		return res + ")"
+	case *CompoundStmt:
+		res := "(block"
+		if len(s.Decls) > 0 {
+			res += " (var"
+			for _, decl := range s.Decls {
+				res += " " + declSexpr(decl)
+			}
+			res += ")"
+		}
+		for _, st := range s.Body {
+			res += " " + stmtSexpr(st)
+		}
+		return res + ")"
+	case *CallStmt:
+		return exprSexpr(s.Call)
+	case *ReturnStmt:
+		if s.Value != nil {
+			return "(return " + exprSexpr(s.Value) + ")"
+		}
+		return "(return)"
+	}
+	return "()"
+}
+
+func exprSexpr(e Expr) string {
+	switch e := e.(type) {
+	case *BinaryExpr:
+		return "(" + e.Op.Lexeme + " " + exprSexpr(e.Left) + " " + exprSexpr(e.Right) + ")"
+	case *UnaryExpr:
+		return "(" + e.Op.Lexeme + " " + exprSexpr(e.X) + ")"
+	case *IndexExpr:
+		return "(index " + exprSexpr(e.X) + " " + exprSexpr(e.Index) + ")"
+	case *FieldExpr:
+		return "(field " + exprSexpr(e.X) + " " + e.Name.Lexeme + ")"
+	case *CallExpr:
+		res := "(call " + e.Name.Lexeme
+		for _, arg := range e.Args {
+			res += " " + exprSexpr(arg)
+		}
+		return res + ")"
+	}
+	return e.Pos().Lexeme
+}
+
+// Запись дерева в формате Graphviz DOT
+func writeProgramDOT(w io.Writer, prog *Program) error {
+	g := &dotGraph{}
+	g.sb.WriteString("digraph AST {\n  node [shape=box, fontname=\"monospace\"];\n")
+	root := g.node("Program", prog.Tok)
+	for _, name := range prog.Uses {
+		g.edge(root, g.node("Uses "+name.Lexeme, name), "")
+	}
+	for _, decl := range prog.Consts {
+		id := g.node("ConstDecl "+decl.Name.Lexeme, decl.Pos())
+		g.edge(root, id, "")
+		g.edge(id, g.expr(decl.Value), "value")
+	}
+	for _, decl := range prog.Types {
+		g.edge(root, g.node("TypeDecl "+decl.Name.Lexeme+" = "+typeString(decl.Type), decl.Pos()), "")
+	}
+	for _, decl := range prog.Decls {
+		g.edge(root, g.node("VarDecl "+declString(decl), decl.Pos()), "")
+	}
+	for _, sub := range prog.Subprograms {
+		id := g.node(subprogramHeader(sub), sub.Pos())
+		g.edge(root, id, "")
+		for _, decl := range sub.Decls {
+			g.edge(id, g.node("VarDecl "+declString(decl), decl.Pos()), "")
+		}
+		for _, s := range sub.Body {
+			g.edge(id, g.stmt(s), "")
+		}
+	}
+	for _, s := range prog.Body {
+		g.edge(root, g.stmt(s), "")
+	}
+	g.sb.WriteString("}\n")
+	_, err := io.WriteString(w, g.sb.String())
+	return err
+}
+
+type dotGraph struct {
+	sb    strings.Builder
+	count int
+}
+
+func (g *dotGraph) node(label string, pos Token) string {
+	g.count++
+	id := "n" + strconv.Itoa(g.count)
+	fmt.Fprintf(&g.sb, "  %s [label=%s];\n", id, strconv.Quote(fmt.Sprintf("%s\n%d:%d", label, pos.LineNum, pos.ColNum)))
+	return id
+}
+
+func (g *dotGraph) edge(from, to, label string) {
+	if label == "" {
+		fmt.Fprintf(&g.sb, "  %s -> %s;\n", from, to)
+	} else {
+		fmt.Fprintf(&g.sb, "  %s -> %s [label=%s];\n", from, to, strconv.Quote(label))
+	}
+}
+
+func (g *dotGraph) stmt(s Stmt) string {
+	switch s := s.(type) {
+	case *AssignStmt:
+		id := g.node("Assign "+exprString(s.Target), s.Pos())
+		g.edge(id, g.expr(s.Value), "value")
+		return id
+	case *IfStmt:
+		id := g.node("If", s.Pos())
+		g.edge(id, g.expr(s.Cond), "cond")
+		g.edge(id, g.stmt(s.Then), "then")
+		if s.Else != nil {
+			g.edge(id, g.stmt(s.Else), "else")
+		}
+		return id
+	case *ForStmt:
+		id := g.node("For", s.Pos())
+		g.edge(id, g.stmt(s.Init), "init")
+		g.edge(id, g.expr(s.To), forDir(s))
+		if s.Step != nil {
+			g.edge(id, g.expr(s.Step), "step")
+		}
+		g.edge(id, g.stmt(s.Body), "body")
+		return id
+	case *WhileStmt:
+		id := g.node("While", s.Pos())
+		g.edge(id, g.expr(s.Cond), "cond")
+		g.edge(id, g.stmt(s.Body), "body")
+		return id
+	case *RepeatStmt:
+		id := g.node("Repeat", s.Pos())
+		for _, st := range s.Body {
+			g.edge(id, g.stmt(st), "")
+		}
+		g.edge(id, g.expr(s.Cond), "until")
+		return id
+	case *BreakStmt:
+		return g.node("Break", s.Pos())
+	case *ContinueStmt:
+		return g.node("Continue", s.Pos())
+	case *CaseStmt:
+		id := g.node("Case", s.Pos())
+		g.edge(id, g.expr(s.Selector), "selector")
+		for _, branch := range s.Branches {
+			b := g.node("Branch "+caseLabelsString(branch.Labels), branch.Pos())
+			g.edge(b, g.stmt(branch.Body), "")
+			g.edge(id, b, "")
+		}
+		if s.Else != nil {
+			g.edge(id, g.stmt(s.Else), "else")
+		}
+		return id
+	case *ReadStmt:
+		return g.node("Read "+exprList(s.Targets), s.Pos())
+	case *WriteStmt:
+		id := g.node("Write", s.Pos())
+		for _, arg := range s.Args {
+			g.edge(id, g.expr(arg), "")
+		}
+		return id
+	case *CompoundStmt:
+		id := g.node("Compound", s.Pos())
+		for _, decl := range s.Decls {
+			g.edge(id, g.node("VarDecl "+declString(decl), decl.Pos()), "")
+		}
+		for _, st := range s.Body {
+			g.edge(id, g.stmt(st), "")
+		}
+		return id
+	case *CallStmt:
+		return g.expr(s.Call)
+	case *ReturnStmt:
+		id := g.node("Return", s.Pos())
+		if s.Value != nil {
+			g.edge(id, g.expr(s.Value), "value")
+		}
+		return id
+	}
+	return g.node("?", s.Pos())
+}
+
+func (g *dotGraph) expr(e Expr) string {
+	switch e := e.(type) {
+	case *BinaryExpr:
+		id := g.node(e.Op.Lexeme, e.Op)
+		g.edge(id, g.expr(e.Left), "")
+		g.edge(id, g.expr(e.Right), "")
+		return id
+	case *UnaryExpr:
+		id := g.node(e.Op.Lexeme, e.Op)
+		g.edge(id, g.expr(e.X), "")
+		return id
+	case *IndexExpr:
+		id := g.node("Index", e.Pos())
+		g.edge(id, g.expr(e.X), "array")
+		g.edge(id, g.expr(e.Index), "index")
+		return id
+	case *FieldExpr:
+		id := g.node("Field "+e.Name.Lexeme, e.Name)
+		g.edge(id, g.expr(e.X), "record")
+		return id
+	case *CallExpr:
+		id := g.node("Call "+e.Name.Lexeme, e.Pos())
+		for _, arg := range e.Args {
+			g.edge(id, g.expr(arg), "")
+		}
+		return id
+	}
+	return g.node(e.Pos().Lexeme, e.Pos())
+}
+
+// Исправления диагностики в JSON: правки заданы смещением и длиной в байтах
+func fixesToJSON(fixes []Fix) []map[string]any {
+	var list []map[string]any
+	for _, fix := range fixes {
+		var edits []map[string]any
+		for _, edit := range fix.Edits {
+			edits = append(edits, map[string]any{"offset": edit.Offset, "length": edit.Length, "text": edit.NewText})
+		}
+		item := map[string]any{"message": fix.Message, "edits": edits}
+		if fix.Manual {
+			item["manual"] = true
+		}
+		list = append(list, item)
+	}
+	return list
+}
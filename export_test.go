@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Элемент списка, записанный как null, — ошибка чтения, а не паника
+func TestReadProgramJSONNullListItems(t *testing.T) {
+	intType := `{"kind":"Type","token":{"text":"int"}}`
+	cases := map[string]string{
+		"uses":        `"uses":[null]`,
+		"consts":      `"consts":[null]`,
+		"types":       `"types":[null]`,
+		"decls":       `"decls":[null]`,
+		"names":       `"decls":[{"kind":"VarDecl","names":[null],"type":` + intType + `}]`,
+		"fields":      `"types":[{"kind":"TypeDecl","name":{"text":"R"},"type":{"kind":"RecordType","fields":[null]}}]`,
+		"subprograms": `"subprograms":[null]`,
+		"params":      `"subprograms":[{"kind":"Procedure","name":{"text":"p"},"params":[null]}]`,
+		"branches":    `"body":[{"kind":"Case","selector":{"kind":"Number","token":{"text":"1d"}},"branches":[null]}]`,
+		"labels":      `"body":[{"kind":"Case","selector":{"kind":"Number","token":{"text":"1d"}},"branches":[{"kind":"CaseBranch","labels":[null],"body":[{"kind":"Break"}]}]}]`,
+	}
+	for list, fields := range cases {
+		src := `{"kind":"Program",` + fields + `}`
+		_, err := ReadProgramJSON(strings.NewReader(src), "prog.json")
+		if err == nil {
+			t.Errorf("%s: ожидалась ошибка", list)
+			continue
+		}
+		if want := "некорректный узел null в списке '" + list + "'"; err.Error() != want {
+			t.Errorf("%s: ошибка %q, ожидалось %q", list, err, want)
+		}
+	}
+}
+
+// Дерево из JSON проверяется так же, как текст программы: необъявленная
+// переменная — ошибка S012 с позицией из дерева
+func TestReadProgramJSONChecksNames(t *testing.T) {
+	src := "program var total : int;\nbegin\n    total as 1d;\n    write(total)\nend.\n"
+	tokens, err := LexFile("prog.txt", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := Syntax{tokens: tokens, pos: 0, file: "prog.txt"}
+	prog, err := parser.ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := writeProgramJSON(&buf, prog); err != nil {
+		t.Fatal(err)
+	}
+	tree := buf.String()
+	if _, err := ReadProgramJSON(strings.NewReader(tree), "prog.json"); err != nil {
+		t.Fatalf("дерево правильной программы не загружено: %v", err)
+	}
+
+	// Имя в write заменено на необъявленное
+	i := strings.LastIndex(tree, `"text": "total"`)
+	tree = tree[:i] + `"text": "totl"` + tree[i+len(`"text": "total"`):]
+	_, err = ReadProgramJSON(strings.NewReader(tree), "prog.json")
+	d := asDiagnostic(err)
+	if d == nil || d.Code != "S012" {
+		t.Fatalf("ошибка %v, ожидалась S012", err)
+	}
+	if d.Tok.Lexeme != "totl" || d.Tok.LineNum != 4 || d.Tok.ColNum != 11 {
+		t.Errorf("ошибка у '%s' на %d:%d, ожидалась у 'totl' на 4:11", d.Tok.Lexeme, d.Tok.LineNum, d.Tok.ColNum)
+	}
+	if len(d.Fixes) != 0 {
+		t.Errorf("исправления для дерева из JSON: %+v", d.Fixes)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Исправления (fix-it), прикрепляемые к диагностикам, и их применение (tfi fix)
+
+// Смещения правок отсчитываются в файле токена anchor. Если он пришёл из другого
+// файла, чем токен диагностики (например, из {$include}), исправление не прикрепляется:
+// правка попала бы не в тот файл
+func withFix(err error, anchor Token, fix Fix) error {
+	if d, ok := err.(*Diagnostic); ok && d.Tok.File == anchor.File {
+		d.Fixes = append(d.Fixes, fix)
+	}
+	return err
+}
+
+func hasFixes(err error) bool {
+	d, ok := err.(*Diagnostic)
+	return ok && len(d.Fixes) > 0
+}
+
+// Замена токена на другой текст
+func replaceFix(err error, tok Token, text string) error {
+	return withFix(err, tok, Fix{
+		Message: sprintf("заменить '%s' на '%s'", tok.Lexeme, text),
+		Edits:   []TextEdit{{Offset: tok.Offset, Length: len(tok.Lexeme), NewText: text}},
+	})
+}
+
+// Вставка пропущенного текста сразу после предыдущего токена
+func (p *Syntax) insertFix(err error, text string) error {
+	if p.pos == 0 {
+		return err
+	}
+	prev := p.tokens[p.pos-1]
+	return withFix(err, prev, Fix{
+		Message: sprintf("вставить '%s'", strings.TrimSpace(text)),
+		Edits:   []TextEdit{{Offset: prev.Offset + len(prev.Lexeme), NewText: text}},
+	})
+}
+
+// Объявление необъявленной переменной после последнего объявления в разделе var
+func (p *Syntax) declareFix(err error, tok Token, typ string) error {
+	if p.declEnd.Lexeme == "" {
+		return err
+	}
+	decl := tok.Lexeme + " : " + typ + ";"
+	if p.declStart.LineNum > p.varTok.LineNum {
+		// Объявления записаны на отдельных строках: новое получает тот же отступ
+		decl = "\n" + strings.Repeat(" ", p.declStart.ColNum-1) + decl
+	} else {
+		decl = " " + decl
+	}
+	return withFix(err, p.declEnd, Fix{
+		Message: sprintf("объявить переменную '%s' типа %s", tok.Lexeme, typ),
+		Edits:   []TextEdit{{Offset: p.declEnd.Offset + len(p.declEnd.Lexeme), NewText: decl}},
+	})
+}
+
+// Тип для новой переменной по присваиваемому значению (<имя> as <значение>)
+func (p *Syntax) guessType() string {
+	if p.pos+2 >= len(p.tokens) {
+		return "int"
+	}
+	value := p.tokens[p.pos+2]
+	switch {
+	case value.Type == TokenKeyword && (value.Lexeme == "true" || value.Lexeme == "false"):
+		return "bool"
+	case value.Type == TokenString:
+		return "string"
+	case value.Type == TokenChar:
+		return "char"
+	case value.Type == TokenNumber:
+		if value.Value.Kind == KindFloat {
+			return "float"
+		}
+	}
+	return "int"
+}
+
+// Применение правок к тексту. Правки не должны пересекаться.
+func applyEdits(src []byte, edits []TextEdit) ([]byte, error) {
+	sorted := append([]TextEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Offset < sorted[j].Offset
+	})
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range sorted {
+		if edit.Offset < last || edit.Offset+edit.Length > len(src) {
+			return nil, errorf("некорректная правка в позиции %d", edit.Offset)
+		}
+		out.Write(src[last:edit.Offset])
+		out.WriteString(edit.NewText)
+		last = edit.Offset + edit.Length
+	}
+	out.Write(src[last:])
+	return out.Bytes(), nil
+}
+
+// Сдвиг позиции offset после применения правок
+func shiftOffset(offset int, edits []TextEdit) int {
+	shifted := offset
+	for _, edit := range edits {
+		if edit.Offset <= offset {
+			shifted += len(edit.NewText) - edit.Length
+		}
+	}
+	return shifted
+}
+
+// Примененное исправление
+type appliedFix struct {
+	Diag *Diagnostic
+	Fix  Fix
+}
+
+// Наибольшее число раундов исправления
+const maxFixRounds = 100
+
+// Лексический и синтаксический анализ текста программы или модуля из файла name;
+// возвращает предупреждения и первую ошибку
+func checkSource(name string, src []byte) ([]*Diagnostic, error) {
+	tokens, err := LexFile(name, bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	parser := Syntax{tokens: tokens, pos: 0, file: name}
+	if isUnitSource(tokens) {
+		_, err = parser.ParseUnit()
+	} else {
+		_, err = parser.ParseProgram()
+	}
+	return parser.Warnings, err
+}
+
+// Первое исправление, которое можно применить без участия пользователя
+func firstAutomatic(fixes []Fix) (Fix, bool) {
+	for _, fix := range fixes {
+		if !fix.Manual {
+			return fix, true
+		}
+	}
+	return Fix{}, false
+}
+
+// Исправление программы или модуля: за один раунд применяется первое
+// автоматическое исправление первой ошибки, после чего текст разбирается
+// заново. Исправление сохраняется, только если разбор после него продвинулся
+// дальше.
+// Возвращает исправленный текст, список примененных исправлений и
+// оставшуюся ошибку (nil, если программа разбирается без ошибок).
+func fixSource(name string, src []byte) ([]byte, []appliedFix, error) {
+	var applied []appliedFix
+	_, err := checkSource(name, src)
+	for round := 0; err != nil && round < maxFixRounds; round++ {
+		d := asDiagnostic(err)
+		if d == nil || d.Tok.File != "" {
+			// Ошибку в подключённом модуле исправляет tfi fix для файла модуля
+			break
+		}
+		fix, ok := firstAutomatic(d.Fixes)
+		if !ok {
+			break
+		}
+		fixed, editErr := applyEdits(src, fix.Edits)
+		if editErr != nil {
+			break
+		}
+		_, newErr := checkSource(name, fixed)
+		if newErr != nil {
+			nd := asDiagnostic(newErr)
+			if nd == nil || nd.Tok.File != "" || nd.Tok.Offset <= shiftOffset(d.Tok.Offset, fix.Edits) {
+				// Исправление не продвинуло разбор: текст остаётся прежним
+				break
+			}
+		}
+		applied = append(applied, appliedFix{Diag: d, Fix: fix})
+		src, err = fixed, newErr
+	}
+	return src, applied, err
+}
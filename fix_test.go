@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Проверка файла name из каталога dir, в котором лежат files; возвращает диагностику ошибки
+func checkFiles(t *testing.T, dir, name string, files map[string]string) *Diagnostic {
+	t.Helper()
+	for file, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, name)
+	_, err := checkSource(path, []byte(files[name]))
+	d := asDiagnostic(err)
+	if d == nil {
+		t.Fatalf("ожидалась диагностика, получено %v", err)
+	}
+	return d
+}
+
+func TestInsertFix(t *testing.T) {
+	src := "program var\na : int\nbegin\n    a as 1d\nend.\n"
+	d := checkFiles(t, t.TempDir(), "main.txt", map[string]string{"main.txt": src})
+	if len(d.Fixes) != 1 {
+		t.Fatalf("исправлений %d, ожидалось 1", len(d.Fixes))
+	}
+	fixed, err := applyEdits([]byte(src), d.Fixes[0].Edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "program var\na : int;\nbegin\n    a as 1d\nend.\n"; string(fixed) != want {
+		t.Errorf("исправленный текст %q, ожидалось %q", fixed, want)
+	}
+}
+
+// Правка, привязанная к токену включённого файла, не предлагается для основного файла
+func TestFixAnchoredInIncludedFile(t *testing.T) {
+	cases := map[string]map[string]string{
+		"insert": {
+			"main.txt":  "program var\n{$include 'decls.txt'}\nbegin\n    a as 1d\nend.\n",
+			"decls.txt": "a : int;\nb : int\n",
+		},
+		"declare": {
+			"main.txt":  "program var\n{$include 'decls.txt'}\nbegin\n    zz as 1d\nend.\n",
+			"decls.txt": "a : int;\n",
+		},
+	}
+	for kind, files := range cases {
+		d := checkFiles(t, t.TempDir(), "main.txt", files)
+		if d.Tok.File != "" {
+			t.Fatalf("%s: ошибка во включённом файле %s", kind, d.Tok.File)
+		}
+		if len(d.Fixes) != 0 {
+			t.Errorf("%s: предложено исправление %+v", kind, d.Fixes)
+		}
+	}
+}
+
+// Для необъявленной переменной, похожей на объявленную, tfi fix добавляет
+// объявление, а замену имени только предлагает
+func TestFixDeclaresInsteadOfRename(t *testing.T) {
+	cases := map[string]struct {
+		files map[string]string
+		want  string
+	}{
+		"declare": {
+			map[string]string{"main.txt": "program var sum : int;\nbegin\n    sum2 as 1d;\n    write(sum2)\nend.\n"},
+			"program var sum : int; sum2 : int;\nbegin\n    sum2 as 1d;\n    write(sum2)\nend.\n",
+		},
+		// Объявления во включённом файле, добавить объявление в основной нельзя:
+		// текст не меняется
+		"rename only": {
+			map[string]string{
+				"main.txt":  "program var\n{$include 'decls.txt'}\nbegin\n    sum2 as 1d\nend.\n",
+				"decls.txt": "sum : int;\n",
+			},
+			"program var\n{$include 'decls.txt'}\nbegin\n    sum2 as 1d\nend.\n",
+		},
+	}
+	for name, c := range cases {
+		dir := t.TempDir()
+		d := checkFiles(t, dir, "main.txt", c.files)
+		if d.Help == "" || len(d.Fixes) == 0 || !d.Fixes[len(d.Fixes)-1].Manual {
+			t.Errorf("%s: замена на похожее имя не предложена: %+v", name, d.Fixes)
+		}
+		fixed, _, _ := fixSource(filepath.Join(dir, "main.txt"), []byte(c.files["main.txt"]))
+		if string(fixed) != c.want {
+			t.Errorf("%s: исправленный текст %q, ожидалось %q", name, fixed, c.want)
+		}
+	}
+}
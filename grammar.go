@@ -0,0 +1,630 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Грамматика языка в EBNF (tfi.ebnf) и её преобразование в продукции BNF:
+// группа с альтернативами, необязательная часть [ x ] и повторение { x }
+// заменяются вспомогательными нетерминалами с именами вида Statement.1.
+// По продукциям вычисляются множества FIRST и FOLLOW и строится таблица
+// LL(1)-анализатора (llparse.go).
+
+// Встроенная грамматика языка
+//
+//go:embed tfi.ebnf
+var builtinGrammar string
+
+// Классы токенов, которые в грамматике обозначаются именами со строчной буквы
+var grammarClasses = map[string]TokenType{
+	"ident":  TokenIdentifier,
+	"number": TokenNumber,
+	"string": TokenString,
+	"char":   TokenChar,
+}
+
+// Конец текста
+const endMarker = "$"
+
+// Продукция BNF: lhs → rhs; пустая правая часть — ε
+type production struct {
+	lhs  string
+	rhs  []string
+	line int // строка правила EBNF, из которого получена продукция
+}
+
+type grammar struct {
+	file     string
+	start    string
+	nonterms []string // нетерминалы в порядке определения (вспомогательные — после своего правила)
+	prods    []*production
+	byLHS    map[string][]int
+	terms    []string // терминалы в порядке первого появления
+	aux      map[string]int
+	unused   []string // правила, недостижимые из начального нетерминала
+	nullable map[string]bool
+	first    map[string]map[string]bool
+	follow   map[string]map[string]bool
+}
+
+// Терминал: слово в кавычках, класс токенов или конец текста
+func isTerminal(sym string) bool {
+	return sym[0] == '"' || sym == endMarker || (sym[0] >= 'a' && sym[0] <= 'z')
+}
+
+// Выражение EBNF
+type ebnfOp uint8
+
+const (
+	ebnfSym ebnfOp = iota // терминал или нетерминал
+	ebnfSeq               // последовательность (пустая — ε)
+	ebnfAlt               // альтернатива
+	ebnfOpt               // [ x ]
+	ebnfRep               // { x }
+)
+
+type ebnfNode struct {
+	op   ebnfOp
+	sym  string
+	subs []*ebnfNode
+	line int
+}
+
+// Альтернативы выражения; необязательная часть на месте альтернативы
+// раскрывается в свои альтернативы и ε
+func (n *ebnfNode) alternatives() []*ebnfNode {
+	switch n.op {
+	case ebnfAlt:
+		var out []*ebnfNode
+		for _, s := range n.subs {
+			out = append(out, s.alternatives()...)
+		}
+		return out
+	case ebnfOpt:
+		return append(n.subs[0].alternatives(), &ebnfNode{op: ebnfSeq})
+	}
+	return []*ebnfNode{n}
+}
+
+// Токен текста грамматики
+type ebnfToken struct {
+	text      string // имя, терминал в кавычках или знак = | . ( ) [ ] { }
+	line, col int
+}
+
+func scanEBNF(file, src string) ([]ebnfToken, error) {
+	var tokens []ebnfToken
+	line, col := 1, 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		switch {
+		case c == '\n':
+			line, col = line+1, 1
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '"':
+			end := strings.IndexAny(src[i+1:], "\"\n")
+			if end < 0 || src[i+1+end] != '"' {
+				return nil, errorf("%s:%d:%d: незакрытая строка", file, line, col)
+			}
+			if end == 0 {
+				return nil, errorf("%s:%d:%d: пустой терминал", file, line, col)
+			}
+			i += end + 2
+			tokens = append(tokens, ebnfToken{src[start:i], line, col})
+		case isLetter(c):
+			for i < len(src) && (isLetter(src[i]) || (src[i] >= '0' && src[i] <= '9') || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, ebnfToken{src[start:i], line, col})
+		case strings.IndexByte("=|.()[]{}", c) >= 0:
+			i++
+			tokens = append(tokens, ebnfToken{src[start:i], line, col})
+		default:
+			r := []rune(src[i:])[0]
+			return nil, errorf("%s:%d:%d: неожиданный символ '%c'", file, line, col, r)
+		}
+		col += len([]rune(src[start:i]))
+	}
+	return tokens, nil
+}
+
+// Разбор текста грамматики рекурсивным спуском
+type ebnfParser struct {
+	file   string
+	tokens []ebnfToken
+	pos    int
+}
+
+func (p *ebnfParser) peek() ebnfToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ebnfToken{}
+}
+
+func (p *ebnfParser) fail(tok ebnfToken, format string, args ...any) error {
+	if tok.text == "" {
+		return fmt.Errorf("%s: %s", p.file, sprintf(format, args...))
+	}
+	return fmt.Errorf("%s:%d:%d: %s", p.file, tok.line, tok.col, sprintf(format, args...))
+}
+
+func (p *ebnfParser) expect(text string) error {
+	tok := p.peek()
+	if tok.text != text {
+		return p.fail(tok, "ожидалось '%s', получено '%s'", text, tok.text)
+	}
+	p.pos++
+	return nil
+}
+
+// Выражение: последовательности через '|'
+func (p *ebnfParser) expr() (*ebnfNode, error) {
+	var alts []*ebnfNode
+	for {
+		seq, err := p.seq()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, seq)
+		if p.peek().text != "|" {
+			break
+		}
+		p.pos++
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return &ebnfNode{op: ebnfAlt, subs: alts}, nil
+}
+
+func (p *ebnfParser) seq() (*ebnfNode, error) {
+	seq := &ebnfNode{op: ebnfSeq}
+	for {
+		tok := p.peek()
+		switch {
+		case tok.text == "(" || tok.text == "[" || tok.text == "{":
+			p.pos++
+			sub, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			closing := map[string]string{"(": ")", "[": "]", "{": "}"}[tok.text]
+			err = p.expect(closing)
+			if err != nil {
+				return nil, err
+			}
+			switch tok.text {
+			case "[":
+				sub = &ebnfNode{op: ebnfOpt, subs: []*ebnfNode{sub}, line: tok.line}
+			case "{":
+				sub = &ebnfNode{op: ebnfRep, subs: []*ebnfNode{sub}, line: tok.line}
+			}
+			seq.subs = append(seq.subs, sub)
+		case tok.text != "" && (tok.text[0] == '"' || isLetter(tok.text[0])):
+			// Имя, за которым следует '=', начинает следующее правило
+			if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "=" {
+				return nil, p.fail(tok, "ожидалось '.' в конце правила")
+			}
+			p.pos++
+			seq.subs = append(seq.subs, &ebnfNode{op: ebnfSym, sym: tok.text, line: tok.line})
+		default:
+			if len(seq.subs) == 1 {
+				return seq.subs[0], nil
+			}
+			return seq, nil
+		}
+	}
+}
+
+// Разбор грамматики и построение продукций BNF
+func parseGrammar(file, src string) (*grammar, error) {
+	tokens, err := scanEBNF(file, src)
+	if err != nil {
+		return nil, err
+	}
+	p := &ebnfParser{file: file, tokens: tokens}
+	g := &grammar{file: file, byLHS: make(map[string][]int), aux: make(map[string]int)}
+	rules := make(map[string]*ebnfNode)
+	var order []string
+	lines := make(map[string]int)
+	for p.pos < len(p.tokens) {
+		name := p.peek()
+		if !isLetter(name.text[0]) || isTerminal(name.text) {
+			return nil, p.fail(name, "ожидалось имя правила с заглавной буквы, получено '%s'", name.text)
+		}
+		p.pos++
+		err := p.expect("=")
+		if err != nil {
+			return nil, err
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(".")
+		if err != nil {
+			return nil, err
+		}
+		if rules[name.text] != nil {
+			return nil, p.fail(name, "повторное определение правила '%s'", name.text)
+		}
+		rules[name.text] = e
+		order = append(order, name.text)
+		lines[name.text] = name.line
+	}
+	if len(order) == 0 {
+		return nil, errorf("%s: нет ни одного правила", file)
+	}
+	g.start = order[0]
+
+	for _, name := range order {
+		g.define(name, rules[name], lines[name])
+	}
+
+	// Проверка символов правых частей
+	seen := make(map[string]bool)
+	for _, prod := range g.prods {
+		for _, sym := range prod.rhs {
+			if !isTerminal(sym) {
+				if g.byLHS[sym] == nil {
+					return nil, errorf("%s:%d: неизвестное правило '%s'", file, prod.line, sym)
+				}
+				continue
+			}
+			if sym[0] == '"' {
+				word := sym[1 : len(sym)-1]
+				if !isKeyword(word) && !isOperator(word) && !contains(delimiters, word) {
+					return nil, errorf("%s:%d: %s не является ключевым словом, операцией или разделителем", file, prod.line, sym)
+				}
+			} else if _, ok := grammarClasses[sym]; !ok {
+				return nil, errorf("%s:%d: неизвестный класс токенов '%s'", file, prod.line, sym)
+			}
+			if !seen[sym] {
+				seen[sym] = true
+				g.terms = append(g.terms, sym)
+			}
+		}
+	}
+	g.unused = g.unreachable(order)
+	g.computeSets()
+	return g, nil
+}
+
+// Продукции нетерминала lhs для выражения e
+func (g *grammar) define(lhs string, e *ebnfNode, line int) {
+	g.nonterms = append(g.nonterms, lhs)
+	alts := e.alternatives()
+	if e.op == ebnfRep {
+		// Повторение: lhs → x lhs | ε
+		alts = append(e.subs[0].alternatives(), &ebnfNode{op: ebnfSeq})
+	}
+	var prods []*production
+	for range alts {
+		prod := &production{lhs: lhs, line: line}
+		g.byLHS[lhs] = append(g.byLHS[lhs], len(g.prods))
+		g.prods = append(g.prods, prod)
+		prods = append(prods, prod)
+	}
+	for i, alt := range alts {
+		prods[i].rhs = g.sequence(lhs, alt, line)
+		if e.op == ebnfRep && i < len(alts)-1 {
+			prods[i].rhs = append(prods[i].rhs, lhs)
+		}
+	}
+}
+
+// Символы последовательности; вложенные конструкции получают вспомогательные нетерминалы
+func (g *grammar) sequence(lhs string, n *ebnfNode, line int) []string {
+	switch n.op {
+	case ebnfSym:
+		return []string{n.sym}
+	case ebnfSeq:
+		rhs := []string{}
+		for _, s := range n.subs {
+			rhs = append(rhs, g.sequence(lhs, s, line)...)
+		}
+		return rhs
+	}
+	base, _, _ := strings.Cut(lhs, ".")
+	g.aux[base]++
+	name := fmt.Sprintf("%s.%d", base, g.aux[base])
+	g.define(name, n, line)
+	return []string{name}
+}
+
+// Правила, недостижимые из начального нетерминала
+func (g *grammar) unreachable(order []string) []string {
+	reached := map[string]bool{g.start: true}
+	queue := []string{g.start}
+	for len(queue) > 0 {
+		nt := queue[0]
+		queue = queue[1:]
+		for _, i := range g.byLHS[nt] {
+			for _, sym := range g.prods[i].rhs {
+				if !isTerminal(sym) && !reached[sym] {
+					reached[sym] = true
+					queue = append(queue, sym)
+				}
+			}
+		}
+	}
+	var out []string
+	for _, name := range order {
+		if !reached[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// Вычисление nullable, FIRST и FOLLOW до неподвижной точки
+func (g *grammar) computeSets() {
+	g.nullable = make(map[string]bool)
+	g.first = make(map[string]map[string]bool)
+	g.follow = make(map[string]map[string]bool)
+	for _, nt := range g.nonterms {
+		g.first[nt] = make(map[string]bool)
+		g.follow[nt] = make(map[string]bool)
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, prod := range g.prods {
+			first, nullable := g.firstOf(prod.rhs)
+			for t := range first {
+				if !g.first[prod.lhs][t] {
+					g.first[prod.lhs][t] = true
+					changed = true
+				}
+			}
+			if nullable && !g.nullable[prod.lhs] {
+				g.nullable[prod.lhs] = true
+				changed = true
+			}
+		}
+	}
+
+	g.follow[g.start][endMarker] = true
+	for changed := true; changed; {
+		changed = false
+		for _, prod := range g.prods {
+			for i, sym := range prod.rhs {
+				if isTerminal(sym) {
+					continue
+				}
+				first, nullable := g.firstOf(prod.rhs[i+1:])
+				if nullable {
+					for t := range g.follow[prod.lhs] {
+						first[t] = true
+					}
+				}
+				for t := range first {
+					if !g.follow[sym][t] {
+						g.follow[sym][t] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// FIRST цепочки символов и допускает ли она пустую строку
+func (g *grammar) firstOf(rhs []string) (map[string]bool, bool) {
+	first := make(map[string]bool)
+	for _, sym := range rhs {
+		if isTerminal(sym) {
+			first[sym] = true
+			return first, false
+		}
+		for t := range g.first[sym] {
+			first[t] = true
+		}
+		if !g.nullable[sym] {
+			return first, false
+		}
+	}
+	return first, true
+}
+
+// Цикл левой рекурсии A ⇒ … ⇒ A α (через нетерминалы, допускающие пустую
+// строку, в начале правой части) или nil; анализатор LL(1) на такой
+// грамматике не заканчивает разбор
+func (g *grammar) leftRecursion() []string {
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(nt string) []string
+	visit = func(nt string) []string {
+		state[nt] = active
+		path = append(path, nt)
+		for _, i := range g.byLHS[nt] {
+			for _, sym := range g.prods[i].rhs {
+				if isTerminal(sym) {
+					break
+				}
+				switch state[sym] {
+				case active:
+					for j, name := range path {
+						if name == sym {
+							return append(append([]string(nil), path[j:]...), sym)
+						}
+					}
+				case unvisited:
+					cycle := visit(sym)
+					if cycle != nil {
+						return cycle
+					}
+				}
+				if !g.nullable[sym] {
+					break
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[nt] = done
+		return nil
+	}
+	for _, nt := range g.nonterms {
+		if state[nt] == unvisited {
+			cycle := visit(nt)
+			if cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Терминалы множества в порядке их появления в грамматике
+func (g *grammar) sortedTerms(set map[string]bool) []string {
+	var out []string
+	for _, t := range g.allTerms() {
+		if set[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Терминалы грамматики и конец текста
+func (g *grammar) allTerms() []string {
+	return append(append([]string(nil), g.terms...), endMarker)
+}
+
+// Запись продукции: Lhs → a B c (ε для пустой правой части)
+func (p *production) String() string {
+	if len(p.rhs) == 0 {
+		return p.lhs + " → ε"
+	}
+	return p.lhs + " → " + strings.Join(p.rhs, " ")
+}
+
+// Таблица LL(1): для нетерминала и терминала — номер продукции
+type llTable struct {
+	g         *grammar
+	cells     map[string]map[string]int
+	conflicts []llConflict
+}
+
+// Несколько продукций в одной клетке таблицы; выбирается первая из них
+type llConflict struct {
+	nonterm, term string
+	prods         []int
+}
+
+func buildLLTable(g *grammar) *llTable {
+	t := &llTable{g: g, cells: make(map[string]map[string]int)}
+	candidates := make(map[string]map[string][]int)
+	for _, nt := range g.nonterms {
+		t.cells[nt] = make(map[string]int)
+		candidates[nt] = make(map[string][]int)
+	}
+	for i, prod := range g.prods {
+		first, nullable := g.firstOf(prod.rhs)
+		if nullable {
+			for term := range g.follow[prod.lhs] {
+				first[term] = true
+			}
+		}
+		for term := range first {
+			candidates[prod.lhs][term] = append(candidates[prod.lhs][term], i)
+		}
+	}
+	for _, nt := range g.nonterms {
+		for _, term := range g.allTerms() {
+			prods := candidates[nt][term]
+			if len(prods) == 0 {
+				continue
+			}
+			t.cells[nt][term] = prods[0]
+			if len(prods) > 1 {
+				t.conflicts = append(t.conflicts, llConflict{nonterm: nt, term: term, prods: prods})
+			}
+		}
+	}
+	return t
+}
+
+// Сводка по грамматике и конфликты LL(1)
+func (t *llTable) printSummary(w io.Writer) {
+	g := t.g
+	aux := 0
+	for _, n := range g.aux {
+		aux += n
+	}
+	fmt.Fprintf(w, tr("%s: нетерминалов: %d (вспомогательных: %d), терминалов: %d, продукций: %d; конфликтов LL(1): %d\n"),
+		g.file, len(g.nonterms), aux, len(g.terms), len(g.prods), len(t.conflicts))
+	for _, name := range g.unused {
+		fmt.Fprintf(w, tr("%s: правило %s недостижимо из %s\n"), g.file, name, g.start)
+	}
+	for _, c := range t.conflicts {
+		fmt.Fprintf(w, tr("%s:%d: конфликт LL(1) в %s по %s:\n"), g.file, g.prods[c.prods[0]].line, c.nonterm, c.term)
+		for _, i := range c.prods {
+			fmt.Fprintf(w, "    %3d  %s\n", i+1, g.prods[i])
+		}
+		fmt.Fprintf(w, tr("    выбрана продукция %d\n"), c.prods[0]+1)
+	}
+}
+
+// Продукции BNF с номерами
+func (g *grammar) printBNF(w io.Writer) {
+	for i, prod := range g.prods {
+		fmt.Fprintf(w, "%4d  %s\n", i+1, prod)
+	}
+}
+
+// Множества FIRST или FOLLOW всех нетерминалов
+func (g *grammar) printSets(w io.Writer, name string, sets map[string]map[string]bool) {
+	width := 0
+	for _, nt := range g.nonterms {
+		width = max(width, len(nt))
+	}
+	for _, nt := range g.nonterms {
+		terms := g.sortedTerms(sets[nt])
+		if name == "FIRST" && g.nullable[nt] {
+			terms = append(terms, "ε")
+		}
+		fmt.Fprintf(w, "%-*s = { %s }\n", width+len(name)+2, name+"("+nt+")", strings.Join(terms, " "))
+	}
+}
+
+// Таблица LL(1) по строкам: для каждого нетерминала — терминал и номер продукции
+func (t *llTable) printTable(w io.Writer) {
+	for _, nt := range t.g.nonterms {
+		var cells []string
+		for _, term := range t.g.allTerms() {
+			if i, ok := t.cells[nt][term]; ok {
+				cells = append(cells, fmt.Sprintf("%s:%d", term, i+1))
+			}
+		}
+		fmt.Fprintf(w, "%s\n    %s\n", nt, strings.Join(cells, "  "))
+	}
+}
+
+// Терминалы строки таблицы: токены, с которых может продолжаться разбор нетерминала
+func (t *llTable) expected(nt string) []string {
+	var out []string
+	for _, term := range t.g.allTerms() {
+		if _, ok := t.cells[nt][term]; ok {
+			out = append(out, term)
+		}
+	}
+	return out
+}
@@ -0,0 +1,983 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Виды значений времени выполнения
+type ValueKind int
+
+const (
+	KindInt ValueKind = iota
+	KindFloat
+	KindBool
+	KindArray
+	KindRecord
+	KindString
+	KindChar
+)
+
+// Значение времени выполнения
+type Value struct {
+	Kind  ValueKind
+	Int   int64
+	Big   *big.Int // целое вне диапазона int64 (только в режиме -bigint); иначе nil
+	Float float64
+	Bool  bool
+	Str   string   // строка или символ
+	Elems []Value  // элементы массива или значения полей записи
+	Low   int64    // нижняя граница индекса массива
+	Names []string // имена полей записи
+}
+
+func (v Value) String() string {
+	switch v.Kind {
+	case KindInt:
+		if v.Big != nil {
+			return v.Big.String()
+		}
+		return strconv.FormatInt(v.Int, 10)
+	case KindFloat:
+		return strconv.FormatFloat(v.Float, 'g', -1, 64)
+	case KindBool:
+		return strconv.FormatBool(v.Bool)
+	case KindString, KindChar:
+		return v.Str
+	case KindArray:
+		elems := make([]string, len(v.Elems))
+		for i, elem := range v.Elems {
+			elems[i] = elem.String()
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case KindRecord:
+		fields := make([]string, len(v.Elems))
+		for i, elem := range v.Elems {
+			fields[i] = v.Names[i] + " = " + elem.String()
+		}
+		return "(" + strings.Join(fields, ", ") + ")"
+	default:
+		return "?"
+	}
+}
+
+// Переменная программы
+type Variable struct {
+	Name  string
+	Type  *TypeSpec
+	Value Value
+}
+
+// Кадр выполнения: тело программы или вызов подпрограммы
+type Frame struct {
+	Name    string
+	Vars    map[string]*Variable
+	Order   []string
+	Current Stmt
+	Blocks  []*Frame // блоки с локальными переменными; последний — самый внутренний
+	Unit    *Unit    // модуль подпрограммы: его переменные служат глобальными для кадра
+}
+
+// Ошибка времени выполнения
+type RuntimeError struct {
+	Tok Token
+	Msg string
+}
+
+func (e *RuntimeError) Error() string {
+	return sprintf("Ошибка выполнения: %s на строке %d столбце %d", e.Msg, e.Tok.LineNum, e.Tok.ColNum)
+}
+
+// Выход из подпрограммы по оператору return; передаётся вверх как ошибка
+// до ближайшего вызова
+type returnSignal struct {
+	Tok   Token
+	Value *Value
+}
+
+func (r *returnSignal) Error() string {
+	return "return"
+}
+
+// Выход из цикла (break) или переход к следующей итерации (continue);
+// передаётся вверх как ошибка до ближайшего цикла
+type loopSignal struct {
+	Tok   Token
+	Break bool
+}
+
+func (l *loopSignal) Error() string {
+	return l.Tok.Lexeme
+}
+
+// Выполнение тела цикла; возвращает true, если цикл нужно прервать.
+// continue завершает только текущую итерацию
+func (in *Interpreter) execLoopBody(body ...Stmt) (bool, error) {
+	err := in.execList(body)
+	if sig, ok := err.(*loopSignal); ok {
+		return sig.Break, nil
+	}
+	return false, err
+}
+
+// Сигнал break или continue, вышедший за пределы цикла (возможно только
+// в дереве, загруженном из JSON), превращается в ошибку выполнения
+func loopOutside(err error) error {
+	if sig, ok := err.(*loopSignal); ok {
+		return &RuntimeError{Tok: sig.Tok, Msg: sprintf("оператор %s вне цикла", sig.Tok.Lexeme)}
+	}
+	return err
+}
+
+// Наибольшая глубина вложенности вызовов
+const maxCallDepth = 10000
+
+// Наибольшее число элементов массива
+const maxArrayLen = 1 << 24
+
+// Интерпретатор программы
+type Interpreter struct {
+	in     *bufio.Reader
+	out    io.Writer
+	Frames []*Frame
+	subs   map[string]*Subprogram
+	units  map[*Unit]*Frame // переменные модулей
+
+	// Вызывается перед выполнением каждого оператора (используется отладчиком).
+	// Ненулевая ошибка прерывает выполнение программы.
+	OnStmt func(s Stmt) error
+}
+
+func NewInterpreter(in io.Reader, out io.Writer) *Interpreter {
+	return &Interpreter{in: bufio.NewReader(in), out: out}
+}
+
+func zeroValue(typeName string) Value {
+	switch typeName {
+	case "float":
+		return Value{Kind: KindFloat}
+	case "bool":
+		return Value{Kind: KindBool}
+	case "string":
+		return Value{Kind: KindString}
+	case "char":
+		return Value{Kind: KindChar, Str: "\x00"}
+	default:
+		return Value{Kind: KindInt}
+	}
+}
+
+// Число элементов массива с границами low <= high; ok == false, если их больше
+// maxArrayLen. Разность границ считается без знака: она может не помещаться в int64
+func arrayLen(low, high int64) (n int, ok bool) {
+	dist := uint64(high) - uint64(low)
+	if dist >= maxArrayLen {
+		return 0, false
+	}
+	return int(dist) + 1, true
+}
+
+// Число элементов массива для сообщения (может не помещаться и в uint64)
+func arrayLenText(low, high int64) string {
+	n := new(big.Int).Sub(big.NewInt(high), big.NewInt(low))
+	return n.Add(n, big.NewInt(1)).String()
+}
+
+// Начальное значение переменной типа t; элементы массива и поля записи
+// получают начальные значения своих типов
+func newValue(t *TypeSpec) (Value, error) {
+	named := t
+	t = t.Underlying()
+	if t.IsRecord() {
+		rec := Value{Kind: KindRecord, Names: t.FieldNames()}
+		for _, decl := range t.Fields {
+			for range decl.Names {
+				val, err := newValue(decl.Type)
+				if err != nil {
+					return Value{}, err
+				}
+				rec.Elems = append(rec.Elems, val)
+			}
+		}
+		return rec, nil
+	}
+	if !t.IsArray() {
+		return zeroValue(t.Tok.Lexeme), nil
+	}
+	low, high, ok := arrayBounds(t)
+	if !ok || low > high {
+		return Value{}, &RuntimeError{Tok: named.Tok, Msg: sprintf("некорректный тип массива %s", typeString(t))}
+	}
+	n, ok := arrayLen(low, high)
+	if !ok {
+		return Value{}, &RuntimeError{Tok: named.Tok, Msg: sprintf("массив слишком велик: %s элементов (не более %d)", arrayLenText(low, high), maxArrayLen)}
+	}
+	arr := Value{Kind: KindArray, Low: low, Elems: make([]Value, n)}
+	for i := range arr.Elems {
+		elem, err := newValue(t.Elem)
+		if err != nil {
+			return Value{}, err
+		}
+		arr.Elems[i] = elem
+	}
+	return arr, nil
+}
+
+// Выполнение программы
+func (in *Interpreter) Run(prog *Program) error {
+	in.subs = make(map[string]*Subprogram)
+	for _, sub := range prog.Subprograms {
+		in.subs[sub.Name.Lexeme] = sub
+	}
+	in.units = make(map[*Unit]*Frame)
+	for _, unit := range prog.Units {
+		frame := &Frame{Name: unit.Name.Lexeme, Vars: make(map[string]*Variable), Unit: unit}
+		err := frame.declare(unit.Decls)
+		if err != nil {
+			return err
+		}
+		in.units[unit] = frame
+	}
+	frame := &Frame{Name: "program", Vars: make(map[string]*Variable)}
+	err := frame.declare(prog.Decls)
+	if err != nil {
+		return err
+	}
+	in.Frames = append(in.Frames, frame)
+	defer func() { in.Frames = in.Frames[:len(in.Frames)-1] }()
+
+	err = in.execList(prog.Body)
+	if _, ok := err.(*returnSignal); ok {
+		return nil
+	}
+	return loopOutside(err)
+}
+
+// Добавление переменных в кадр
+func (f *Frame) declare(decls []*VarDecl) error {
+	for _, decl := range decls {
+		for _, name := range decl.Names {
+			val, err := newValue(decl.Type)
+			if err != nil {
+				return err
+			}
+			f.Vars[name.Lexeme] = &Variable{Name: name.Lexeme, Type: decl.Type, Value: val}
+			f.Order = append(f.Order, name.Lexeme)
+		}
+	}
+	return nil
+}
+
+// Вызов подпрограммы: аргументы вычисляются в кадре вызывающего,
+// параметры и локальные переменные создаются в новом кадре
+func (in *Interpreter) call(c *CallExpr) (*Value, error) {
+	sub, ok := c.Sub, c.Sub != nil
+	if !ok {
+		sub, ok = in.subs[c.Name.Lexeme]
+	}
+	if ok && sub.Impl != nil {
+		// Подпрограмма из интерфейса модуля
+		sub = sub.Impl
+	}
+	if !ok {
+		if b, ok := builtins[c.Name.Lexeme]; ok {
+			return in.callBuiltin(c, b)
+		}
+		return nil, &RuntimeError{Tok: c.Name, Msg: sprintf("необъявленная подпрограмма '%s'", c.Name.Lexeme)}
+	}
+	if len(in.Frames) > maxCallDepth {
+		return nil, &RuntimeError{Tok: c.Name, Msg: sprintf("слишком глубокая рекурсия: более %d вложенных вызовов", maxCallDepth)}
+	}
+	frame := &Frame{Name: sub.Name.Lexeme, Vars: make(map[string]*Variable), Unit: sub.Unit}
+	err := frame.declare(sub.Params)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range sub.ParamNames() {
+		if i >= len(c.Args) {
+			break
+		}
+		val, err := in.eval(c.Args[i])
+		if err != nil {
+			return nil, err
+		}
+		val, err = convert(name, val, sub.ParamType(i))
+		if err != nil {
+			return nil, err
+		}
+		frame.Vars[name.Lexeme].Value = val
+	}
+	err = frame.declare(sub.Decls)
+	if err != nil {
+		return nil, err
+	}
+
+	in.Frames = append(in.Frames, frame)
+	defer func() { in.Frames = in.Frames[:len(in.Frames)-1] }()
+
+	err = in.execList(sub.Body)
+	if ret, ok := err.(*returnSignal); ok {
+		if sub.IsFunction() && ret.Value != nil {
+			val, err := convert(sub.Name, *ret.Value, sub.Result)
+			if err != nil {
+				return nil, err
+			}
+			return &val, nil
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, loopOutside(err)
+	}
+	if sub.IsFunction() {
+		return nil, &RuntimeError{Tok: sub.End, Msg: sprintf("функция '%s' завершилась без возврата значения", sub.Name.Lexeme)}
+	}
+	return nil, nil
+}
+
+func (in *Interpreter) frame() *Frame {
+	return in.Frames[len(in.Frames)-1]
+}
+
+// Глобальные переменные для кадра: переменные модуля для подпрограммы модуля,
+// иначе переменные программы
+func (in *Interpreter) globals(frame *Frame) *Frame {
+	if frame.Unit != nil && in.units[frame.Unit] != nil {
+		return in.units[frame.Unit]
+	}
+	return in.Frames[0]
+}
+
+// Поиск переменной: сначала в текущем кадре, затем среди глобальных переменных
+func (in *Interpreter) lookup(tok Token) (*Variable, error) {
+	frame := in.frame()
+	for i := len(frame.Blocks) - 1; i >= 0; i-- {
+		v, ok := frame.Blocks[i].Vars[tok.Lexeme]
+		if ok {
+			return v, nil
+		}
+	}
+	v, ok := frame.Vars[tok.Lexeme]
+	if !ok {
+		v, ok = in.globals(frame).Vars[tok.Lexeme]
+	}
+	if !ok {
+		return nil, &RuntimeError{Tok: tok, Msg: sprintf("необъявленная переменная '%s'", tok.Lexeme)}
+	}
+	return v, nil
+}
+
+// Место хранения переменной или элемента массива и его тип
+func (in *Interpreter) ref(e Expr) (*Value, *TypeSpec, error) {
+	switch e := e.(type) {
+	case *Ident:
+		if e.Const != nil {
+			return nil, nil, &RuntimeError{Tok: e.Tok, Msg: sprintf("нельзя изменить константу '%s'", e.Tok.Lexeme)}
+		}
+		v, err := in.lookup(e.Tok)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &v.Value, v.Type, nil
+	case *IndexExpr:
+		arr, t, err := in.ref(e.X)
+		if err != nil {
+			return nil, nil, err
+		}
+		if arr.Kind != KindArray || !t.IsArray() {
+			return nil, nil, &RuntimeError{Tok: e.X.Pos(), Msg: sprintf("'%s' не является массивом", exprString(e.X))}
+		}
+		index, err := in.eval(e.Index)
+		if err != nil {
+			return nil, nil, err
+		}
+		if index.Kind != KindInt {
+			return nil, nil, &RuntimeError{Tok: e.Index.Pos(), Msg: sprintf("индекс массива должен быть целым, получено %s", index)}
+		}
+		i := index.Int - arr.Low
+		if index.Big != nil || index.Int < arr.Low || i >= int64(len(arr.Elems)) {
+			high := arr.Low + int64(len(arr.Elems)) - 1
+			return nil, nil, &RuntimeError{Tok: e.Index.Pos(), Msg: sprintf("индекс %d вне границ массива '%s' [%d..%d]", index.bigInt(), exprString(e.X), arr.Low, high)}
+		}
+		return &arr.Elems[i], t.Underlying().Elem, nil
+	case *FieldExpr:
+		rec, t, err := in.ref(e.X)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rec.Kind != KindRecord || !t.IsRecord() {
+			return nil, nil, &RuntimeError{Tok: e.X.Pos(), Msg: sprintf("'%s' не является записью", exprString(e.X))}
+		}
+		i, ft := t.Field(e.Name.Lexeme)
+		if i < 0 || i >= len(rec.Elems) {
+			return nil, nil, &RuntimeError{Tok: e.Name, Msg: sprintf("запись '%s' не содержит поля '%s'", exprString(e.X), e.Name.Lexeme)}
+		}
+		return &rec.Elems[i], ft, nil
+	}
+	return nil, nil, &RuntimeError{Tok: e.Pos(), Msg: tr("неподдерживаемое выражение")}
+}
+
+// Токен для сообщений о переменной; для элемента массива или поля записи
+// лексемой служит вся запись: a[i], p.x
+func targetToken(e Expr) Token {
+	tok := e.Pos()
+	tok.Lexeme = exprString(e)
+	return tok
+}
+
+func (in *Interpreter) execList(stmts []Stmt) error {
+	for _, s := range stmts {
+		err := in.exec(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Выполнение одного оператора
+func (in *Interpreter) exec(s Stmt) error {
+	if _, ok := s.(*CompoundStmt); !ok {
+		in.frame().Current = s
+		if in.OnStmt != nil {
+			err := in.OnStmt(s)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	switch s := s.(type) {
+	case *AssignStmt:
+		return in.assign(s)
+	case *IfStmt:
+		cond, err := in.evalBool(s.Cond)
+		if err != nil {
+			return err
+		}
+		if cond {
+			return in.exec(s.Then)
+		} else if s.Else != nil {
+			return in.exec(s.Else)
+		}
+		return nil
+	case *ForStmt:
+		err := in.assign(s.Init)
+		if err != nil {
+			return err
+		}
+		v, _, err := in.ref(s.Init.Target)
+		if err != nil {
+			return err
+		}
+		if v.Kind != KindInt {
+			return &RuntimeError{Tok: s.Init.Target.Pos(), Msg: sprintf("переменная цикла for должна быть целой, получено %s", *v)}
+		}
+		// Граница и шаг вычисляются один раз, после присваивания начального значения
+		limit, err := in.eval(s.To)
+		if err != nil {
+			return err
+		}
+		if limit.Kind != KindInt {
+			return &RuntimeError{Tok: s.To.Pos(), Msg: sprintf("граница цикла for должна быть целой, получено %s", limit)}
+		}
+		step := Value{Kind: KindInt, Int: 1}
+		if s.Step != nil {
+			step, err = in.eval(s.Step)
+			if err != nil {
+				return err
+			}
+			if step.Kind != KindInt || step.bigInt().Sign() <= 0 {
+				return &RuntimeError{Tok: s.Step.Pos(), Msg: sprintf("шаг цикла for должен быть положительным целым, получено %s", step)}
+			}
+		}
+		if (!s.Down() && cmpInt(*v, limit) > 0) || (s.Down() && cmpInt(*v, limit) < 0) {
+			return nil
+		}
+		if v.Big != nil || limit.Big != nil || step.Big != nil {
+			return in.execBigFor(s, v, limit.bigInt(), step.bigInt())
+		}
+		// Переменная не выходит за границу: после цикла она хранит последнее
+		// значение, с которым выполнялось тело (расстояние до границы считается
+		// без знака, чтобы не было переполнения). Вызванная подпрограмма может
+		// изменить глобальную переменную цикла, поэтому сначала проверяется,
+		// не прошла ли она уже границу
+		for {
+			stop, err := in.execLoopBody(s.Body)
+			if err != nil || stop {
+				return err
+			}
+			if s.Down() {
+				if cmpInt(*v, limit) <= 0 || uint64(v.Int-limit.Int) < uint64(step.Int) {
+					return nil
+				}
+				v.Int -= step.Int
+			} else {
+				if cmpInt(*v, limit) >= 0 || uint64(limit.Int-v.Int) < uint64(step.Int) {
+					return nil
+				}
+				v.Int += step.Int
+			}
+		}
+	case *WhileStmt:
+		for {
+			cond, err := in.evalBool(s.Cond)
+			if err != nil {
+				return err
+			}
+			if !cond {
+				return nil
+			}
+			stop, err := in.execLoopBody(s.Body)
+			if err != nil || stop {
+				return err
+			}
+		}
+	case *RepeatStmt:
+		for {
+			stop, err := in.execLoopBody(s.Body...)
+			if err != nil || stop {
+				return err
+			}
+			cond, err := in.evalBool(s.Cond)
+			if err != nil {
+				return err
+			}
+			if cond {
+				return nil
+			}
+		}
+	case *BreakStmt:
+		return &loopSignal{Tok: s.Tok, Break: true}
+	case *ContinueStmt:
+		return &loopSignal{Tok: s.Tok}
+	case *CaseStmt:
+		sel, err := in.eval(s.Selector)
+		if err != nil {
+			return err
+		}
+		n, ok := ordinal(sel)
+		if sel.Kind == KindInt && !ok {
+			// Метки case помещаются в 64 бита, поэтому такое значение ни с одной не совпадает
+			if s.Else != nil {
+				return in.exec(s.Else)
+			}
+			return nil
+		}
+		if !ok {
+			return &RuntimeError{Tok: s.Selector.Pos(), Msg: sprintf("выражение выбора должно быть целым или логическим, получено %s", sel)}
+		}
+		for _, branch := range s.Branches {
+			for _, label := range branch.Labels {
+				low, high, kind, err := label.Bounds()
+				if err != nil {
+					return err
+				}
+				if kind != sel.Kind {
+					return &RuntimeError{Tok: label.Low.Pos(), Msg: sprintf("метка case '%s' типа %s не соответствует значению %s", caseLabelString(label), ordinalTypeName(kind), sel)}
+				}
+				if low <= n && n <= high {
+					return in.exec(branch.Body)
+				}
+			}
+		}
+		if s.Else != nil {
+			return in.exec(s.Else)
+		}
+		return nil
+	case *ReadStmt:
+		for _, target := range s.Targets {
+			v, t, err := in.ref(target)
+			if err != nil {
+				return err
+			}
+			if t.IsArray() || t.IsRecord() {
+				return &RuntimeError{Tok: target.Pos(), Msg: sprintf("нельзя прочитать переменную '%s' типа %s", exprString(target), typeString(t))}
+			}
+			val, err := in.readValue(targetToken(target), t)
+			if err != nil {
+				return err
+			}
+			*v = val
+		}
+		return nil
+	case *WriteStmt:
+		parts := make([]string, 0, len(s.Args))
+		for _, arg := range s.Args {
+			val, err := in.eval(arg)
+			if err != nil {
+				return err
+			}
+			parts = append(parts, val.String())
+		}
+		_, err := fmt.Fprintln(in.out, strings.Join(parts, " "))
+		return err
+	case *CompoundStmt:
+		if len(s.Decls) == 0 {
+			return in.execList(s.Body)
+		}
+		// Переменные блока создаются при каждом входе в блок
+		block := &Frame{Name: "block", Vars: make(map[string]*Variable)}
+		err := block.declare(s.Decls)
+		if err != nil {
+			return err
+		}
+		frame := in.frame()
+		frame.Blocks = append(frame.Blocks, block)
+		defer func() { frame.Blocks = frame.Blocks[:len(frame.Blocks)-1] }()
+		return in.execList(s.Body)
+	case *CallStmt:
+		_, err := in.call(s.Call)
+		return err
+	case *ReturnStmt:
+		ret := &returnSignal{Tok: s.Tok}
+		if s.Value != nil {
+			val, err := in.eval(s.Value)
+			if err != nil {
+				return err
+			}
+			ret.Value = &val
+		}
+		return ret
+	}
+	return &RuntimeError{Tok: s.Pos(), Msg: tr("неподдерживаемый оператор")}
+}
+
+func (in *Interpreter) assign(s *AssignStmt) error {
+	v, t, err := in.ref(s.Target)
+	if err != nil {
+		return err
+	}
+	val, err := in.eval(s.Value)
+	if err != nil {
+		return err
+	}
+	val, err = convert(targetToken(s.Target), val, t)
+	if err != nil {
+		return err
+	}
+	*v = val
+	return nil
+}
+
+// Приведение значения к типу переменной (допускаются только int -> float
+// и char -> string).
+// Массивы и записи копируются поэлементно; границы индекса массива
+// и имена полей записи должны совпадать.
+func convert(tok Token, val Value, t *TypeSpec) (Value, error) {
+	u := t.Underlying()
+	switch {
+	case u.IsArray():
+		low, high, _ := arrayBounds(u)
+		if val.Kind == KindArray && val.Low == low && int64(len(val.Elems)) == high-low+1 {
+			arr := Value{Kind: KindArray, Low: low, Elems: make([]Value, len(val.Elems))}
+			for i, elem := range val.Elems {
+				elem, err := convert(tok, elem, u.Elem)
+				if err != nil {
+					return Value{}, err
+				}
+				arr.Elems[i] = elem
+			}
+			return arr, nil
+		}
+	case u.IsRecord():
+		names := u.FieldNames()
+		if val.Kind == KindRecord && slices.Equal(val.Names, names) {
+			rec := Value{Kind: KindRecord, Names: names, Elems: make([]Value, len(val.Elems))}
+			for i, elem := range val.Elems {
+				_, ft := u.Field(names[i])
+				elem, err := convert(tok, elem, ft)
+				if err != nil {
+					return Value{}, err
+				}
+				rec.Elems[i] = elem
+			}
+			return rec, nil
+		}
+	default:
+		want := zeroValue(u.Tok.Lexeme).Kind
+		if val.Kind == want {
+			return val, nil
+		}
+		if val.Kind == KindInt && want == KindFloat {
+			return Value{Kind: KindFloat, Float: intToFloat(val)}, nil
+		}
+		if val.Kind == KindChar && want == KindString {
+			return Value{Kind: KindString, Str: val.Str}, nil
+		}
+	}
+	return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("нельзя присвоить значение %s переменной '%s' типа %s", val, tok.Lexeme, typeString(t))}
+}
+
+// Чтение значения заданного типа из входного потока
+func (in *Interpreter) readValue(tok Token, t *TypeSpec) (Value, error) {
+	typeName := t.Underlying().Tok.Lexeme
+	if typeName == "string" || typeName == "char" {
+		return in.readText(tok, typeName)
+	}
+	var word string
+	_, err := fmt.Fscan(in.in, &word)
+	if err != nil {
+		return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("не удалось прочитать значение '%s': %v", tok.Lexeme, err)}
+	}
+	if typeName == "bool" {
+		switch word {
+		case "true":
+			return Value{Kind: KindBool, Bool: true}, nil
+		case "false":
+			return Value{Kind: KindBool, Bool: false}, nil
+		}
+		return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("ожидалось true или false, прочитано '%s'", word)}
+	}
+	val, err := decodeSigned(word)
+	if err != nil {
+		return Value{}, &RuntimeError{Tok: tok, Msg: err.Error()}
+	}
+	return convert(tok, val, t)
+}
+
+// Чтение строки или символа: пробельные символы (в том числе переводы строк)
+// пропускаются, затем читается один символ или остаток строки ввода
+func (in *Interpreter) readText(tok Token, typeName string) (Value, error) {
+	for {
+		ch, _, err := in.in.ReadRune()
+		if err != nil {
+			return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("не удалось прочитать значение '%s': %v", tok.Lexeme, err)}
+		}
+		if !unicode.IsSpace(ch) {
+			if typeName == "char" {
+				return Value{Kind: KindChar, Str: string(ch)}, nil
+			}
+			in.in.UnreadRune()
+			break
+		}
+	}
+	line, err := in.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("не удалось прочитать значение '%s': %v", tok.Lexeme, err)}
+	}
+	return Value{Kind: KindString, Str: strings.TrimRight(line, "\r\n")}, nil
+}
+
+func (in *Interpreter) evalBool(e Expr) (bool, error) {
+	val, err := in.eval(e)
+	if err != nil {
+		return false, err
+	}
+	if val.Kind != KindBool {
+		return false, &RuntimeError{Tok: e.Pos(), Msg: sprintf("ожидалось логическое значение, получено %s", val)}
+	}
+	return val.Bool, nil
+}
+
+// Вычисление выражения
+func (in *Interpreter) eval(e Expr) (Value, error) {
+	switch e := e.(type) {
+	case *NumberLit, *BoolLit, *StringLit:
+		return evalConst(e)
+	case *CallExpr:
+		val, err := in.call(e)
+		if err != nil {
+			return Value{}, err
+		}
+		if val == nil {
+			return Value{}, &RuntimeError{Tok: e.Name, Msg: sprintf("Процедура '%s' не возвращает значения", e.Name.Lexeme)}
+		}
+		return *val, nil
+	case *Ident:
+		if e.Const != nil {
+			return evalConst(e)
+		}
+		v, err := in.lookup(e.Tok)
+		if err != nil {
+			return Value{}, err
+		}
+		return v.Value, nil
+	case *IndexExpr, *FieldExpr:
+		v, _, err := in.ref(e)
+		if err != nil {
+			return Value{}, err
+		}
+		return *v, nil
+	case *UnaryExpr:
+		x, err := in.eval(e.X)
+		if err != nil {
+			return Value{}, err
+		}
+		return unary(e.Op, x)
+	case *BinaryExpr:
+		left, err := in.eval(e.Left)
+		if err != nil {
+			return Value{}, err
+		}
+		right, err := in.eval(e.Right)
+		if err != nil {
+			return Value{}, err
+		}
+		return binary(e.Op, left, right)
+	}
+	return Value{}, &RuntimeError{Tok: e.Pos(), Msg: tr("неподдерживаемое выражение")}
+}
+
+// Унарная операция ~
+func unary(op Token, x Value) (Value, error) {
+	if x.Kind != KindBool {
+		return Value{}, &RuntimeError{Tok: op, Msg: sprintf("операция '~' неприменима к %s", x)}
+	}
+	return Value{Kind: KindBool, Bool: !x.Bool}, nil
+}
+
+// Бинарная операция над вычисленными операндами
+func binary(op Token, left, right Value) (Value, error) {
+	switch op.Lexeme {
+	case "and", "or":
+		if left.Kind != KindBool || right.Kind != KindBool {
+			return Value{}, &RuntimeError{Tok: op, Msg: sprintf("операция '%s' применима только к логическим значениям", op.Lexeme)}
+		}
+		if op.Lexeme == "and" {
+			return Value{Kind: KindBool, Bool: left.Bool && right.Bool}, nil
+		}
+		return Value{Kind: KindBool, Bool: left.Bool || right.Bool}, nil
+	case "EQ", "NE", "LT", "LE", "GT", "GE":
+		return compare(op, op.Lexeme, left, right)
+	default:
+		return arith(op, op.Lexeme, left, right)
+	}
+}
+
+// Арифметические операции plus, min, mult, div; plus над строками и символами —
+// сцепление
+func arith(tok Token, op string, left, right Value) (Value, error) {
+	err := checkScalar(tok, op, left, right)
+	if err != nil {
+		return Value{}, err
+	}
+	if isText(left) || isText(right) {
+		err := checkText(tok, op, left, right)
+		if err != nil {
+			return Value{}, err
+		}
+		if op != "plus" {
+			return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("операция '%s' неприменима к строкам", op)}
+		}
+		return Value{Kind: KindString, Str: left.Str + right.Str}, nil
+	}
+	if left.Kind == KindBool || right.Kind == KindBool {
+		return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("операция '%s' неприменима к логическим значениям", op)}
+	}
+	if left.Kind == KindInt && right.Kind == KindInt {
+		switch op {
+		case "plus", "min", "mult", "div":
+			return intArith(tok, op, left, right)
+		}
+	} else {
+		a, b := toFloat(left), toFloat(right)
+		switch op {
+		case "plus":
+			return Value{Kind: KindFloat, Float: a + b}, nil
+		case "min":
+			return Value{Kind: KindFloat, Float: a - b}, nil
+		case "mult":
+			return Value{Kind: KindFloat, Float: a * b}, nil
+		case "div":
+			if b == 0 {
+				return Value{}, &RuntimeError{Tok: tok, Msg: tr("деление на ноль")}
+			}
+			return Value{Kind: KindFloat, Float: a / b}, nil
+		}
+	}
+	return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("неизвестная операция '%s'", op)}
+}
+
+// Операции отношения EQ, NE, LT, LE, GT, GE
+func compare(tok Token, op string, left, right Value) (Value, error) {
+	err := checkScalar(tok, op, left, right)
+	if err != nil {
+		return Value{}, err
+	}
+	var c int
+	if isText(left) || isText(right) {
+		err := checkText(tok, op, left, right)
+		if err != nil {
+			return Value{}, err
+		}
+		c = strings.Compare(left.Str, right.Str)
+	} else if left.Kind == KindBool || right.Kind == KindBool {
+		if left.Kind != right.Kind || (op != "EQ" && op != "NE") {
+			return Value{}, &RuntimeError{Tok: tok, Msg: sprintf("операция '%s' неприменима к %s и %s", op, left, right)}
+		}
+		if left.Bool != right.Bool {
+			c = 1
+		}
+	} else if left.Kind == KindInt && right.Kind == KindInt {
+		c = cmpInt(left, right)
+	} else {
+		c = cmpOrdered(toFloat(left), toFloat(right))
+	}
+
+	var res bool
+	switch op {
+	case "EQ":
+		res = c == 0
+	case "NE":
+		res = c != 0
+	case "LT":
+		res = c < 0
+	case "LE":
+		res = c <= 0
+	case "GT":
+		res = c > 0
+	case "GE":
+		res = c >= 0
+	}
+	return Value{Kind: KindBool, Bool: res}, nil
+}
+
+// Операции над массивами и записями целиком не определены
+func checkScalar(tok Token, op string, left, right Value) error {
+	for _, v := range []Value{left, right} {
+		switch v.Kind {
+		case KindArray:
+			return &RuntimeError{Tok: tok, Msg: sprintf("операция '%s' неприменима к массивам", op)}
+		case KindRecord:
+			return &RuntimeError{Tok: tok, Msg: sprintf("операция '%s' неприменима к записям", op)}
+		}
+	}
+	return nil
+}
+
+func isText(v Value) bool {
+	return v.Kind == KindString || v.Kind == KindChar
+}
+
+// Строки и символы сцепляются и сравниваются только друг с другом
+func checkText(tok Token, op string, left, right Value) error {
+	for _, v := range []Value{left, right} {
+		if !isText(v) {
+			return &RuntimeError{Tok: tok, Msg: sprintf("операция '%s' неприменима к строке и значению %s", op, v)}
+		}
+	}
+	return nil
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v Value) float64 {
+	if v.Kind == KindInt {
+		return intToFloat(v)
+	}
+	return v.Float
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// Разбирает и выполняет программу, возвращая её вывод
+func runProgram(t *testing.T, src, input string) string {
+	t.Helper()
+	tokens, err := LexFile("prog.txt", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("лексическая ошибка: %v", err)
+	}
+	parser := Syntax{tokens: tokens, pos: 0, file: "prog.txt"}
+	prog, err := parser.ParseProgram()
+	if err != nil {
+		t.Fatalf("синтаксическая ошибка: %v", err)
+	}
+	var out strings.Builder
+	if err := NewInterpreter(strings.NewReader(input), &out).Run(prog); err != nil {
+		t.Fatalf("ошибка выполнения: %v", err)
+	}
+	return out.String()
+}
+
+// Подпрограмма, переносящая глобальную переменную цикла за границу,
+// завершает цикл, а не заставляет расстояние до границы переполниться
+func TestForLoopVariableChangedByCallee(t *testing.T) {
+	src := `program var i : int;
+procedure up();
+begin
+    i as 7d
+end;
+procedure down();
+begin
+    i as 0d
+end;
+begin
+    for i as 1d to 3d do [write(i); up()];
+    write(i);
+    for i as 3d downto 1d do [write(i); down()];
+    write(i)
+end.
+`
+	got := runProgram(t, src, "")
+	want := "1\n7\n3\n0\n"
+	if got != want {
+		t.Errorf("вывод %q, ожидалось %q", got, want)
+	}
+}
+
+func TestForLoopStepStopsAtBound(t *testing.T) {
+	src := `program var i : int;
+begin
+    for i as 1d to 7d step 3d do write(i);
+    for i as 9223372036854775806d to 9223372036854775807d do write(i);
+    for i as 5d downto 1d step 2d do write(i)
+end.
+`
+	got := runProgram(t, src, "")
+	want := "1\n4\n7\n9223372036854775806\n9223372036854775807\n5\n3\n1\n"
+	if got != want {
+		t.Errorf("вывод %q, ожидалось %q", got, want)
+	}
+}
+
+// Размер массива с границей около MinInt64 не переполняется при проверке:
+// при разборе текста и загрузке дерева из JSON это ошибка S061, а дерево,
+// построенное без проверок, даёт ошибку выполнения, а не панику в make
+func TestArrayTooLarge(t *testing.T) {
+	src := `program const lo = 0d min 9223372036854775807d;
+var b : array [lo..1d] of int;
+begin
+    write(1d)
+end.
+`
+	tokens, err := LexFile("prog.txt", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := Syntax{tokens: tokens, pos: 0, file: "prog.txt"}
+	_, err = parser.ParseProgram()
+	if d := asDiagnostic(err); d == nil || d.Code != "S061" {
+		t.Errorf("ошибка разбора %v, ожидалась S061", err)
+	}
+
+	num := func(text string) string {
+		return `{"kind":"Number","token":{"text":"` + text + `"}}`
+	}
+	low := `{"kind":"Binary","op":{"text":"min"},"left":` + num("0d") + `,"right":` + num("9223372036854775807d") + `}`
+	tree := `{"kind":"Program","decls":[{"kind":"VarDecl","names":[{"text":"b"}],"type":{"kind":"ArrayType","low":` + low +
+		`,"high":` + num("1d") + `,"elem":{"kind":"Type","token":{"text":"int"}}}}],"body":[{"kind":"Write","args":[` + num("1d") + `]}]}`
+	_, err = ReadProgramJSON(strings.NewReader(tree), "prog.json")
+	if d := asDiagnostic(err); d == nil || d.Code != "S061" {
+		t.Errorf("ошибка загрузки дерева %v, ожидалась S061", err)
+	}
+
+	lit := func(text string) Expr {
+		val, base, err := decodeNumber(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &NumberLit{Tok: Token{Type: TokenNumber, Lexeme: text, Value: val, Base: base}}
+	}
+	prog := &Program{
+		Decls: []*VarDecl{{
+			Names: []Token{{Type: TokenIdentifier, Lexeme: "b"}},
+			Type: &TypeSpec{
+				Tok:  Token{Type: TokenKeyword, Lexeme: "array"},
+				Low:  &BinaryExpr{Op: Token{Type: TokenOperator, Lexeme: "min"}, Left: lit("0d"), Right: lit("9223372036854775807d")},
+				High: lit("1d"),
+				Elem: &TypeSpec{Tok: Token{Type: TokenKeyword, Lexeme: "int"}},
+			},
+		}},
+		Body: []Stmt{&WriteStmt{Tok: Token{Type: TokenKeyword, Lexeme: "write"}, Args: []Expr{lit("1d")}}},
+	}
+	err = NewInterpreter(strings.NewReader(""), io.Discard).Run(prog)
+	if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), "9223372036854775809") {
+		t.Errorf("ошибка выполнения %v, ожидалось сообщение о размере массива", err)
+	}
+}
+
+// read принимает числа со знаком, в том числе наименьшее 64-битное целое
+func TestReadSignedNumbers(t *testing.T) {
+	src := `program var a, b, c : int; f, g : float;
+begin
+    read(a, b, c, f, g);
+    write(a, b, c, f, g)
+end.
+`
+	got := runProgram(t, src, "-5 +7d -9223372036854775808\n-1.5 -101b\n")
+	want := "-5 7 -9223372036854775808 -1.5 -5\n"
+	if got != want {
+		t.Errorf("вывод %q, ожидалось %q", got, want)
+	}
+
+	for _, input := range []string{"--1", "-", "-9223372036854775809"} {
+		tokens, err := LexFile("prog.txt", strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parser := Syntax{tokens: tokens, pos: 0, file: "prog.txt"}
+		prog, err := parser.ParseProgram()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewInterpreter(strings.NewReader(input), io.Discard).Run(prog)
+		if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), "'"+input+"'") {
+			t.Errorf("ввод %q: ошибка %v", input, err)
+		}
+	}
+}
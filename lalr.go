@@ -0,0 +1,582 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Команда lalr: таблицы LALR(1)-анализатора по той же грамматике tfi.ebnf,
+// что и у команды ll. Наборы пунктов LR(0) строятся по расширенной
+// грамматике S' → Program, предпросмотры находятся распространением
+// (спонтанные символы и символы, переходящие от пункта к пункту), а конфликты
+// разрешаются как в yacc: сдвиг важнее свёртки, из двух свёрток выбирается
+// продукция с меньшим номером.
+
+// Пункт LR: продукция и позиция точки в её правой части
+type lrItem struct {
+	prod, dot int
+}
+
+type lrState struct {
+	kernel []lrItem
+	index  map[lrItem]int    // номера пунктов ядра
+	next   map[string]int    // переходы по символам
+	la     []map[string]bool // предпросмотры пунктов ядра
+}
+
+type lrKind uint8
+
+const (
+	lrShift lrKind = iota
+	lrReduce
+	lrAccept
+)
+
+type lrAction struct {
+	kind lrKind
+	n    int // состояние для сдвига, продукция для свёртки
+}
+
+// Конфликт в клетке таблицы: сдвиг (shift ≥ 0) и/или несколько свёрток
+type lrConflict struct {
+	state   int
+	term    string
+	shift   int
+	reduces []int
+}
+
+type lalrTable struct {
+	g         *grammar
+	prods     []*production // продукции грамматики и S' → Program последней
+	states    []*lrState
+	action    []map[string]lrAction
+	conflicts []lrConflict
+}
+
+// Символ после точки
+func (t *lalrTable) symAt(it lrItem) (string, bool) {
+	rhs := t.prods[it.prod].rhs
+	if it.dot < len(rhs) {
+		return rhs[it.dot], true
+	}
+	return "", false
+}
+
+// Замыкание LR(0): пункты ядра и пункты с точкой в начале продукций
+// нетерминалов, стоящих после точки
+func (t *lalrTable) closure(kernel []lrItem) []lrItem {
+	items := append([]lrItem(nil), kernel...)
+	expanded := make(map[string]bool)
+	for i := 0; i < len(items); i++ {
+		sym, ok := t.symAt(items[i])
+		if !ok || isTerminal(sym) || expanded[sym] {
+			continue
+		}
+		expanded[sym] = true
+		for _, p := range t.g.byLHS[sym] {
+			items = append(items, lrItem{p, 0})
+		}
+	}
+	return items
+}
+
+// Замыкание LR(1) для пунктов с множествами предпросмотров
+func (t *lalrTable) closureLA(kernel []lrItem, las []map[string]bool) ([]lrItem, map[lrItem]map[string]bool) {
+	items := append([]lrItem(nil), kernel...)
+	sets := make(map[lrItem]map[string]bool)
+	for i, it := range kernel {
+		sets[it] = make(map[string]bool)
+		for a := range las[i] {
+			sets[it][a] = true
+		}
+	}
+	queue := append([]lrItem(nil), kernel...)
+	for len(queue) > 0 {
+		it := queue[0]
+		queue = queue[1:]
+		sym, ok := t.symAt(it)
+		if !ok || isTerminal(sym) {
+			continue
+		}
+		first, nullable := t.g.firstOf(t.prods[it.prod].rhs[it.dot+1:])
+		if nullable {
+			for a := range sets[it] {
+				first[a] = true
+			}
+		}
+		for _, p := range t.g.byLHS[sym] {
+			target := lrItem{p, 0}
+			set := sets[target]
+			if set == nil {
+				set = make(map[string]bool)
+				sets[target] = set
+				items = append(items, target)
+			}
+			changed := false
+			for a := range first {
+				if !set[a] {
+					set[a] = true
+					changed = true
+				}
+			}
+			if changed {
+				queue = append(queue, target)
+			}
+		}
+	}
+	return items, sets
+}
+
+func itemsKey(items []lrItem) string {
+	var b strings.Builder
+	for _, it := range items {
+		fmt.Fprintf(&b, "%d.%d,", it.prod, it.dot)
+	}
+	return b.String()
+}
+
+func buildLALRTable(g *grammar) *lalrTable {
+	t := &lalrTable{g: g, prods: append(append([]*production(nil), g.prods...), &production{lhs: g.start + "'", rhs: []string{g.start}})}
+	augmented := len(t.prods) - 1
+
+	// Наборы пунктов LR(0)
+	index := make(map[string]int)
+	add := func(kernel []lrItem) int {
+		sort.Slice(kernel, func(i, j int) bool {
+			if kernel[i].prod != kernel[j].prod {
+				return kernel[i].prod < kernel[j].prod
+			}
+			return kernel[i].dot < kernel[j].dot
+		})
+		key := itemsKey(kernel)
+		if i, ok := index[key]; ok {
+			return i
+		}
+		index[key] = len(t.states)
+		st := &lrState{kernel: kernel, index: make(map[lrItem]int), next: make(map[string]int)}
+		for k, it := range kernel {
+			st.index[it] = k
+			st.la = append(st.la, make(map[string]bool))
+		}
+		t.states = append(t.states, st)
+		return len(t.states) - 1
+	}
+	add([]lrItem{{augmented, 0}})
+	for i := 0; i < len(t.states); i++ {
+		var order []string
+		gotos := make(map[string][]lrItem)
+		for _, it := range t.closure(t.states[i].kernel) {
+			sym, ok := t.symAt(it)
+			if !ok {
+				continue
+			}
+			if gotos[sym] == nil {
+				order = append(order, sym)
+			}
+			gotos[sym] = append(gotos[sym], lrItem{it.prod, it.dot + 1})
+		}
+		for _, sym := range order {
+			t.states[i].next[sym] = add(gotos[sym])
+		}
+	}
+
+	// Предпросмотры: замыкание каждого пункта ядра с фиктивным символом '#'
+	// показывает, какие символы порождаются спонтанно, а какие переходят
+	// от пункта к пункту ядра следующего состояния
+	type kernelRef struct{ state, item int }
+	propagate := make(map[kernelRef][]kernelRef)
+	const dummy = "#"
+	for i, st := range t.states {
+		for k, kit := range st.kernel {
+			items, sets := t.closureLA([]lrItem{kit}, []map[string]bool{{dummy: true}})
+			for _, it := range items {
+				sym, ok := t.symAt(it)
+				if !ok {
+					continue
+				}
+				j := st.next[sym]
+				kj := t.states[j].index[lrItem{it.prod, it.dot + 1}]
+				for a := range sets[it] {
+					if a == dummy {
+						propagate[kernelRef{i, k}] = append(propagate[kernelRef{i, k}], kernelRef{j, kj})
+					} else {
+						t.states[j].la[kj][a] = true
+					}
+				}
+			}
+		}
+	}
+	t.states[0].la[0][endMarker] = true
+	for changed := true; changed; {
+		changed = false
+		for from, targets := range propagate {
+			for a := range t.states[from.state].la[from.item] {
+				for _, to := range targets {
+					if !t.states[to.state].la[to.item][a] {
+						t.states[to.state].la[to.item][a] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	// Таблица действий
+	for i, st := range t.states {
+		shifts := make(map[string]int)
+		reduces := make(map[string][]int)
+		accept := false
+		items, sets := t.closureLA(st.kernel, st.la)
+		for _, it := range items {
+			sym, ok := t.symAt(it)
+			switch {
+			case ok && isTerminal(sym):
+				shifts[sym] = st.next[sym]
+			case ok:
+			case it.prod == augmented:
+				accept = true
+			default:
+				for a := range sets[it] {
+					reduces[a] = append(reduces[a], it.prod)
+				}
+			}
+		}
+		row := make(map[string]lrAction)
+		for _, term := range g.allTerms() {
+			shift, isShift := shifts[term]
+			rs := reduces[term]
+			sort.Ints(rs)
+			switch {
+			case term == endMarker && accept:
+				row[term] = lrAction{kind: lrAccept}
+			case isShift:
+				row[term] = lrAction{kind: lrShift, n: shift}
+			case len(rs) > 0:
+				row[term] = lrAction{kind: lrReduce, n: rs[0]}
+			}
+			if (isShift && len(rs) > 0) || len(rs) > 1 {
+				c := lrConflict{state: i, term: term, shift: -1, reduces: rs}
+				if isShift {
+					c.shift = shift
+				}
+				t.conflicts = append(t.conflicts, c)
+			}
+		}
+		t.action = append(t.action, row)
+	}
+	return t
+}
+
+// Запись пункта: Lhs → a · B c
+func (t *lalrTable) itemString(it lrItem) string {
+	prod := t.prods[it.prod]
+	syms := append(append(append([]string(nil), prod.rhs[:it.dot]...), "·"), prod.rhs[it.dot:]...)
+	return prod.lhs + " → " + strings.Join(syms, " ")
+}
+
+// Кратчайшие цепочки терминалов, выводимые из нетерминалов
+func (g *grammar) shortestYields() map[string][]string {
+	yields := make(map[string][]string)
+	for changed := true; changed; {
+		changed = false
+		for _, prod := range g.prods {
+			var out []string
+			complete := true
+			for _, sym := range prod.rhs {
+				if isTerminal(sym) {
+					out = append(out, sym)
+					continue
+				}
+				y, ok := yields[sym]
+				if !ok {
+					complete = false
+					break
+				}
+				out = append(out, y...)
+			}
+			old, ok := yields[prod.lhs]
+			if complete && (!ok || len(out) < len(old)) {
+				yields[prod.lhs] = out
+				changed = true
+			}
+		}
+	}
+	return yields
+}
+
+// Пример входа, на котором возникает конфликт: кратчайший путь по переходам
+// от начального состояния, нетерминалы которого заменены кратчайшими
+// выводимыми цепочками, затем точка и терминал конфликта
+func (t *lalrTable) example(state int, term string, yields map[string][]string) string {
+	type step struct {
+		from int
+		sym  string
+	}
+	prev := map[int]step{0: {-1, ""}}
+	queue := []int{0}
+	for len(queue) > 0 && queue[0] != state {
+		i := queue[0]
+		queue = queue[1:]
+		st := t.states[i]
+		for _, sym := range append(append([]string(nil), t.g.allTerms()...), t.g.nonterms...) {
+			j, ok := st.next[sym]
+			if _, seen := prev[j]; ok && !seen {
+				prev[j] = step{i, sym}
+				queue = append(queue, j)
+			}
+		}
+	}
+	var path []string
+	for i := state; prev[i].from >= 0; i = prev[i].from {
+		path = append([]string{prev[i].sym}, path...)
+	}
+	var words []string
+	for _, sym := range path {
+		if isTerminal(sym) {
+			words = append(words, symbolText(sym))
+			continue
+		}
+		for _, s := range yields[sym] {
+			words = append(words, symbolText(s))
+		}
+	}
+	return strings.Join(append(words, "·", symbolText(term)), " ")
+}
+
+// Терминал в примере: слово без кавычек или имя класса токенов
+func symbolText(sym string) string {
+	if sym[0] == '"' {
+		return sym[1 : len(sym)-1]
+	}
+	return sym
+}
+
+// Сводка по таблице и конфликты с примерами
+func (t *lalrTable) printSummary(w io.Writer) {
+	g := t.g
+	sr, rr := 0, 0
+	for _, c := range t.conflicts {
+		if c.shift >= 0 {
+			sr++
+		}
+		if len(c.reduces) > 1 {
+			rr++
+		}
+	}
+	fmt.Fprintf(w, tr("%s: продукций: %d, состояний LALR(1): %d; конфликтов сдвиг/свёртка: %d, свёртка/свёртка: %d\n"),
+		g.file, len(g.prods), len(t.states), sr, rr)
+	yields := g.shortestYields()
+	for _, c := range t.conflicts {
+		line := g.prods[c.reduces[0]].line
+		if c.shift >= 0 {
+			fmt.Fprintf(w, tr("%s:%d: конфликт сдвиг/свёртка в состоянии %d по %s:\n"), g.file, line, c.state, c.term)
+			items, _ := t.closureLA(t.states[c.state].kernel, t.states[c.state].la)
+			for _, it := range items {
+				sym, ok := t.symAt(it)
+				if ok && sym == c.term {
+					fmt.Fprintf(w, tr("    сдвиг       %s\n"), t.itemString(it))
+				}
+			}
+		} else {
+			fmt.Fprintf(w, tr("%s:%d: конфликт свёртка/свёртка в состоянии %d по %s:\n"), g.file, line, c.state, c.term)
+		}
+		for _, p := range c.reduces {
+			fmt.Fprintf(w, tr("    свёртка %3d %s\n"), p+1, g.prods[p])
+		}
+		fmt.Fprintf(w, tr("    пример: %s\n"), t.example(c.state, c.term, yields))
+		if c.shift >= 0 {
+			fmt.Fprintln(w, tr("    выбран сдвиг"))
+		} else {
+			fmt.Fprintf(w, tr("    выбрана свёртка %d\n"), c.reduces[0]+1)
+		}
+	}
+}
+
+// Наборы пунктов: ядро с предпросмотрами и переходы
+func (t *lalrTable) printStates(w io.Writer) {
+	for i, st := range t.states {
+		fmt.Fprintf(w, tr("состояние %d\n"), i)
+		for k, it := range st.kernel {
+			fmt.Fprintf(w, "    %s    [ %s ]\n", t.itemString(it), strings.Join(t.g.sortedTerms(st.la[k]), " "))
+		}
+		for _, sym := range append(append([]string(nil), t.g.allTerms()...), t.g.nonterms...) {
+			if j, ok := st.next[sym]; ok {
+				fmt.Fprintf(w, "    %s → %d\n", sym, j)
+			}
+		}
+	}
+}
+
+// Таблица по строкам: действия (s — сдвиг, r — свёртка, acc — принять) и переходы
+func (t *lalrTable) printTable(w io.Writer) {
+	for i, row := range t.action {
+		var cells []string
+		for _, term := range t.g.allTerms() {
+			act, ok := row[term]
+			if !ok {
+				continue
+			}
+			switch act.kind {
+			case lrShift:
+				cells = append(cells, fmt.Sprintf("%s:s%d", term, act.n))
+			case lrReduce:
+				cells = append(cells, fmt.Sprintf("%s:r%d", term, act.n+1))
+			case lrAccept:
+				cells = append(cells, term+":acc")
+			}
+		}
+		for _, nt := range t.g.nonterms {
+			if j, ok := t.states[i].next[nt]; ok {
+				cells = append(cells, fmt.Sprintf("%s:%d", nt, j))
+			}
+		}
+		fmt.Fprintf(w, "%d\n    %s\n", i, strings.Join(cells, "  "))
+	}
+}
+
+// Разбор токенов по таблице LALR(1); при trace != nil каждый шаг печатается:
+// стек состояний и символов, начало входа и действие
+func (t *lalrTable) parse(tokens []Token, trace io.Writer) error {
+	p := &Syntax{tokens: tokens}
+	states := []int{0}
+	syms := []string{""}
+	for {
+		tok := p.currentToken()
+		term := grammarTerminal(tok)
+		act, ok := t.action[states[len(states)-1]][term]
+		if !ok {
+			// Как и ParseProgram, токены после конца программы не рассматриваются
+			if term != endMarker && t.acceptsEnd(states) {
+				if trace != nil {
+					t.traceStep(trace, states, syms, p, tr("принять"))
+				}
+				return nil
+			}
+			var expected []string
+			for _, term := range t.g.allTerms() {
+				if _, ok := t.action[states[len(states)-1]][term]; ok {
+					expected = append(expected, term)
+				}
+			}
+			return p.errorAt(tok, "Ожидалось %s, получено %s '%s'", expectedText(expected), TokenTypeToString(tok.Type), tok.Lexeme)
+		}
+		switch act.kind {
+		case lrAccept:
+			if trace != nil {
+				t.traceStep(trace, states, syms, p, tr("принять"))
+			}
+			return nil
+		case lrShift:
+			if trace != nil {
+				t.traceStep(trace, states, syms, p, sprintf("сдвиг %s, состояние %d", term, act.n))
+			}
+			states = append(states, act.n)
+			syms = append(syms, term)
+			p.nextToken()
+		case lrReduce:
+			prod := t.prods[act.n]
+			if trace != nil {
+				t.traceStep(trace, states, syms, p, sprintf("свёртка %d  %s", act.n+1, prod))
+			}
+			states = states[:len(states)-len(prod.rhs)]
+			syms = syms[:len(syms)-len(prod.rhs)]
+			states = append(states, t.states[states[len(states)-1]].next[prod.lhs])
+			syms = append(syms, prod.lhs)
+		}
+	}
+}
+
+// Допускает ли разобранная часть входа конец текста: свёртки по '$'
+// приводят к принятию
+func (t *lalrTable) acceptsEnd(stack []int) bool {
+	states := append([]int(nil), stack...)
+	for {
+		act, ok := t.action[states[len(states)-1]][endMarker]
+		if !ok || act.kind == lrShift {
+			return false
+		}
+		if act.kind == lrAccept {
+			return true
+		}
+		prod := t.prods[act.n]
+		states = states[:len(states)-len(prod.rhs)]
+		states = append(states, t.states[states[len(states)-1]].next[prod.lhs])
+	}
+}
+
+// Строка трассировки: вершина стека (символ и состояние), три токена входа и действие
+func (t *lalrTable) traceStep(w io.Writer, states []int, syms []string, p *Syntax, action string) {
+	const depth, lookahead = 5, 3
+	var stack []string
+	from := max(0, len(states)-depth)
+	if from > 0 {
+		stack = append(stack, "…")
+	}
+	for i := from; i < len(states); i++ {
+		if i == 0 {
+			stack = append(stack, "0")
+			continue
+		}
+		stack = append(stack, fmt.Sprintf("%s %d", syms[i], states[i]))
+	}
+	var input []string
+	for i := p.pos; i < len(p.tokens) && i < p.pos+lookahead; i++ {
+		input = append(input, p.tokens[i].Lexeme)
+	}
+	if p.pos+lookahead >= len(p.tokens) {
+		input = append(input, endMarker)
+	}
+	fmt.Fprintf(w, "%-60s | %-24s | %s\n", strings.Join(stack, " "), strings.Join(input, " "), action)
+}
+
+func runLALR(args []string) int {
+	fs := flag.NewFlagSet("lalr", flag.ContinueOnError)
+	grammarFile := fs.String("grammar", "", tr("файл грамматики EBNF вместо встроенной"))
+	printFlag := fs.String("print", "", tr("напечатать части анализатора через запятую: bnf, states, table"))
+	trace := fs.Bool("trace", false, tr("печатать шаги разбора"))
+	fs.StringVar(&lang, "lang", lang, tr("язык сообщений: ru, en"))
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if lang != "ru" && lang != "en" {
+		fmt.Fprintf(os.Stderr, tr("Неизвестный язык '%s'\n"), lang)
+		return exitUsage
+	}
+	parts, ok := printParts(*printFlag, []string{"bnf", "states", "table"})
+	if !ok {
+		return exitUsage
+	}
+	g, code := loadGrammar(*grammarFile)
+	if g == nil {
+		return code
+	}
+	table := buildLALRTable(g)
+	table.printSummary(os.Stdout)
+	for _, part := range parts {
+		fmt.Println()
+		switch part {
+		case "bnf":
+			g.printBNF(os.Stdout)
+		case "states":
+			table.printStates(os.Stdout)
+		case "table":
+			table.printTable(os.Stdout)
+		}
+	}
+
+	var w io.Writer
+	if *trace {
+		w = os.Stdout
+	}
+	for _, name := range fs.Args() {
+		code = max(code, checkGrammarParse(name, func(tokens []Token) error {
+			return table.parse(tokens, w)
+		}))
+	}
+	return code
+}
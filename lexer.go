@@ -1,12 +1,11 @@
 package main
 
 import (
-	"bufio"
-	"fmt"
 	"io"
-	"regexp"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
-	"unicode"
 )
 
 // Определение типов токенов
@@ -19,6 +18,10 @@ const (
 	TokenIdentifier
 	TokenNumber
 	TokenEOF
+	TokenComment
+	TokenString
+	TokenChar
+	TokenDirective // директива препроцессора {$...}; удаляется при обработке директив
 )
 
 type Token struct {
@@ -26,12 +29,20 @@ type Token struct {
 	Lexeme  string
 	LineNum int
 	ColNum  int
+	Offset  int    // смещение начала токена в байтах от начала текста
+	File    string // путь к файлу модуля; пусто для токенов разбираемого файла
+	// Значение константы, вычисленное лексическим анализатором: для числа — целое
+	// (KindInt) или вещественное (KindFloat), для строки и символа — KindString и KindChar
+	Value Value
+	Base  int // система счисления числовой константы: 2, 8, 10 или 16
 }
 
 // Списки ключевых слов, операторов и разделителей
 var keyWords = []string{
 	"or", "and", "not", "program", "var", "begin", "end", "int", "float", "bool", "as", "if", "else", "then",
-	"for", "to", "do", "while", "read", "write", "true", "false",
+	"for", "to", "do", "while", "read", "write", "true", "false", "procedure", "function", "return", "array", "of", "type", "record",
+	"string", "char", "const", "case", "repeat", "until", "break", "continue", "downto", "step",
+	"unit", "uses", "interface", "implementation",
 }
 
 var operators = []string{
@@ -40,26 +51,225 @@ var operators = []string{
 }
 
 var delimiters = []string{
-	";", ":", ",", "(", ")", ".", "=", "{", "}", "[", "]",
+	";", ":", ",", "(", ")", ".", "..", "=", "{", "}", "[", "]",
 }
 
-// Функции для проверки типов токенов
-func isNumber(s string) bool {
-	patterns := []string{
-		`^\d+d?$`,                // Целое число
-		`^\d*\.\d+(e[-+]?\d+)?$`, // Вещественное число с десятичной точкой
-		`^\d+e[-+]?\d+$`,         // Число с экспонентой
-		`^[01]+b$`,               // Двоичное число
-		`^[0-7]+o$`,              // Восьмеричное число
-		`^[0-9a-fA-F]+h$`,        // Шестнадцатеричное число
+// Ошибка в записи числовой константы; Pos — номер символа лексемы (с 0),
+// на который указывает сообщение
+type numberError struct {
+	Pos    int
+	Format string
+	Args   []any
+}
+
+func (e *numberError) Error() string {
+	return sprintf(e.Format, e.Args...)
+}
+
+// Числовая константа записана как вещественная: с точкой или десятичным порядком
+func isFloatLiteral(s string) bool {
+	return strings.ContainsAny(s, ".") || (strings.ContainsAny(s, "eE") && !strings.HasSuffix(s, "h"))
+}
+
+// Цифры в записи чисел — только цифры ASCII
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isHexDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+// Имя, которое было бы шестнадцатеричным числом, если бы начиналось с цифры: FFh, abch
+func looksLikeHex(s string) bool {
+	if len(s) < 2 || !strings.HasSuffix(s, "h") {
+		return false
 	}
-	for _, pattern := range patterns {
-		matched, _ := regexp.MatchString(pattern, s)
-		if matched {
-			return true
+	for _, r := range s[:len(s)-1] {
+		if !isHexDigit(r) {
+			return false
 		}
 	}
-	return false
+	return true
+}
+
+// Значение и система счисления числовой константы. Запись целых: 123 или 123d,
+// 1010b, 17o, 0FFh (первым символом всегда идёт цифра); вещественных: 3.14, 2e5,
+// 1.5e-3. Значение проверяется только на корректность записи: целое вне диапазона
+// int64 хранится в Value.Big, вещественное вне диапазона float64 равно ±Inf,
+// а допустимость таких значений проверяет синтаксический анализатор
+func scanNumber(s string) (Value, int, error) {
+	runes := []rune(s)
+	if len(runes) == 0 || !isDigit(runes[0]) {
+		return Value{}, 0, &numberError{Format: "некорректное число '%s'", Args: []any{s}}
+	}
+	last, body := runes[len(runes)-1], runes[:len(runes)-1]
+	if last == 'h' {
+		for i, r := range body {
+			if !isHexDigit(r) {
+				return Value{}, 16, &numberError{Pos: i, Format: "недопустимый символ '%c' в числе '%s'", Args: []any{r, s}}
+			}
+		}
+		return intLiteral(string(body), 16)
+	}
+	if isFloatLiteral(s) {
+		return scanFloat(s)
+	}
+
+	digits, base := runes, 10
+	switch last {
+	case 'b':
+		digits, base = body, 2
+	case 'o':
+		digits, base = body, 8
+	case 'd':
+		digits = body
+	}
+	for i, r := range digits {
+		if !isDigit(r) && isHexDigit(r) {
+			return Value{}, base, &numberError{Pos: i, Format: "шестнадцатеричное число '%s' должно оканчиваться на 'h'", Args: []any{s}}
+		}
+	}
+	for i, r := range digits {
+		if !isDigit(r) {
+			return Value{}, base, &numberError{Pos: i, Format: "недопустимый символ '%c' в числе '%s'", Args: []any{r, s}}
+		}
+		if int(r-'0') >= base {
+			return Value{}, base, &numberError{Pos: i, Format: "цифра '%c' недопустима в числе '%s' с основанием %d", Args: []any{r, s, base}}
+		}
+	}
+	return intLiteral(string(digits), base)
+}
+
+// Значение целой константы по цифрам, уже проверенным на допустимость
+func intLiteral(digits string, base int) (Value, int, error) {
+	n, err := strconv.ParseInt(digits, base, 64)
+	if err == nil {
+		return Value{Kind: KindInt, Int: n}, base, nil
+	}
+	b, _ := new(big.Int).SetString(digits, base)
+	return Value{Kind: KindInt, Big: b}, base, nil
+}
+
+// Вещественная константа: <цифры> [. <цифры>] [e [+|-] <цифры>]
+func scanFloat(s string) (Value, int, error) {
+	runes := []rune(s)
+	i := 0
+	digits := func() int {
+		n := 0
+		for i < len(runes) && isDigit(runes[i]) {
+			i++
+			n++
+		}
+		return n
+	}
+	digits()
+	if i < len(runes) && runes[i] == '.' {
+		i++
+		if digits() == 0 {
+			return Value{}, 10, &numberError{Pos: i, Format: "после десятичной точки в числе '%s' ожидалась цифра", Args: []any{s}}
+		}
+	}
+	if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+		i++
+		if i < len(runes) && (runes[i] == '+' || runes[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return Value{}, 10, &numberError{Pos: i, Format: "в порядке числа '%s' ожидалась цифра", Args: []any{s}}
+		}
+	}
+	if i < len(runes) {
+		return Value{}, 10, &numberError{Pos: i, Format: "недопустимый символ '%c' в числе '%s'", Args: []any{runes[i], s}}
+	}
+	// Ошибка возможна только при выходе за диапазон; тогда f равно ±Inf
+	f, _ := strconv.ParseFloat(s, 64)
+	return Value{Kind: KindFloat, Float: f}, 10, nil
+}
+
+// Значение числа, записанного в тексте (константа из дерева в JSON или ввод read):
+// в отличие от scanNumber, значение вне допустимого диапазона является ошибкой
+func decodeNumber(s string) (Value, int, error) {
+	val, base, err := scanNumber(s)
+	if err != nil {
+		return Value{}, base, err
+	}
+	err = checkNumberRange(s, val)
+	if err != nil {
+		return Value{}, base, err
+	}
+	return val, base, nil
+}
+
+// Число из входного потока (read): как decodeNumber, но перед числом допускается
+// знак '+' или '-'. Знак применяется до проверки диапазона, поэтому читается
+// и -9223372036854775808
+func decodeSigned(s string) (Value, error) {
+	digits := s
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		digits = s[1:]
+	}
+	val, _, err := scanNumber(digits)
+	if e, ok := err.(*numberError); ok {
+		// Сообщение приводит прочитанное слово целиком, со знаком
+		for i, arg := range e.Args {
+			if arg == digits {
+				e.Args[i] = s
+			}
+		}
+	}
+	if err != nil {
+		return Value{}, err
+	}
+	if strings.HasPrefix(s, "-") {
+		if val.Kind == KindFloat {
+			val.Float = -val.Float
+		} else {
+			val = bigValue(new(big.Int).Neg(val.bigInt()))
+		}
+	}
+	err = checkNumberRange(s, val)
+	if err != nil {
+		return Value{}, err
+	}
+	return val, nil
+}
+
+// Проверка диапазона значения числовой константы s: целое вне int64 допустимо
+// только в режиме -bigint, вещественное должно быть конечным
+func checkNumberRange(s string, val Value) error {
+	if val.Big != nil && !bigInts {
+		return errorf("целое число '%s' не помещается в 64 бита", s)
+	}
+	if math.IsInf(val.Float, 0) {
+		return errorf("некорректное вещественное число '%s'", s)
+	}
+	return nil
+}
+
+// Escape-последовательности в строковых и символьных константах: \n, \t, \r, \\, \", \'
+var escapes = map[rune]rune{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '"': '"', '\'': '\''}
+
+// Значение строковой или символьной константы; лексема записана вместе с кавычками
+func decodeString(s string) (string, error) {
+	runes := []rune(s)
+	if len(runes) < 2 || (runes[0] != '"' && runes[0] != '\'') || runes[len(runes)-1] != runes[0] {
+		return "", errorf("некорректная строковая константа %s", s)
+	}
+	var sb strings.Builder
+	for i := 1; i < len(runes)-1; i++ {
+		ch := runes[i]
+		if ch == '\\' {
+			i++
+			e, ok := escapes[runes[i]]
+			if !ok || i == len(runes)-1 {
+				return "", errorf("некорректная строковая константа %s", s)
+			}
+			ch = e
+		}
+		sb.WriteRune(ch)
+	}
+	return sb.String(), nil
 }
 
 func isKeyword(s string) bool {
@@ -89,174 +299,39 @@ func isDelimiter(c rune) bool {
 	return false
 }
 
-// Функция лексического анализа
-func Lexer(reader io.Reader) ([]Token, error) {
-	var tokens []Token
-	var lineNum, colNum int = 1, 0
-
-	bufReader := bufio.NewReader(reader)
-	var sb strings.Builder
-
-	state := "H"
-	for {
-		ch, size, err := bufReader.ReadRune()
-		if err != nil {
-			if err == io.EOF {
-				if state == "ID" {
-					lexeme := sb.String()
-					if isKeyword(lexeme) {
-						tokens = append(tokens, Token{Type: TokenKeyword, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme)) + 1})
-					} else if isOperator(lexeme) {
-						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme)) + 1})
-					} else {
-						tokens = append(tokens, Token{Type: TokenIdentifier, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme)) + 1})
-					}
-				} else if state == "NUM" {
-					lexeme := sb.String()
-					if isNumber(lexeme) {
-						tokens = append(tokens, Token{Type: TokenNumber, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme)) + 1})
-					} else {
-						return nil, fmt.Errorf("Лексическая ошибка в строке %d, столбец %d: некорректное число '%s'", lineNum, colNum-len([]rune(lexeme))+1, lexeme)
-					}
-				} else if state == "OP" {
-					lexeme := sb.String()
-					if isOperator(lexeme) {
-						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum})
-					} else {
-						return nil, fmt.Errorf("Неизвестный оператор '%s' в строке %d, столбец %d", lexeme, lineNum, colNum)
-					}
-				}
-				break
-			}
-			return nil, err
-		}
-
-		colNum += size
+// Функция лексического анализа текста файла name с обработкой директив препроцессора;
+// файлы из {$include} ищутся относительно каталога name
+func LexFile(name string, reader io.Reader) ([]Token, error) {
+	tokens, _, err := lexFileIncludes(name, reader)
+	return tokens, err
+}
 
-		switch state {
-		case "H":
-			if unicode.IsSpace(ch) {
-				if ch == '\n' {
-					lineNum++
-					colNum = 0
-				}
-				continue
-			} else if unicode.IsLetter(ch) {
-				sb.WriteRune(ch)
-				state = "ID"
-			} else if unicode.IsDigit(ch) {
-				sb.WriteRune(ch)
-				state = "NUM"
-			} else if isDelimiter(ch) {
-				// Обработка комментариев
-				if ch == '{' {
-					for {
-						ch, size, err = bufReader.ReadRune()
-						if err != nil {
-							return nil, fmt.Errorf("Некорректный комментарий: ожидался '}'")
-						}
-						if ch == '}' {
-							break
-						}
-						if ch == '\n' {
-							lineNum++
-							colNum = 0
-						} else {
-							colNum += size
-						}
-					}
-				} else {
-					tokens = append(tokens, Token{Type: TokenDelimiter, Lexeme: string(ch), LineNum: lineNum, ColNum: colNum})
-				}
-			} else if isOperator(string(ch)) {
-				sb.WriteRune(ch)
-				state = "OP"
-			} else {
-				return nil, fmt.Errorf("Неизвестный символ '%c' в строке %d, столбец %d", ch, lineNum, colNum)
-			}
-		case "ID":
-			if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
-				sb.WriteRune(ch)
-			} else {
-				lexeme := sb.String()
-				if isKeyword(lexeme) {
-					tokens = append(tokens, Token{Type: TokenKeyword, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme))})
-				} else if isOperator(lexeme) {
-					tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme))})
-				} else {
-					tokens = append(tokens, Token{Type: TokenIdentifier, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme))})
-				}
-				sb.Reset()
-				state = "H"
-				bufReader.UnreadRune()
-				colNum -= size
-			}
-		case "NUM":
-			if unicode.IsDigit(ch) || ch == '.' || ch == 'e' || ch == 'E' || ch == '+' || ch == '-' ||
-				ch == 'b' || ch == 'o' || ch == 'h' || ch == 'd' ||
-				(ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F') {
-				sb.WriteRune(ch)
-			} else {
-				lexeme := sb.String()
-				if isNumber(lexeme) {
-					// Проверяем, что следующий символ не является буквой или цифрой
-					if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
-						return nil, fmt.Errorf("Лексическая ошибка в строке %d, столбец %d: некорректное число '%s'", lineNum, colNum-len([]rune(lexeme)), lexeme+string(ch))
-					}
-					tokens = append(tokens, Token{Type: TokenNumber, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme))})
-				} else {
-					return nil, fmt.Errorf("Лексическая ошибка в строке %d, столбец %d: некорректное число '%s'", lineNum, colNum-len([]rune(lexeme)), lexeme)
-				}
-				sb.Reset()
-				state = "H"
-				bufReader.UnreadRune()
-				colNum -= size
-			}
+// То же, что LexFile; возвращает также абсолютные пути включённых файлов
+func lexFileIncludes(name string, reader io.Reader) ([]Token, []string, error) {
+	tokens, err := scan(reader, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	pp := newPreprocessor(name)
+	tokens, err = pp.run(tokens, name)
+	return tokens, pp.included, err
+}
 
-		case "OP":
-			lexeme := sb.String()
-			if isOperator(lexeme) {
-				tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - size + 1})
-				sb.Reset()
-				state = "H"
-				bufReader.UnreadRune()
-				colNum -= size
-			} else {
-				chNext, sizeNext, err := bufReader.ReadRune()
-				if err != nil && err != io.EOF {
-					return nil, err
-				}
-				if err == nil {
-					sb.WriteRune(chNext)
-					lexeme = sb.String()
-					if isOperator(lexeme) {
-						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - len([]rune(lexeme)) + 1})
-						sb.Reset()
-						state = "H"
-					} else {
-						// Если это не оператор, возвращаем последний прочитанный символ
-						sb.Reset()
-						sb.WriteRune(ch)
-						bufReader.UnreadRune()
-						colNum -= sizeNext
-						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: string(ch), LineNum: lineNum, ColNum: colNum - size + 1})
-						state = "H"
-					}
-				} else {
-					// EOF после оператора
-					if isOperator(lexeme) {
-						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: colNum - size + 1})
-						sb.Reset()
-						state = "H"
-					} else {
-						return nil, fmt.Errorf("Неизвестный оператор '%s' в строке %d, столбец %d", lexeme, lineNum, colNum-len([]rune(lexeme))+1)
-					}
-				}
-			}
+// Лексический анализ с сохранением комментариев (нужен форматировщику).
+// Директивы препроцессора не обрабатываются: форматирование текста с ними
+// потеряло бы включаемые файлы и исключённые фрагменты, поэтому считается ошибкой
+func LexerWithComments(reader io.Reader) ([]Token, []Token, error) {
+	var comments []Token
+	tokens, err := scan(reader, &comments)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tok := range tokens {
+		if tok.Type == TokenDirective {
+			return nil, nil, newDiagnostic(StageLexical, tok, "Текст с директивами препроцессора нельзя отформатировать")
 		}
 	}
-
-	return tokens, nil
+	return tokens, comments, nil
 }
 
 // Функция для преобразования типа токена в строку
@@ -274,6 +349,14 @@ func TokenTypeToString(t TokenType) string {
 		return "Number"
 	case TokenEOF:
 		return "EOF"
+	case TokenComment:
+		return "Comment"
+	case TokenString:
+		return "String"
+	case TokenChar:
+		return "Char"
+	case TokenDirective:
+		return "Directive"
 	default:
 		return "Unknown"
 	}
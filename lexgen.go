@@ -0,0 +1,929 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Генератор лексических анализаторов. Спецификация (tfi.lex) описывает классы
+// токенов регулярными выражениями с приоритетами; по ней строится НКА
+// (конструкция Томпсона), затем ДКА (построение подмножеств) и минимальный ДКА
+// (разбиение состояний на классы эквивалентности). Результат — таблицы lexTables,
+// которые выводятся в виде исходного текста на Go (lexgen_tables.go) и
+// по которым работает тот же цикл, что и у табличного анализатора scanner.go.
+//
+// Алфавит автомата — классы символов charClass: байты ASCII и четыре класса
+// символов Unicode. Символы, неразличимые для всех выражений спецификации,
+// объединяются в один столбец таблицы.
+
+//go:generate go run . lexgen -o lexgen_tables.go tfi.lex
+
+// Таблицы сгенерированного лексического анализатора
+type lexTables struct {
+	rules   []string          // класс токенов каждого правила спецификации
+	classes [numClasses]uint8 // столбец таблицы для каждого класса символов
+	next    [][]uint16        // переходы по столбцам; 0 — перехода нет, 1 — начальное состояние
+	accept  []uint8           // номер допускаемого правила + 1; 0 — состояние не допускающее
+	partial []uint8           // номер правила + 1, лексему которого читает недопускающее состояние
+}
+
+// Вид допускающего состояния для каждого класса токенов спецификации
+var lexTokenKinds = map[string]acceptKind{
+	"space":       acceptSpace,
+	"identifier":  acceptIdentifier,
+	"keyword":     acceptKeyword,
+	"operator":    acceptOperator,
+	"number":      acceptNumber,
+	"numberrange": acceptNumberRange,
+	"badnumber":   acceptBadNumber,
+	"delimiter":   acceptDelimiter,
+	"string":      acceptString,
+	"char":        acceptChar,
+	"comment":     acceptComment,
+	"directive":   acceptDirective,
+}
+
+// Правило спецификации: класс токенов, приоритет и выражение
+type lexRule struct {
+	name     string
+	priority int
+	expr     *reNode
+}
+
+type lexSpec struct {
+	rules []lexRule
+}
+
+// Разбор спецификации: строки «имя = выражение» (определение, подставляется
+// в выражения как {имя}) и «класс приоритет выражение» (правило);
+// пустые строки и строки, начинающиеся с '#', пропускаются
+func parseLexSpec(file string, src []byte) (*lexSpec, error) {
+	spec := &lexSpec{}
+	defs := make(map[string]*reNode)
+	for i, line := range strings.Split(string(src), "\n") {
+		where := fmt.Sprintf("%s:%d", file, i+1)
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, rest := cutField(text)
+		if !isSpecName(name) {
+			return nil, errorf("%s: ожидалось 'имя = выражение' или 'класс приоритет выражение'", where)
+		}
+
+		if strings.HasPrefix(rest, "=") {
+			expr := strings.TrimLeft(rest[1:], " \t")
+			if defs[name] != nil {
+				return nil, errorf("%s: повторное определение '%s'", where, name)
+			}
+			n, err := parseRegex(expr, defs, where, len(line)-len(strings.TrimLeft(line, " \t"))+len(text)-len(expr))
+			if err != nil {
+				return nil, err
+			}
+			defs[name] = n
+			continue
+		}
+
+		prio, expr := cutField(rest)
+		priority, err := strconv.Atoi(prio)
+		if err != nil || expr == "" {
+			return nil, errorf("%s: ожидалось 'имя = выражение' или 'класс приоритет выражение'", where)
+		}
+		if _, ok := lexTokenKinds[name]; !ok {
+			return nil, errorf("%s: неизвестный класс токенов '%s'", where, name)
+		}
+		n, err := parseRegex(expr, defs, where, len(line)-len(strings.TrimLeft(line, " \t"))+len(text)-len(expr))
+		if err != nil {
+			return nil, err
+		}
+		if n.nullable() {
+			return nil, errorf("%s: выражение класса '%s' допускает пустую строку", where, name)
+		}
+		spec.rules = append(spec.rules, lexRule{name: name, priority: priority, expr: n})
+	}
+	if len(spec.rules) == 0 {
+		return nil, errorf("%s: нет ни одного правила", file)
+	}
+	if len(spec.rules) > 254 {
+		return nil, errorf("%s: слишком много правил: %d", file, len(spec.rules))
+	}
+	return spec, nil
+}
+
+// Первое слово строки и остаток без начальных пробелов
+func cutField(s string) (string, string) {
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimLeft(s[i:], " \t")
+}
+
+func isSpecName(s string) bool {
+	if s == "" || !isLetter(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isLetter(s[i]) && !(s[i] >= '0' && s[i] <= '9') && s[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Множество классов символов
+type symSet [(numClasses + 63) / 64]uint64
+
+func (s *symSet) add(c int) {
+	s[c/64] |= 1 << (c % 64)
+}
+
+func (s *symSet) addString(chars string) {
+	for i := 0; i < len(chars); i++ {
+		s.add(int(chars[i]))
+	}
+}
+
+func (s symSet) has(c int) bool {
+	return s[c/64]&(1<<(c%64)) != 0
+}
+
+func (s symSet) union(t symSet) symSet {
+	for i := range s {
+		s[i] |= t[i]
+	}
+	return s
+}
+
+// Дополнение до всех классов символов
+func (s symSet) invert() symSet {
+	var r symSet
+	for c := 0; c < numClasses; c++ {
+		if !s.has(c) {
+			r.add(c)
+		}
+	}
+	return r
+}
+
+// Регулярное выражение
+type reOp uint8
+
+const (
+	reSet    reOp = iota // один символ из множества
+	reEmpty              // пустая строка
+	reConcat             // последовательность
+	reAlt                // альтернатива
+	reStar               // повторение 0 или более раз
+	rePlus               // повторение 1 или более раз
+	reQuest              // необязательная часть
+)
+
+type reNode struct {
+	op   reOp
+	set  symSet
+	subs []*reNode
+}
+
+// Выражение допускает пустую строку
+func (n *reNode) nullable() bool {
+	switch n.op {
+	case reSet:
+		return false
+	case reConcat:
+		for _, s := range n.subs {
+			if !s.nullable() {
+				return false
+			}
+		}
+		return true
+	case reAlt:
+		for _, s := range n.subs {
+			if s.nullable() {
+				return true
+			}
+		}
+		return false
+	case rePlus:
+		return n.subs[0].nullable()
+	}
+	return true
+}
+
+// Разбор регулярного выражения; where и col указывают место выражения
+// в спецификации для сообщений об ошибках
+type reParser struct {
+	expr  string
+	pos   int
+	defs  map[string]*reNode
+	where string
+	col   int
+}
+
+func parseRegex(expr string, defs map[string]*reNode, where string, col int) (*reNode, error) {
+	p := &reParser{expr: expr, defs: defs, where: where, col: col}
+	n, err := p.alt()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.expr) {
+		return nil, p.unexpected()
+	}
+	return n, nil
+}
+
+func (p *reParser) fail(format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", p.where, p.col+p.pos+1, sprintf(format, args...))
+}
+
+func (p *reParser) unexpected() error {
+	return p.fail("неожиданный символ '%c'", p.expr[p.pos])
+}
+
+func (p *reParser) alt() (*reNode, error) {
+	var alts []*reNode
+	for {
+		n, err := p.concat()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, n)
+		if p.pos >= len(p.expr) || p.expr[p.pos] != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return &reNode{op: reAlt, subs: alts}, nil
+}
+
+func (p *reParser) concat() (*reNode, error) {
+	var items []*reNode
+	for p.pos < len(p.expr) && p.expr[p.pos] != '|' && p.expr[p.pos] != ')' {
+		n, err := p.repeat()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+	}
+	switch len(items) {
+	case 0:
+		return &reNode{op: reEmpty}, nil
+	case 1:
+		return items[0], nil
+	}
+	return &reNode{op: reConcat, subs: items}, nil
+}
+
+func (p *reParser) repeat() (*reNode, error) {
+	n, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.expr) {
+		var op reOp
+		switch p.expr[p.pos] {
+		case '*':
+			op = reStar
+		case '+':
+			op = rePlus
+		case '?':
+			op = reQuest
+		default:
+			return n, nil
+		}
+		p.pos++
+		n = &reNode{op: op, subs: []*reNode{n}}
+	}
+	return n, nil
+}
+
+func (p *reParser) atom() (*reNode, error) {
+	switch p.expr[p.pos] {
+	case '(':
+		start := p.pos
+		p.pos++
+		n, err := p.alt()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.expr) {
+			p.pos = start
+			return nil, p.fail("незакрытая скобка '%c'", '(')
+		}
+		p.pos++
+		return n, nil
+	case '[':
+		return p.class()
+	case '{':
+		end := strings.IndexByte(p.expr[p.pos:], '}')
+		if end < 0 {
+			return nil, p.fail("незакрытая скобка '%c'", '{')
+		}
+		name := p.expr[p.pos+1 : p.pos+end]
+		def := p.defs[name]
+		if def == nil {
+			return nil, p.fail("неизвестное определение '%s'", name)
+		}
+		p.pos += end + 1
+		return def, nil
+	case '.':
+		p.pos++
+		var set symSet
+		set.add('\n')
+		return &reNode{op: reSet, set: set.invert()}, nil
+	case '*', '+', '?':
+		return nil, p.unexpected()
+	}
+	set, _, err := p.char()
+	if err != nil {
+		return nil, err
+	}
+	return &reNode{op: reSet, set: set}, nil
+}
+
+// Набор символов [...] или [^...]
+func (p *reParser) class() (*reNode, error) {
+	start := p.pos
+	p.pos++
+	negate := p.pos < len(p.expr) && p.expr[p.pos] == '^'
+	if negate {
+		p.pos++
+	}
+	var set symSet
+	for {
+		if p.pos >= len(p.expr) {
+			p.pos = start
+			return nil, p.fail("незакрытая скобка '%c'", '[')
+		}
+		if p.expr[p.pos] == ']' {
+			p.pos++
+			break
+		}
+		s, lo, err := p.char()
+		if err != nil {
+			return nil, err
+		}
+		if lo >= 0 && p.pos+1 < len(p.expr) && p.expr[p.pos] == '-' && p.expr[p.pos+1] != ']' {
+			p.pos++
+			from := p.pos
+			_, hi, err := p.char()
+			if err != nil {
+				return nil, err
+			}
+			if hi < lo {
+				p.pos = from
+				return nil, p.fail("некорректный диапазон символов")
+			}
+			for c := lo; c <= hi; c++ {
+				s.add(c)
+			}
+		}
+		set = set.union(s)
+	}
+	if negate {
+		set = set.invert()
+	}
+	return &reNode{op: reSet, set: set}, nil
+}
+
+// Символ или escape-последовательность: множество классов символов и сам
+// символ (-1 для \s и \p{...}, обозначающих несколько символов)
+func (p *reParser) char() (symSet, int, error) {
+	var set symSet
+	c := p.expr[p.pos]
+	if c >= utf8.RuneSelf {
+		r, _ := utf8.DecodeRuneInString(p.expr[p.pos:])
+		return set, 0, p.fail("символ '%c' вне ASCII: используйте \\p{L}, \\p{Nd} или \\s", r)
+	}
+	p.pos++
+	if c != '\\' {
+		set.add(int(c))
+		return set, int(c), nil
+	}
+	if p.pos >= len(p.expr) {
+		return set, 0, p.fail("выражение оканчивается на '\\'")
+	}
+	c = p.expr[p.pos]
+	p.pos++
+	switch c {
+	case 'n':
+		c = '\n'
+	case 't':
+		c = '\t'
+	case 'r':
+		c = '\r'
+	case 'v':
+		c = '\v'
+	case 'f':
+		c = '\f'
+	case 's':
+		set.addString(asciiSpaces)
+		set.add(classSpace)
+		return set, -1, nil
+	case 'p':
+		end := strings.IndexByte(p.expr[p.pos:], '}')
+		if !strings.HasPrefix(p.expr[p.pos:], "{") || end < 0 {
+			p.pos -= 2
+			return set, 0, p.fail("ожидалось \\p{L} или \\p{Nd}")
+		}
+		name := p.expr[p.pos+1 : p.pos+end]
+		switch name {
+		case "L":
+			set.addString(asciiLetters)
+			set.add(classLetter)
+		case "Nd":
+			set.addString(asciiDigits)
+			set.add(classDigit)
+		default:
+			p.pos -= 2
+			return set, 0, p.fail("неизвестный класс символов '\\p{%s}'", name)
+		}
+		p.pos += end + 1
+		return set, -1, nil
+	default:
+		if c >= utf8.RuneSelf || isLetter(c) || (c >= '0' && c <= '9') {
+			p.pos -= 2
+			return set, 0, p.fail("неизвестная escape-последовательность '\\%c'", c)
+		}
+	}
+	set.add(int(c))
+	return set, int(c), nil
+}
+
+// НКА: из состояния есть переход по символам set в next и пустые переходы eps
+type nfaState struct {
+	set  symSet
+	next int // -1, если перехода по символам нет
+	eps  []int
+	rule int // правило, которому принадлежит состояние
+}
+
+type nfaBuilder struct {
+	states []nfaState
+	rule   int
+}
+
+func (b *nfaBuilder) state() int {
+	b.states = append(b.states, nfaState{next: -1, rule: b.rule})
+	return len(b.states) - 1
+}
+
+func (b *nfaBuilder) eps(from, to int) {
+	b.states[from].eps = append(b.states[from].eps, to)
+}
+
+// Фрагмент НКА для выражения: начальное и конечное состояния
+func (b *nfaBuilder) build(n *reNode) (int, int) {
+	switch n.op {
+	case reSet:
+		s, e := b.state(), b.state()
+		b.states[s].set, b.states[s].next = n.set, e
+		return s, e
+	case reConcat:
+		s, e := b.build(n.subs[0])
+		for _, sub := range n.subs[1:] {
+			s2, e2 := b.build(sub)
+			b.eps(e, s2)
+			e = e2
+		}
+		return s, e
+	case reAlt:
+		s, e := b.state(), b.state()
+		for _, sub := range n.subs {
+			s2, e2 := b.build(sub)
+			b.eps(s, s2)
+			b.eps(e2, e)
+		}
+		return s, e
+	case reStar, rePlus, reQuest:
+		s, e := b.state(), b.state()
+		s2, e2 := b.build(n.subs[0])
+		b.eps(s, s2)
+		b.eps(e2, e)
+		if n.op != rePlus {
+			b.eps(s, e)
+		}
+		if n.op != reQuest {
+			b.eps(e2, s2)
+		}
+		return s, e
+	}
+	s := b.state()
+	return s, s
+}
+
+// Размеры автоматов, построенных по спецификации
+type lexStats struct {
+	nfa, dfa, minimal, columns int
+}
+
+// Построение таблиц по спецификации: НКА, ДКА и минимальный ДКА
+func buildLexTables(spec *lexSpec) (*lexTables, lexStats) {
+	var stats lexStats
+	b := &nfaBuilder{}
+	start := b.state()
+	final := make(map[int]int) // конечное состояние фрагмента -> правило
+	for i, r := range spec.rules {
+		b.rule = i
+		s, e := b.build(r.expr)
+		b.eps(start, s)
+		final[e] = i
+	}
+	b.states[start].rule = -1
+	stats.nfa = len(b.states)
+
+	// Столбцы: классы символов, одинаково входящие во все множества переходов
+	var t lexTables
+	for _, r := range spec.rules {
+		t.rules = append(t.rules, r.name)
+	}
+	columns := make(map[string]int)
+	var reps []int // представитель каждого столбца
+	for c := 0; c < numClasses; c++ {
+		var key []byte
+		for _, s := range b.states {
+			if s.next >= 0 {
+				key = strconv.AppendBool(key, s.set.has(c))
+			}
+		}
+		col, ok := columns[string(key)]
+		if !ok {
+			col = len(reps)
+			columns[string(key)] = col
+			reps = append(reps, c)
+		}
+		t.classes[c] = uint8(col)
+	}
+	stats.columns = len(reps)
+
+	// Построение подмножеств; состояние 0 — пустое множество (перехода нет)
+	closure := func(set []int) []int {
+		seen := make(map[int]bool)
+		stack := append([]int(nil), set...)
+		for _, s := range set {
+			seen[s] = true
+		}
+		for len(stack) > 0 {
+			s := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, e := range b.states[s].eps {
+				if !seen[e] {
+					seen[e] = true
+					stack = append(stack, e)
+				}
+			}
+		}
+		out := make([]int, 0, len(seen))
+		for s := range seen {
+			out = append(out, s)
+		}
+		sort.Ints(out)
+		return out
+	}
+	key := func(set []int) string {
+		var sb strings.Builder
+		for _, s := range set {
+			sb.WriteString(strconv.Itoa(s))
+			sb.WriteByte(',')
+		}
+		return sb.String()
+	}
+	sets := [][]int{nil, closure([]int{start})}
+	index := map[string]int{"": 0, key(sets[1]): 1}
+	next := [][]int{make([]int, len(reps))}
+	for i := 1; i < len(sets); i++ {
+		row := make([]int, len(reps))
+		for col, c := range reps {
+			var moved []int
+			for _, s := range sets[i] {
+				if st := b.states[s]; st.next >= 0 && st.set.has(c) {
+					moved = append(moved, st.next)
+				}
+			}
+			if moved == nil {
+				continue
+			}
+			target := closure(moved)
+			k := key(target)
+			n, ok := index[k]
+			if !ok {
+				n = len(sets)
+				index[k] = n
+				sets = append(sets, target)
+			}
+			row[col] = n
+		}
+		next = append(next, row)
+	}
+	stats.dfa = len(sets) - 1
+
+	// Допускаемое правило: самое длинное совпадение выбирает цикл анализатора,
+	// здесь — правило с наибольшим приоритетом, при равных — записанное раньше
+	better := func(a, b int) bool {
+		return b < 0 || spec.rules[a].priority > spec.rules[b].priority ||
+			(spec.rules[a].priority == spec.rules[b].priority && a < b)
+	}
+	accept := make([]int, len(sets))
+	partial := make([]int, len(sets))
+	for i, set := range sets {
+		accept[i], partial[i] = -1, -1
+		for _, s := range set {
+			if r, ok := final[s]; ok && better(r, accept[i]) {
+				accept[i] = r
+			}
+			if r := b.states[s].rule; r >= 0 && better(r, partial[i]) {
+				partial[i] = r
+			}
+		}
+		if accept[i] >= 0 {
+			partial[i] = -1
+		}
+	}
+
+	// Минимизация: начальное разбиение по допускаемому правилу, затем
+	// уточнение по блокам, в которые ведут переходы, пока число блоков растёт
+	block := make([]int, len(sets))
+	count := 0
+	for {
+		blocks := make(map[string]int)
+		newBlock := make([]int, len(sets))
+		for i := range sets {
+			k := fmt.Sprint(accept[i], partial[i])
+			if count > 0 {
+				k = fmt.Sprint(block[i])
+				for _, n := range next[i] {
+					k += "," + strconv.Itoa(block[n])
+				}
+			}
+			n, ok := blocks[k]
+			if !ok {
+				n = len(blocks)
+				blocks[k] = n
+			}
+			newBlock[i] = n
+		}
+		block = newBlock
+		if len(blocks) == count {
+			break
+		}
+		count = len(blocks)
+	}
+	stats.minimal = count - 1
+
+	// Состояния 0 и 1 — первые в своих блоках, поэтому номера блоков сохраняют их роль
+	t.next = make([][]uint16, count)
+	t.accept = make([]uint8, count)
+	t.partial = make([]uint8, count)
+	for i := range sets {
+		n := block[i]
+		if t.next[n] != nil {
+			continue
+		}
+		t.next[n] = make([]uint16, len(reps))
+		for col, to := range next[i] {
+			t.next[n][col] = uint16(block[to])
+		}
+		t.accept[n] = uint8(accept[i] + 1)
+		t.partial[n] = uint8(partial[i] + 1)
+	}
+	return &t, stats
+}
+
+// Анализатор по таблицам из lexgen_tables.go
+var generatedDFA = generatedTables.expand()
+
+// Автомат для цикла анализатора scanner.go
+func (t *lexTables) expand() *scannerDFA {
+	d := &scannerDFA{}
+	for s := range t.next {
+		var row [numClasses]uint16
+		for c := range row {
+			row[c] = t.next[s][t.classes[c]]
+		}
+		d.next = append(d.next, row)
+		accept, stuck := acceptNone, stuckUnknownChar
+		if r := t.accept[s]; r > 0 {
+			accept = lexTokenKinds[t.rules[r-1]]
+		}
+		if r := t.partial[s]; r > 0 && s != dfaStart {
+			switch t.rules[r-1] {
+			case "string", "char":
+				stuck = stuckString
+			case "comment", "directive":
+				stuck = stuckComment
+			}
+		}
+		d.accept = append(d.accept, accept)
+		d.stuck = append(d.stuck, stuck)
+		d.words = append(d.words, "")
+	}
+	return d
+}
+
+// Исходный текст на Go с таблицами t; spec — имя файла спецификации
+func (t *lexTables) goSource(spec string, stats lexStats) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by tfi lexgen from %s; DO NOT EDIT.\n\n", spec)
+	buf.WriteString("package main\n\n")
+	fmt.Fprintf(&buf, "// Таблицы лексического анализатора по спецификации %s: %d правил,\n", spec, len(t.rules))
+	fmt.Fprintf(&buf, "// %d столбцов, %d состояний (НКА — %d, ДКА до минимизации — %d)\n", stats.columns, stats.minimal, stats.nfa, stats.dfa)
+	buf.WriteString("var generatedTables = &lexTables{\n")
+	buf.WriteString("rules: []string{")
+	for i, r := range t.rules {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(strconv.Quote(r))
+	}
+	buf.WriteString("},\n")
+	buf.WriteString("classes: [numClasses]uint8{\n")
+	writeInts(&buf, len(t.classes), func(i int) int { return int(t.classes[i]) })
+	buf.WriteString("},\n")
+	buf.WriteString("next: [][]uint16{\n")
+	for _, row := range t.next {
+		buf.WriteString("{")
+		for i, n := range row {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(strconv.Itoa(int(n)))
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("},\n")
+	buf.WriteString("accept: []uint8{\n")
+	writeInts(&buf, len(t.accept), func(i int) int { return int(t.accept[i]) })
+	buf.WriteString("},\n")
+	buf.WriteString("partial: []uint8{\n")
+	writeInts(&buf, len(t.partial), func(i int) int { return int(t.partial[i]) })
+	buf.WriteString("},\n")
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+// Числа по 16 в строке
+func writeInts(buf *bytes.Buffer, n int, value func(int) int) {
+	for i := 0; i < n; i++ {
+		buf.WriteString(strconv.Itoa(value(i)))
+		buf.WriteString(",")
+		if i%16 == 15 || i == n-1 {
+			buf.WriteString("\n")
+		} else {
+			buf.WriteString(" ")
+		}
+	}
+}
+
+// Команда lexgen: вывод таблиц по спецификации или сравнение
+// сгенерированного анализатора с текущим (-check)
+func runLexgen(args []string) int {
+	fs := flag.NewFlagSet("lexgen", flag.ContinueOnError)
+	out := fs.String("o", "", tr("записать таблицы в файл вместо стандартного вывода"))
+	check := fs.Bool("check", false, tr("сравнить сгенерированный анализатор с текущим на указанных файлах"))
+	fs.StringVar(&lang, "lang", lang, tr("язык сообщений: ru, en"))
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if lang != "ru" && lang != "en" {
+		fmt.Fprintf(os.Stderr, tr("Неизвестный язык '%s'\n"), lang)
+		return exitUsage
+	}
+	if fs.NArg() == 0 || (!*check && fs.NArg() > 1) {
+		fmt.Fprintln(os.Stderr, tr("Использование: tfi lexgen [-o файл] <спецификация>"))
+		fmt.Fprintln(os.Stderr, tr("               tfi lexgen -check <спецификация> [файл...]"))
+		return exitUsage
+	}
+
+	file := fs.Arg(0)
+	src, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, tr("Ошибка при открытии файла: %v\n"), err)
+		return exitError
+	}
+	spec, err := parseLexSpec(file, src)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitSyntax
+	}
+	tables, stats := buildLexTables(spec)
+	if *check {
+		return checkLexer(file, tables, stats, fs.Args()[1:])
+	}
+
+	code, err := tables.goSource(filepath.Base(file), stats)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *out == "" {
+		os.Stdout.Write(code)
+		return exitOK
+	}
+	err = os.WriteFile(*out, code, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *out, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// Сравнение анализатора, построенного по спецификации, с текущим (scan)
+// на файлах корпуса; без файлов используется пример программы команды bench
+func checkLexer(file string, tables *lexTables, stats lexStats, files []string) int {
+	fmt.Printf(tr("%s: правил: %d; НКА: %d состояний; ДКА: %d, после минимизации: %d; столбцов таблицы: %d\n"),
+		file, len(tables.rules), stats.nfa, stats.dfa, stats.minimal, stats.columns)
+	code := exitOK
+	if reflect.DeepEqual(tables, generatedTables) {
+		fmt.Println(tr("Таблицы lexgen_tables.go соответствуют спецификации"))
+	} else {
+		fmt.Println(tr("Таблицы lexgen_tables.go построены не по этой спецификации или устарели: выполните go generate"))
+		code = exitError
+	}
+
+	generated := tables.expand()
+	corpus := map[string][]byte{}
+	names := files
+	for _, name := range files {
+		src, err := readSource(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return exitError
+		}
+		corpus[name] = src
+	}
+	if len(files) == 0 {
+		name := tr("(пример)")
+		corpus[name] = []byte(benchSample)
+		names = []string{name}
+	}
+	for _, name := range names {
+		c := compareScanners(generated, corpus[name])
+		switch {
+		case errorText(c.gotErr) != errorText(c.wantErr):
+			fmt.Printf(tr("%s: различие: %s, ожидалось: %s\n"), name, errorText(c.gotErr), errorText(c.wantErr))
+			code = exitError
+		case c.diff != "":
+			fmt.Printf(tr("%s: различие: %s\n"), name, c.diff)
+			code = exitError
+		case c.wantErr != nil:
+			fmt.Printf(tr("%s: совпадает (ошибка: %v)\n"), name, c.wantErr)
+		default:
+			fmt.Printf(tr("%s: совпадает (токенов: %d, комментариев: %d)\n"), name, c.tokens, c.comments)
+		}
+	}
+	return code
+}
+
+// Результат сравнения анализатора, построенного по спецификации, с текущим
+type scannerComparison struct {
+	tokens, comments int    // токенов и комментариев у текущего анализатора
+	wantErr, gotErr  error  // ошибки текущего и построенного анализаторов
+	diff             string // первое различие токенов или комментариев; "" — совпадают
+}
+
+func compareScanners(generated *scannerDFA, src []byte) scannerComparison {
+	var wantComments, gotComments []Token
+	want, wantErr := scan(bytes.NewReader(src), &wantComments)
+	got, gotErr := generated.scan(bytes.NewReader(src), &gotComments)
+	diff := tokensDiff(got, want)
+	if diff == "" {
+		diff = tokensDiff(gotComments, wantComments)
+	}
+	return scannerComparison{tokens: len(want), comments: len(wantComments), wantErr: wantErr, gotErr: gotErr, diff: diff}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return tr("нет ошибки")
+	}
+	return err.Error()
+}
+
+// Описание первого различия списков токенов; "" — списки совпадают
+func tokensDiff(got, want []Token) string {
+	for i := 0; i < len(got) && i < len(want); i++ {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			g, w := got[i], want[i]
+			return sprintf("строка %d, столбец %d: %s '%s', ожидался %s '%s' (строка %d, столбец %d)",
+				g.LineNum, g.ColNum, TokenTypeToString(g.Type), g.Lexeme,
+				TokenTypeToString(w.Type), w.Lexeme, w.LineNum, w.ColNum)
+		}
+	}
+	if len(got) != len(want) {
+		return sprintf("токенов: %d, ожидалось %d", len(got), len(want))
+	}
+	return ""
+}
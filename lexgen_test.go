@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// То же, что tfi lexgen -check tfi.lex на корпусе testdata/lexer: таблицы в
+// lexgen_tables.go построены по спецификации, а анализатор по ним выдаёт те же
+// токены, комментарии и ошибки, что и текущий. Файлы err_* содержат по одной
+// лексической ошибке.
+func TestLexgenCorpus(t *testing.T) {
+	src, err := os.ReadFile("tfi.lex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := parseLexSpec("tfi.lex", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, _ := buildLexTables(spec)
+	if !reflect.DeepEqual(tables, generatedTables) {
+		t.Error("таблицы lexgen_tables.go устарели: выполните go generate")
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "lexer", "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("корпус не найден: %v", err)
+	}
+	corpus := map[string][]byte{"(пример)": []byte(benchSample)}
+	for _, name := range append(files, "test.txt") {
+		text, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corpus[name] = text
+	}
+	// Анализатор по спецификации и анализатор по таблицам из lexgen_tables.go
+	scanners := map[string]*scannerDFA{"tfi.lex": tables.expand(), "lexgen_tables.go": generatedDFA}
+	for from, generated := range scanners {
+		for name, text := range corpus {
+			c := compareScanners(generated, text)
+			if errorText(c.gotErr) != errorText(c.wantErr) {
+				t.Errorf("%s, %s: ошибка %s, ожидалась %s", from, name, errorText(c.gotErr), errorText(c.wantErr))
+			} else if c.diff != "" {
+				t.Errorf("%s, %s: %s", from, name, c.diff)
+			}
+			if isErr := strings.HasPrefix(filepath.Base(name), "err_"); isErr != (c.wantErr != nil) {
+				t.Errorf("%s: ошибка анализатора %v", name, c.wantErr)
+			}
+		}
+	}
+}
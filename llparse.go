@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Команда ll: табличный предсказывающий анализатор, построенный по грамматике
+// tfi.ebnf (grammar.go). Анализатор только проверяет синтаксис: дерево разбора
+// и контекстные проверки остаются за ParseProgram, с результатом которого
+// сравнивается результат разбора каждого файла.
+
+// Терминал грамматики для токена
+func grammarTerminal(tok Token) string {
+	switch tok.Type {
+	case TokenIdentifier:
+		return "ident"
+	case TokenNumber:
+		return "number"
+	case TokenString:
+		return "string"
+	case TokenChar:
+		return "char"
+	case TokenEOF:
+		return endMarker
+	}
+	return `"` + tok.Lexeme + `"`
+}
+
+// Запись терминала в сообщении об ошибке
+func terminalText(term string) string {
+	switch term {
+	case "ident":
+		return tr("идентификатор")
+	case "number":
+		return tr("число")
+	case "string":
+		return tr("строка")
+	case "char":
+		return tr("символ")
+	case endMarker:
+		return tr("конец текста")
+	}
+	return "'" + term[1:len(term)-1] + "'"
+}
+
+// Перечисление ожидаемых терминалов: 'a', 'b' или 'c'
+func expectedText(terms []string) string {
+	var parts []string
+	for _, term := range terms {
+		parts = append(parts, terminalText(term))
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + tr(" или ") + parts[len(parts)-1]
+}
+
+// Разбор токенов по таблице LL(1); при trace != nil каждый шаг печатается:
+// содержимое стека (вершина справа), начало входа и действие
+func (t *llTable) parse(tokens []Token, trace io.Writer) error {
+	p := &Syntax{tokens: tokens}
+	stack := []string{endMarker, t.g.start}
+	for {
+		top := stack[len(stack)-1]
+		tok := p.currentToken()
+		term := grammarTerminal(tok)
+		if top == endMarker {
+			// Как и ParseProgram, токены после конца программы не рассматриваются
+			if trace != nil {
+				t.traceStep(trace, stack, p, tr("принять"))
+			}
+			return nil
+		}
+		stack = stack[:len(stack)-1]
+		if isTerminal(top) {
+			if top != term {
+				return p.errorAt(tok, "Ожидалось %s, получено %s '%s'", terminalText(top), TokenTypeToString(tok.Type), tok.Lexeme)
+			}
+			if trace != nil {
+				t.traceStep(trace, append(stack, top), p, sprintf("сдвиг %s", top))
+			}
+			p.nextToken()
+			continue
+		}
+		i, ok := t.cells[top][term]
+		if !ok {
+			return p.errorAt(tok, "Ожидалось %s, получено %s '%s'", expectedText(t.expected(top)), TokenTypeToString(tok.Type), tok.Lexeme)
+		}
+		if trace != nil {
+			t.traceStep(trace, append(stack, top), p, fmt.Sprintf("%d  %s", i+1, t.g.prods[i]))
+		}
+		rhs := t.g.prods[i].rhs
+		for j := len(rhs) - 1; j >= 0; j-- {
+			stack = append(stack, rhs[j])
+		}
+	}
+}
+
+// Строка трассировки: последние символы стека, три токена входа и действие
+func (t *llTable) traceStep(w io.Writer, stack []string, p *Syntax, action string) {
+	const depth, lookahead = 6, 3
+	shown := stack
+	prefix := ""
+	if len(shown) > depth {
+		shown = shown[len(shown)-depth:]
+		prefix = "… "
+	}
+	var input []string
+	for i := p.pos; i < len(p.tokens) && i < p.pos+lookahead; i++ {
+		input = append(input, p.tokens[i].Lexeme)
+	}
+	if p.pos+lookahead >= len(p.tokens) {
+		input = append(input, endMarker)
+	}
+	fmt.Fprintf(w, "%-60s | %-24s | %s\n", prefix+strings.Join(shown, " "), strings.Join(input, " "), action)
+}
+
+func runLL(args []string) int {
+	fs := flag.NewFlagSet("ll", flag.ContinueOnError)
+	grammarFile := fs.String("grammar", "", tr("файл грамматики EBNF вместо встроенной"))
+	printFlag := fs.String("print", "", tr("напечатать части анализатора через запятую: bnf, first, follow, table"))
+	trace := fs.Bool("trace", false, tr("печатать шаги разбора"))
+	fs.StringVar(&lang, "lang", lang, tr("язык сообщений: ru, en"))
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if lang != "ru" && lang != "en" {
+		fmt.Fprintf(os.Stderr, tr("Неизвестный язык '%s'\n"), lang)
+		return exitUsage
+	}
+	parts, ok := printParts(*printFlag, []string{"bnf", "first", "follow", "table"})
+	if !ok {
+		return exitUsage
+	}
+	g, code := loadGrammar(*grammarFile)
+	if g == nil {
+		return code
+	}
+	cycle := g.leftRecursion()
+	if cycle != nil {
+		fmt.Fprintf(os.Stderr, tr("%s:%d: левая рекурсия %s: грамматика не подходит для анализатора LL(1)\n"),
+			g.file, g.prods[g.byLHS[cycle[0]][0]].line, strings.Join(cycle, " ⇒ "))
+		return exitSyntax
+	}
+	table := buildLLTable(g)
+	table.printSummary(os.Stdout)
+	for _, part := range parts {
+		fmt.Println()
+		switch part {
+		case "bnf":
+			g.printBNF(os.Stdout)
+		case "first":
+			g.printSets(os.Stdout, "FIRST", g.first)
+		case "follow":
+			g.printSets(os.Stdout, "FOLLOW", g.follow)
+		case "table":
+			table.printTable(os.Stdout)
+		}
+	}
+
+	var w io.Writer
+	if *trace {
+		w = os.Stdout
+	}
+	for _, name := range fs.Args() {
+		code = max(code, checkGrammarParse(name, func(tokens []Token) error {
+			return table.parse(tokens, w)
+		}))
+	}
+	return code
+}
+
+// Части анализатора для печати из значения флага -print
+func printParts(value string, allowed []string) ([]string, bool) {
+	if value == "" {
+		return nil, true
+	}
+	parts := strings.Split(value, ",")
+	for _, part := range parts {
+		if !contains(allowed, part) {
+			fmt.Fprintf(os.Stderr, tr("Неизвестная часть анализатора '%s': ожидалось %s\n"), part, strings.Join(allowed, ", "))
+			return nil, false
+		}
+	}
+	return parts, true
+}
+
+// Встроенная грамматика или грамматика из файла; при ошибке — nil и код завершения
+func loadGrammar(file string) (*grammar, int) {
+	src := []byte(builtinGrammar)
+	if file == "" {
+		file = "tfi.ebnf"
+	} else {
+		var err error
+		src, err = os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Ошибка при открытии файла: %v\n"), err)
+			return nil, exitError
+		}
+	}
+	g, err := parseGrammar(file, string(src))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, exitSyntax
+	}
+	return g, exitOK
+}
+
+// Разбор файла анализатором, построенным по грамматике, и сравнение с ParseProgram
+func checkGrammarParse(name string, parse func([]Token) error) int {
+	src, err := readSource(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return exitError
+	}
+	tokens, err := LexFile(name, bytes.NewReader(src))
+	if err != nil {
+		renderError(os.Stderr, name, src, err)
+		return exitLexical
+	}
+	if isUnitSource(tokens) {
+		fmt.Printf(tr("%s: модуль пропущен: грамматика описывает только программы\n"), name)
+		return exitOK
+	}
+	grammarErr := parse(tokens)
+	parser := Syntax{tokens: tokens, pos: 0, file: name}
+	_, err = parser.ParseProgram()
+	switch {
+	case grammarErr != nil:
+		renderError(os.Stderr, name, src, grammarErr)
+		if err == nil {
+			fmt.Printf(tr("%s: различие: ParseProgram принимает программу\n"), name)
+		}
+		return exitSyntax
+	case err != nil:
+		// Контекстное ограничение, которое грамматика не описывает
+		fmt.Printf(tr("%s: принят; ParseProgram: %v\n"), name, err)
+	default:
+		fmt.Printf(tr("%s: принят\n"), name)
+	}
+	return exitOK
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Анализаторы по грамматике tfi.ebnf (LL(1) и LALR(1)) и ParseProgram
+// одинаково разбирают корпус testdata/parser: ok_* принимают все, err_* все
+// отвергают на одном и том же токене, а sem_* нарушают контекстные ограничения,
+// которые грамматика не описывает, и отвергаются только ParseProgram.
+func TestGrammarParsersAgree(t *testing.T) {
+	g, err := parseGrammar("tfi.ebnf", builtinGrammar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ll := buildLLTable(g)
+	lalr := buildLALRTable(g)
+	parsers := map[string]func([]Token) error{
+		"ll":   func(tokens []Token) error { return ll.parse(tokens, nil) },
+		"lalr": func(tokens []Token) error { return lalr.parse(tokens, nil) },
+	}
+
+	// Единственный конфликт грамматики — висячий else (см. README)
+	var conflicts []string
+	for _, c := range ll.conflicts {
+		var prods []string
+		for _, p := range c.prods {
+			prods = append(prods, g.prods[p].String())
+		}
+		conflicts = append(conflicts, fmt.Sprintf("ll %s %s: %s", c.nonterm, c.term, strings.Join(prods, " | ")))
+	}
+	for _, c := range lalr.conflicts {
+		kind := "свёртка/свёртка"
+		if c.shift >= 0 {
+			kind = "сдвиг/свёртка"
+		}
+		var prods []string
+		for _, p := range c.reduces {
+			prods = append(prods, g.prods[p].String())
+		}
+		conflicts = append(conflicts, fmt.Sprintf("lalr %s %s: %s", kind, c.term, strings.Join(prods, " | ")))
+	}
+	want := []string{
+		`ll OtherStatement.1 "else": OtherStatement.1 → "else" Statement | OtherStatement.1 → ε`,
+		`lalr сдвиг/свёртка "else": OtherStatement.1 → ε`,
+	}
+	if strings.Join(conflicts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("конфликты:\n%s\nожидалось:\n%s", strings.Join(conflicts, "\n"), strings.Join(want, "\n"))
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "parser", "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("корпус не найден: %v", err)
+	}
+	for _, name := range append(files, "test.txt") {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := LexFile(name, strings.NewReader(string(src)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		parser := Syntax{tokens: tokens, pos: 0, file: name}
+		_, want := parser.ParseProgram()
+		base := filepath.Base(name)
+		rejected := strings.HasPrefix(base, "err_") || strings.HasPrefix(base, "sem_")
+		if rejected != (want != nil) {
+			t.Errorf("%s: ParseProgram: %v", name, want)
+			continue
+		}
+		for kind, parse := range parsers {
+			got := parse(tokens)
+			switch {
+			case strings.HasPrefix(base, "err_"):
+				if got == nil {
+					t.Errorf("%s: %s принимает программу, ParseProgram: %v", name, kind, want)
+				} else if g, w := asDiagnostic(got).Tok, asDiagnostic(want).Tok; g.LineNum != w.LineNum || g.ColNum != w.ColNum {
+					t.Errorf("%s: %s: ошибка на %d:%d (%v), ParseProgram — на %d:%d (%v)",
+						name, kind, g.LineNum, g.ColNum, got, w.LineNum, w.ColNum, want)
+				}
+			case got != nil:
+				t.Errorf("%s: %s: %v", name, kind, got)
+			}
+		}
+	}
+}
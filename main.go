@@ -1,41 +1,492 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
+// Коды завершения. При обработке нескольких файлов возвращается наибольший из кодов.
+const (
+	exitOK      = 0
+	exitError   = 1 // ошибка ввода-вывода
+	exitUsage   = 2 // неверные аргументы командной строки
+	exitLexical = 3 // лексическая ошибка
+	exitSyntax  = 4 // синтаксическая ошибка
+	exitRuntime = 5 // ошибка выполнения программы
+)
+
+// Параметры командной строки
+type options struct {
+	format string
+	from   string
+	write  bool
+	list   bool
+	diff   bool
+	sarif  *sarifLog // общий отчёт SARIF для всех файлов
+}
+
+// Подкоманда tfi
+type command struct {
+	name    string
+	usage   string
+	formats []string
+	run     func(opts *options, name string, src []byte) int
+}
+
+var commands = []*command{
+	{name: "tokens", usage: "вывести таблицу токенов", formats: []string{"text", "jsonl", "csv"}, run: runTokens},
+	{name: "parse", usage: "вывести дерево разбора", formats: []string{"text", "json", "sexpr", "dot"}, run: runParse},
+	{name: "check", usage: "проверить программу без вывода при успехе", formats: []string{"text", "json", "sarif"}, run: runCheck},
+	{name: "run", usage: "выполнить программу", formats: []string{"text"}, run: runRun},
+	{name: "fmt", usage: "отформатировать программу", formats: []string{"text"}, run: runFmt},
+	{name: "fix", usage: "исправить ошибки, для которых известно исправление", formats: []string{"text"}, run: runFix},
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Использование: go run main.go <имя файла>")
-		return
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+func runCLI(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
 	}
 
-	fileName := os.Args[1]
-	file, err := os.Open(fileName)
+	name := args[0]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return exitOK
+	case "dap":
+		// Сервер отладки (Debug Adapter Protocol) через stdin/stdout
+		server := NewDAPServer(os.Stdin, os.Stdout)
+		err := server.Serve()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Ошибка сервера отладки: %v\n"), err)
+			return exitError
+		}
+		return exitOK
+	case "bench":
+		return runBench(args[1:])
+	case "lexgen":
+		return runLexgen(args[1:])
+	case "ll":
+		return runLL(args[1:])
+	case "lalr":
+		return runLALR(args[1:])
+	}
+
+	var cmd *command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, tr("Неизвестная команда '%s'\n"), name)
+		usage(os.Stderr)
+		return exitUsage
+	}
+
+	opts := &options{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.format, "format", "text", tr("формат вывода: ")+strings.Join(cmd.formats, ", "))
+	fs.StringVar(&lang, "lang", lang, tr("язык сообщений: ru, en"))
+	fs.StringVar(&opts.from, "from", "source", tr("вход: source — текст программы, json — дерево разбора в JSON"))
+	fs.StringVar(&colorMode, "color", colorMode, tr("раскраска сообщений об ошибках: auto, always, never"))
+	fs.StringVar(&shadowMode, "shadow", shadowMode, tr("скрытие внешнего имени переменной блока: warning, error"))
+	fs.StringVar(&unitPath, "path", unitPath, tr("каталоги поиска модулей, разделённые ':' (';' в Windows)"))
+	fs.BoolVar(&bigInts, "bigint", bigInts, tr("целые числа произвольной длины вместо 64-битных"))
+	if name == "fmt" {
+		fs.BoolVar(&opts.write, "w", false, tr("записать результат в исходный файл"))
+		fs.BoolVar(&opts.list, "l", false, tr("вывести имена файлов, форматирование которых отличается"))
+	}
+	if name == "fix" {
+		fs.BoolVar(&opts.diff, "d", false, tr("вывести изменения в виде diff, не изменяя файлы"))
+	}
+	err := fs.Parse(args[1:])
 	if err != nil {
-		fmt.Printf("Ошибка при открытии файла: %v\n", err)
-		return
+		return exitUsage
+	}
+	if lang != "ru" && lang != "en" {
+		fmt.Fprintf(os.Stderr, tr("Неизвестный язык '%s'\n"), lang)
+		return exitUsage
+	}
+	if colorMode != "auto" && colorMode != "always" && colorMode != "never" {
+		fmt.Fprintf(os.Stderr, tr("Неизвестный режим раскраски '%s'\n"), colorMode)
+		return exitUsage
+	}
+	if shadowMode != "warning" && shadowMode != "error" {
+		fmt.Fprintf(os.Stderr, tr("Неизвестный режим проверки скрытия имён '%s'\n"), shadowMode)
+		return exitUsage
+	}
+	if opts.from != "source" && opts.from != "json" {
+		fmt.Fprintf(os.Stderr, tr("Неизвестный вид входных данных '%s'\n"), opts.from)
+		return exitUsage
 	}
-	defer file.Close()
+	if !contains(cmd.formats, opts.format) {
+		fmt.Fprintf(os.Stderr, tr("Команда '%s' не поддерживает формат '%s'\n"), name, opts.format)
+		return exitUsage
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	code := exitOK
+	for _, file := range files {
+		src, err := readSource(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Ошибка при открытии файла: %v\n"), err)
+			code = max(code, exitError)
+			continue
+		}
+		code = max(code, cmd.run(opts, file, src))
+	}
+	if opts.sarif != nil {
+		err := opts.sarif.write(os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return max(code, exitError)
+		}
+	}
+	return code
+}
 
-	// Лексический анализ
-	tokens, err := Lexer(file)
+func usage(w io.Writer) {
+	fmt.Fprintln(w, tr("Использование: tfi <команда> [флаги] <файл>..."))
+	fmt.Fprintln(w, tr("Вместо имени файла можно указать '-' для чтения из стандартного ввода."))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, tr("Команды:"))
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, tr(c.usage))
+	}
+	fmt.Fprintf(w, "  %-8s %s\n", "dap", tr("запустить сервер отладки (Debug Adapter Protocol)"))
+	fmt.Fprintf(w, "  %-8s %s\n", "bench", tr("сравнить скорость табличного и посимвольного лексического анализатора"))
+	fmt.Fprintf(w, "  %-8s %s\n", "lexgen", tr("построить таблицы лексического анализатора по спецификации"))
+	fmt.Fprintf(w, "  %-8s %s\n", "ll", tr("построить LL(1)-анализатор по грамматике и проверить им программы"))
+	fmt.Fprintf(w, "  %-8s %s\n", "lalr", tr("построить LALR(1)-анализатор по грамматике и проверить им программы"))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, tr("Флаги:"))
+	fmt.Fprintln(w, tr("  -format  формат вывода (text, json, jsonl, csv, sexpr, dot, sarif)"))
+	fmt.Fprintln(w, tr("  -from    вид входных данных (source, json)"))
+	fmt.Fprintln(w, tr("  -lang    язык сообщений (ru, en)"))
+	fmt.Fprintln(w, tr("  -color   раскраска сообщений об ошибках (auto, always, never)"))
+	fmt.Fprintln(w, tr("  -shadow  скрытие внешнего имени переменной блока (warning, error)"))
+	fmt.Fprintln(w, tr("  -path    каталоги поиска модулей (кроме каталога программы)"))
+	fmt.Fprintln(w, tr("  -bigint  целые числа произвольной длины"))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Чтение исходного текста из файла или из стандартного ввода ("-")
+func readSource(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// Лексический и синтаксический анализ (или загрузка дерева из JSON при -from=json);
+// для файла модуля возвращается модуль вместо программы. Возвращает код завершения для ошибки
+func parseSource(opts *options, name string, src []byte) (*Program, *Unit, int) {
+	if opts.from == "json" {
+		prog, warnings, err := readProgramJSON(bytes.NewReader(src), name)
+		if err != nil {
+			if asDiagnostic(err) != nil {
+				// Ошибка проверки дерева или подключённого модуля: исходного текста нет
+				renderError(os.Stderr, name, nil, err)
+			} else {
+				fmt.Fprintf(os.Stderr, tr("%s: Ошибка чтения дерева разбора: %v\n"), name, err)
+			}
+			return nil, nil, exitSyntax
+		}
+		for _, w := range warnings {
+			renderError(os.Stderr, name, nil, w)
+		}
+		return prog, nil, exitOK
+	}
+	tokens, err := LexFile(name, bytes.NewReader(src))
 	if err != nil {
-		fmt.Printf("Ошибка лексического анализа: %v\n", err)
-		return
+		renderError(os.Stderr, name, src, err)
+		return nil, nil, exitLexical
+	}
+	parser := Syntax{tokens: tokens, pos: 0, file: name}
+	var prog *Program
+	var unit *Unit
+	if isUnitSource(tokens) {
+		unit, err = parser.ParseUnit()
+	} else {
+		prog, err = parser.ParseProgram()
+	}
+	if err != nil {
+		renderError(os.Stderr, name, src, err)
+		return nil, nil, exitSyntax
+	}
+	for _, w := range parser.Warnings {
+		renderError(os.Stderr, name, src, w)
 	}
+	return prog, unit, exitOK
+}
 
-	for _, token := range tokens {
-		fmt.Printf("Token: %-15s Lexeme: %-10s Line: %d Col: %d\n", TokenTypeToString(token.Type), token.Lexeme, token.LineNum, token.ColNum)
+func runTokens(opts *options, name string, src []byte) int {
+	tokens, err := LexFile(name, bytes.NewReader(src))
+	if err != nil {
+		renderError(os.Stderr, name, src, err)
+		return exitLexical
+	}
+	switch opts.format {
+	case "jsonl":
+		err = writeTokensJSONL(os.Stdout, tokens)
+	case "csv":
+		err = writeTokensCSV(os.Stdout, tokens)
+	default:
+		for _, token := range tokens {
+			fmt.Printf("Token: %-15s Lexeme: %-10s Line: %d Col: %d\n", TokenTypeToString(token.Type), token.Lexeme, token.LineNum, token.ColNum)
+		}
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return exitError
+	}
+	return exitOK
+}
 
-	// Синтаксический анализ
-	parser := Syntax{tokens: tokens, pos: 0}
-	err = parser.ParseProgram()
+func runParse(opts *options, name string, src []byte) int {
+	prog, unit, code := parseSource(opts, name, src)
+	if unit != nil {
+		if opts.format != "text" {
+			fmt.Fprintf(os.Stderr, tr("%s: формат '%s' не поддерживается для модуля\n"), name, opts.format)
+			return exitUsage
+		}
+		dumpUnit(os.Stdout, unit)
+		return exitOK
+	}
+	if prog == nil {
+		return code
+	}
+	var err error
+	switch opts.format {
+	case "json":
+		err = writeProgramJSON(os.Stdout, prog)
+	case "sexpr":
+		err = writeProgramSexpr(os.Stdout, prog)
+	case "dot":
+		err = writeProgramDOT(os.Stdout, prog)
+	default:
+		dumpTree(os.Stdout, prog)
+	}
 	if err != nil {
-		fmt.Printf("Ошибка синтаксического анализа: %v\n", err)
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// Проверка файла для check -format=json и -format=sarif: текст программы
+// разбирается, а при -from=json загружается дерево разбора
+func checkInput(opts *options, name string, src []byte) ([]*Diagnostic, error) {
+	if opts.from == "json" {
+		_, warnings, err := readProgramJSON(bytes.NewReader(src), name)
+		return warnings, err
+	}
+	return checkSource(name, src)
+}
+
+func runCheck(opts *options, name string, src []byte) int {
+	if opts.format == "sarif" {
+		// Отчёт выводится после обработки всех файлов
+		if opts.sarif == nil {
+			opts.sarif = newSarifLog()
+		}
+		diags, err := checkInput(opts, name, src)
+		code := exitOK
+		if opts.from == "json" {
+			// Позиции вне модулей относятся к исходному тексту, которого нет
+			src = nil
+			if err != nil && asDiagnostic(err) == nil {
+				fmt.Fprintf(os.Stderr, tr("%s: Ошибка чтения дерева разбора: %v\n"), name, err)
+				return exitSyntax
+			}
+		}
+		if d := asDiagnostic(err); d != nil {
+			diags = append(diags, d)
+			code = exitSyntax
+			if d.Stage == StageLexical {
+				code = exitLexical
+			}
+		}
+		opts.sarif.addFile(name, src, diags)
+		return code
+	}
+	if opts.format == "json" {
+		// Ошибки выводятся в stdout в виде JSON, по одной строке на файл
+		result := map[string]any{"file": name, "ok": true}
+		diags, err := checkInput(opts, name, src)
+		code := exitOK
+		if len(diags) > 0 {
+			warnings := []map[string]any{}
+			for _, w := range diags {
+				warnings = append(warnings, map[string]any{
+					"code": w.Code, "message": w.Message, "line": w.Tok.LineNum, "column": w.Tok.ColNum,
+				})
+			}
+			result["warnings"] = warnings
+		}
+		if d := asDiagnostic(err); d != nil {
+			code = exitSyntax
+			if d.Stage == StageLexical {
+				code = exitLexical
+			}
+			result["ok"], result["stage"], result["message"], result["code"] = false, d.Stage, d.Message, d.Code
+			result["line"], result["column"] = d.Tok.LineNum, d.Tok.ColNum
+			if d.Tok.File != "" {
+				// Ошибка в подключённом модуле
+				result["source"] = displayPath(d.Tok.File)
+			}
+			if d.Help != "" {
+				result["help"] = d.Help
+			}
+			if len(d.Fixes) > 0 {
+				result["fixes"] = fixesToJSON(d.Fixes)
+			}
+		} else if err != nil && opts.from == "json" {
+			code = exitSyntax
+			result["ok"], result["stage"], result["message"] = false, "json", err.Error()
+		} else if err != nil {
+			code = exitLexical
+			result["ok"], result["stage"], result["message"] = false, "lexical", err.Error()
+		}
+		data, _ := json.Marshal(result)
+		fmt.Println(string(data))
+		return code
+	}
+
+	_, _, code := parseSource(opts, name, src)
+	return code
+}
+
+func runRun(opts *options, name string, src []byte) int {
+	prog, unit, code := parseSource(opts, name, src)
+	if unit != nil {
+		fmt.Fprintf(os.Stderr, tr("%s: модуль нельзя выполнить: он подключается к программе через uses\n"), name)
+		return exitUsage
+	}
+	if prog == nil {
+		return code
+	}
+	interp := NewInterpreter(os.Stdin, os.Stdout)
+	err := interp.Run(prog)
+	if err != nil {
+		if opts.from == "json" {
+			// Исходного текста нет: выводится только заголовок сообщения
+			src = nil
+		}
+		renderError(os.Stderr, name, src, err)
+		return exitRuntime
+	}
+	return exitOK
+}
+
+func runFmt(opts *options, name string, src []byte) int {
+	var prog *Program
+	var unit *Unit
+	var comments []Token
+	if opts.from == "json" {
+		var code int
+		prog, _, code = parseSource(opts, name, src)
+		if prog == nil {
+			return code
+		}
+		// Результат печатается в stdout: исходный JSON-файл не перезаписывается
+		opts.write = false
 	} else {
-		fmt.Println("Синтаксический анализ успешно завершен.")
+		tokens, cs, err := LexerWithComments(bytes.NewReader(src))
+		if err != nil {
+			renderError(os.Stderr, name, src, err)
+			return exitLexical
+		}
+		parser := Syntax{tokens: tokens, pos: 0, file: name}
+		if isUnitSource(tokens) {
+			unit, err = parser.ParseUnit()
+		} else {
+			prog, err = parser.ParseProgram()
+		}
+		if err != nil {
+			renderError(os.Stderr, name, src, err)
+			return exitSyntax
+		}
+		comments = cs
+	}
+
+	var out string
+	if unit != nil {
+		out = FormatUnit(unit, comments)
+	} else {
+		out = Format(prog, comments)
+	}
+	if opts.list {
+		if out != string(src) {
+			fmt.Println(name)
+		}
+	}
+	if opts.write && name != "-" {
+		if out != string(src) {
+			err := os.WriteFile(name, []byte(out), 0644)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				return exitError
+			}
+		}
+		return exitOK
+	}
+	if !opts.list {
+		fmt.Print(out)
+	}
+	return exitOK
+}
+
+func runFix(opts *options, name string, src []byte) int {
+	if opts.from == "json" {
+		fmt.Fprintf(os.Stderr, tr("%s: команда fix работает только с текстом программы\n"), name)
+		return exitUsage
+	}
+	fixed, applied, err := fixSource(name, src)
+	for _, a := range applied {
+		fmt.Fprintf(os.Stderr, tr("%s:%d:%d: исправлено: %s\n"), name, a.Diag.Tok.LineNum, a.Diag.Tok.ColNum, a.Fix.Message)
+	}
+
+	if opts.diff {
+		fmt.Print(unifiedDiff(name, name, string(src), string(fixed)))
+	} else if name == "-" {
+		fmt.Print(string(fixed))
+	} else if len(applied) > 0 {
+		werr := os.WriteFile(name, fixed, 0644)
+		if werr != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, werr)
+			return exitError
+		}
+	}
+
+	if err != nil {
+		// Ошибка, которую не удалось исправить автоматически
+		renderError(os.Stderr, name, fixed, err)
+		if d := asDiagnostic(err); d != nil && d.Stage == StageLexical {
+			return exitLexical
+		}
+		return exitSyntax
 	}
+	return exitOK
 }
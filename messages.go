@@ -0,0 +1,447 @@
+package main
+
+import "fmt"
+
+// Язык сообщений: "ru" (по умолчанию) или "en"; задаётся флагом -lang
+var lang = "ru"
+
+// Переводы сообщений на английский язык. Ключом служит исходная (русская)
+// строка формата, поэтому в коде сообщения остаются на русском языке.
+var messagesEn = map[string]string{
+	// Лексический анализ
+	"некорректное число '%s'":                                        "invalid number '%s'",
+	"Неизвестная операция '%s'":                                      "Unknown operator '%s'",
+	"Неизвестный символ '%c'":                                        "Unknown character '%c'",
+	"Некорректный комментарий: ожидался '}'":                         "Invalid comment: expected '}'",
+	"некорректное вещественное число '%s'":                           "invalid floating-point number '%s'",
+	"целое число '%s' не помещается в 64 бита":                       "integer '%s' does not fit in 64 bits",
+	"Неизвестная escape-последовательность '%s'":                     "Unknown escape sequence '%s'",
+	"Незакрытая константа: ожидалась %c":                             "Unterminated literal: expected %c",
+	"Символьная константа должна содержать один символ, получено %d": "Character literal must contain exactly one character, got %d",
+	"Директива {$%s} требует аргумента":                              "Directive {$%s} requires an argument",
+	"Директива {$%s} без {$ifdef}":                                   "Directive {$%s} without {$ifdef}",
+	"Повторная директива {$else}":                                    "Duplicate {$else} directive",
+	"Неизвестная директива '%s'":                                     "Unknown directive '%s'",
+	"Не закрыта директива {$%s}: ожидалась {$endif}":                 "Unclosed {$%s} directive: expected {$endif}",
+	"Рекурсивное включение файла '%s'":                               "Recursive inclusion of file '%s'",
+	"Не удалось включить файл '%s': %v":                              "Cannot include file '%s': %v",
+	"Текст с директивами препроцессора нельзя отформатировать":       "Source with preprocessor directives cannot be formatted",
+	"цифра '%c' недопустима в числе '%s' с основанием %d":            "digit '%c' is not allowed in base-%[3]d number '%[2]s'",
+	"шестнадцатеричное число '%s' должно оканчиваться на 'h'":        "hexadecimal number '%s' must end with 'h'",
+	"недопустимый символ '%c' в числе '%s'":                          "invalid character '%c' in number '%s'",
+	"после десятичной точки в числе '%s' ожидалась цифра":            "expected a digit after the decimal point in number '%s'",
+	"в порядке числа '%s' ожидалась цифра":                           "expected a digit in the exponent of number '%s'",
+	"некорректная строковая константа %s":                            "invalid string literal %s",
+
+	// Синтаксический анализ
+	"Ожидалось %s '%s', получено %s '%s'":                                 "Expected %s '%s', got %s '%s'",
+	"Ожидался идентификатор, получено %s '%s'":                            "Expected identifier, got %s '%s'",
+	"Переменная '%s' уже объявлена":                                       "Variable '%s' is already declared",
+	"Ожидалось ',' или ':', получено %s '%s'":                             "Expected ',' or ':', got %s '%s'",
+	"Ожидался тип, получено %s '%s'":                                      "Expected type, got %s '%s'",
+	"Ожидалось ';', получено %s '%s'":                                     "Expected ';', got %s '%s'",
+	"Ожидалось ';' или 'end', получено %s '%s'":                           "Expected ';' or 'end', got %s '%s'",
+	"Неизвестный оператор '%s'":                                           "Unknown statement '%s'",
+	"Ожидался оператор, получено %s '%s'":                                 "Expected statement, got %s '%s'",
+	"Ожидалось ':' или ']' в составном операторе, получено %s '%s'":       "Expected ':' or ']' in compound statement, got %s '%s'",
+	"Ожидался идентификатор в присваивании, получено %s '%s'":             "Expected identifier in assignment, got %s '%s'",
+	"Необъявленная переменная '%s'":                                       "Undeclared variable '%s'",
+	"Ожидался идентификатор в read, получено %s '%s'":                     "Expected identifier in read, got %s '%s'",
+	"Ожидалось ',' или ')', получено %s '%s'":                             "Expected ',' or ')', got %s '%s'",
+	"Ожидался фактор, получено %s '%s'":                                   "Expected factor, got %s '%s'",
+	"Имя '%s' уже объявлено":                                              "Name '%s' is already declared",
+	"Ожидалось имя подпрограммы, получено %s '%s'":                        "Expected subprogram name, got %s '%s'",
+	"Ожидалось ';' или ')', получено %s '%s'":                             "Expected ';' or ')', got %s '%s'",
+	"'%s' не является переменной":                                         "'%s' is not a variable",
+	"'%s' не является процедурой или функцией":                            "'%s' is not a procedure or function",
+	"Необъявленная подпрограмма '%s'":                                     "Undeclared subprogram '%s'",
+	"Процедура '%s' не возвращает значения":                               "Procedure '%s' does not return a value",
+	"Подпрограмма '%s' ожидает аргументов: %d, передано: %d":              "Subprogram '%s' expects %d argument(s), got %d",
+	"Оператор return допустим только в процедуре или функции":             "return is allowed only in a procedure or function",
+	"Ожидалась целая константа в границе массива, получено %s '%s'":       "Expected integer constant as array bound, got %s '%s'",
+	"Нижняя граница массива %d больше верхней %d":                         "Array lower bound %d is greater than upper bound %d",
+	"Массив из %s элементов слишком велик (не более %d)":                  "Array of %s elements is too large (at most %d)",
+	"'%s' не является массивом":                                           "'%s' is not an array",
+	"Индекс %d вне границ массива '%s' [%d..%d]":                          "Index %d is out of bounds of array '%s' [%d..%d]",
+	"Переменную '%s' типа %s нельзя прочитать":                            "Variable '%s' of type %s cannot be read",
+	"Неизвестный тип '%s'":                                                "Unknown type '%s'",
+	"'%s' не является типом":                                              "'%s' is not a type",
+	"Поле '%s' уже объявлено":                                             "Field '%s' is already declared",
+	"'%s' не является записью":                                            "'%s' is not a record",
+	"Ожидалось имя поля, получено %s '%s'":                                "Expected field name, got %s '%s'",
+	"Некорректное константное выражение: %s":                              "Invalid constant expression: %s",
+	"Нельзя изменить константу '%s'":                                      "Cannot modify constant '%s'",
+	"Метка case должна быть целой или логической константой, получено %s": "Case label must be an integer or boolean constant, got %s",
+	"Метка case '%s' имеет тип %s, а первая метка — тип %s":               "Case label '%s' has type %s, but the first label has type %s",
+	"Пустой диапазон меток %s..%s":                                        "Empty label range %s..%s",
+	"Значение %s уже встречается среди меток case":                        "Value %s already appears among the case labels",
+	"Ожидалось ';', 'else' или 'end' в операторе case, получено %s '%s'":  "Expected ';', 'else' or 'end' in case statement, got %s '%s'",
+	"Оператор case по логическому выражению не охватывает значение %s":    "Case statement on a boolean expression does not cover the value %s",
+	"Оператор %s допустим только внутри цикла":                            "The %s statement is only allowed inside a loop",
+	"Ожидалось ';' или 'until', получено %s '%s'":                         "Expected ';' or 'until', got %s '%s'",
+	"Переменной цикла for должна быть простая переменная, получено '%s'":  "The for loop variable must be a simple variable, got '%s'",
+	"Переменная цикла for '%s' должна быть целой, а не %s":                "The for loop variable '%s' must be an integer, not %s",
+	"Шаг цикла for должен быть положительным целым, получено %s":          "The for loop step must be a positive integer, got %s",
+	"Нельзя изменить переменную цикла '%s' в теле цикла":                  "Cannot modify the loop variable '%s' inside the loop body",
+	"Имя '%s' скрывает внешнее объявление":                                "Name '%s' shadows an outer declaration",
+	"Ожидалось имя модуля, получено %s '%s'":                              "Expected unit name, got %s '%s'",
+	"Имя модуля '%s' не совпадает с именем файла '%s'":                    "Unit name '%s' does not match the file name '%s'",
+	"Модуль '%s' не найден":                                               "Unit '%s' not found",
+	"Циклическая зависимость модулей: %s":                                 "Circular unit dependency: %s",
+	"Модуль '%s' уже подключён":                                           "Unit '%s' is already used",
+	"Заголовок подпрограммы '%s' не совпадает с объявлением в интерфейсе": "Heading of subprogram '%s' does not match its interface declaration",
+	"Подпрограмма '%s' объявлена в интерфейсе, но не реализована":         "Subprogram '%s' is declared in the interface but not implemented",
+	"Ошибка чтения модуля '%s': %v":                                       "Error reading unit '%s': %v",
+	"Целое число '%s' не помещается в 64 бита":                            "Integer '%s' does not fit in 64 bits",
+	"Вещественное число '%s' вне допустимого диапазона":                   "Float '%s' is out of range",
+	"Метка case %s не помещается в 64 бита":                               "Case label %s does not fit in 64 bits",
+	"Ожидалось %s, получено %s '%s'":                                      "Expected %s, got %s '%s'",
+	"файл %s ищется в каталогах: %s":                                      "file %s was searched for in: %s",
+	"ожидалось '%s'":                    "expected '%s'",
+	"Запись '%s' не содержит поля '%s'": "Record '%s' has no field '%s'",
+
+	// Диагностика
+	"%s на строке %d столбце %d":        "%s at line %d column %d",
+	"лексическая ошибка":                "lexical error",
+	"синтаксическая ошибка":             "syntax error",
+	"ошибка выполнения":                 "runtime error",
+	"ошибка":                            "error",
+	"первое объявление":                 "first declared here",
+	"первое вхождение":                  "first occurrence",
+	"первая метка":                      "first label",
+	"предупреждение":                    "warning",
+	"к этому оператору case":            "for this case statement",
+	"оператор case начат здесь":         "case statement starts here",
+	"тело программы начато здесь":       "program body starts here",
+	"составной оператор начат здесь":    "compound statement starts here",
+	"к этому оператору if":              "for this if statement",
+	"к этому циклу for":                 "for this for loop",
+	"к этому циклу while":               "for this while loop",
+	"к этому циклу repeat":              "for this repeat loop",
+	"переменная цикла":                  "loop variable",
+	"внешнее объявление":                "outer declaration",
+	"раздел implementation начат здесь": "implementation section starts here",
+	"конец модуля":                      "end of unit",
+	"первое подключение":                "first used here",
+	"модуль подключён здесь":            "unit used here",
+	"для целых чисел произвольной длины используйте флаг -bigint": "use the -bigint flag for arbitrary-precision integers",
+	"шестнадцатеричное число должно начинаться с цифры: '0%s'":    "a hexadecimal number must start with a digit: '0%s'",
+	"файл включён здесь":              "file included here",
+	"к этой директиве":                "for this directive",
+	"объявлена в интерфейсе":          "declared in the interface",
+	"примечание":                      "note",
+	"открывающая скобка":              "opening parenthesis",
+	"объявлено здесь":                 "declared here",
+	"объявлена здесь":                 "declared here",
+	"тело подпрограммы начато здесь":  "subprogram body starts here",
+	"функция должна вернуть значение": "the function must return a value",
+	"открывающая квадратная скобка":   "opening bracket",
+	"границы объявлены здесь":         "bounds declared here",
+	"запись начата здесь":             "record starts here",
+	"запись объявлена здесь":          "record declared here",
+	"константа объявлена здесь":       "constant declared here",
+	"помощь": "help",
+	"возможно, имелось в виду '%s'":    "did you mean '%s'?",
+	"заменить '%s' на '%s'":            "replace '%s' with '%s'",
+	"вставить '%s'":                    "insert '%s'",
+	"объявить переменную '%s' типа %s": "declare variable '%s' of type %s",
+	"некорректная правка в позиции %d": "invalid edit at offset %d",
+
+	// Описания правил
+	"Некорректная запись числа":                                                      "Invalid number literal",
+	"Неизвестный знак операции":                                                      "Unknown operator",
+	"Символ, недопустимый в программе":                                               "Character not allowed in a program",
+	"Незакрытый комментарий":                                                         "Unterminated comment",
+	"Пропущен обязательный токен":                                                    "Missing required token",
+	"Ожидалось имя переменной в объявлении":                                          "Variable name expected in declaration",
+	"Повторное объявление переменной":                                                "Variable declared twice",
+	"Ожидался разделитель в объявлении":                                              "Separator expected in declaration",
+	"Ожидался тип переменной":                                                        "Variable type expected",
+	"Пропущена ';' после объявления":                                                 "Missing ';' after declaration",
+	"Пропущен разделитель операторов":                                                "Missing statement separator",
+	"Неизвестный оператор":                                                           "Unknown statement",
+	"Ожидался оператор":                                                              "Statement expected",
+	"Пропущен разделитель в составном операторе":                                     "Missing separator in compound statement",
+	"Ожидалось имя переменной в присваивании":                                        "Variable name expected in assignment",
+	"Использование необъявленной переменной":                                         "Use of undeclared variable",
+	"Ожидалось имя переменной в read":                                                "Variable name expected in read",
+	"Пропущен разделитель в списке аргументов":                                       "Missing separator in argument list",
+	"Ожидалось выражение":                                                            "Expression expected",
+	"Повторное объявление имени":                                                     "Name declared twice",
+	"Ожидалось имя процедуры или функции":                                            "Procedure or function name expected",
+	"Пропущен разделитель в списке параметров":                                       "Missing separator in parameter list",
+	"Имя подпрограммы использовано как переменная":                                   "Subprogram name used as a variable",
+	"Вызов переменной":                                                               "Variable called as a subprogram",
+	"Вызов необъявленной подпрограммы":                                               "Call of undeclared subprogram",
+	"Процедура использована в выражении":                                             "Procedure used in an expression",
+	"Неверное число аргументов":                                                      "Wrong number of arguments",
+	"return вне подпрограммы":                                                        "return outside a subprogram",
+	"Граница массива не является целой константой":                                   "Array bound is not an integer constant",
+	"Пустой диапазон индексов массива":                                               "Empty array index range",
+	"Индексирование переменной, не являющейся массивом":                              "Indexing a variable that is not an array",
+	"Константный индекс вне границ массива":                                          "Constant index out of array bounds",
+	"Чтение массива или записи целиком":                                              "Reading a whole array or record",
+	"Использование необъявленного типа":                                              "Use of undeclared type",
+	"Имя переменной или подпрограммы использовано как тип":                           "Variable or subprogram name used as a type",
+	"Повторное объявление поля записи":                                               "Record field declared twice",
+	"Выбор поля у переменной, не являющейся записью":                                 "Field selected from a variable that is not a record",
+	"Ожидалось имя поля после '.'":                                                   "Field name expected after '.'",
+	"Обращение к несуществующему полю записи":                                        "Access to a nonexistent record field",
+	"Недопустимая escape-последовательность в строке":                                "Invalid escape sequence in a string",
+	"Незакрытая строковая или символьная константа":                                  "Unterminated string or character literal",
+	"Символьная константа длиной не в один символ":                                   "Character literal that is not exactly one character",
+	"Директива препроцессора без имени или файла":                                    "Preprocessor directive without a name or file",
+	"{$else} или {$endif} без открывающей директивы":                                 "{$else} or {$endif} without an opening directive",
+	"Две директивы {$else} в одном условном фрагменте":                               "Two {$else} directives in one conditional block",
+	"Неизвестная директива препроцессора":                                            "Unknown preprocessor directive",
+	"{$ifdef} или {$ifndef} без {$endif}":                                            "{$ifdef} or {$ifndef} without {$endif}",
+	"Файл включает сам себя напрямую или через другие файлы":                         "File includes itself directly or through other files",
+	"Включаемый файл не удалось прочитать":                                           "Included file cannot be read",
+	"Форматирование текста с директивами препроцессора":                              "Formatting source with preprocessor directives",
+	"Цифра, которой нет в системе счисления числа":                                   "Digit outside the base of the number",
+	"Шестнадцатеричные цифры в числе без суффикса h":                                 "Hexadecimal digits in a number without the h suffix",
+	"Посторонний символ в записи числа":                                              "Unexpected character in a number",
+	"Вещественное число без цифр после точки":                                        "Float without digits after the decimal point",
+	"Вещественное число без цифр порядка":                                            "Float without exponent digits",
+	"Выражение константы нельзя вычислить при компиляции":                            "Constant expression cannot be evaluated at compile time",
+	"Присваивание константе или чтение в неё":                                        "Assignment to a constant or reading into it",
+	"Метка case не является целой или логической константой":                         "Case label is not an integer or boolean constant",
+	"Метки case разных типов":                                                        "Case labels of different types",
+	"Нижняя граница диапазона меток больше верхней":                                  "Lower bound of a label range is greater than the upper one",
+	"Повторяющаяся метка case":                                                       "Duplicate case label",
+	"Пропущен разделитель ветвей оператора case":                                     "Missing separator between case branches",
+	"Оператор case по логическому выражению без ветви для true или false":            "Case statement on a boolean expression without a branch for true or false",
+	"break или continue вне цикла":                                                   "break or continue outside a loop",
+	"Пропущен разделитель операторов в цикле repeat":                                 "Missing statement separator in a repeat loop",
+	"Элемент массива или поле записи в качестве переменной цикла for":                "Array element or record field used as a for loop variable",
+	"Переменная цикла for не целого типа":                                            "Non-integer for loop variable",
+	"Нулевой, отрицательный или дробный шаг цикла for":                               "Zero, negative or fractional for loop step",
+	"Присваивание переменной цикла for в его теле":                                   "Assignment to a for loop variable inside its body",
+	"Переменная блока скрывает имя из объемлющей области (ошибка при -shadow=error)": "Block variable hides a name from an enclosing scope (an error with -shadow=error)",
+	"Ожидалось имя модуля":                                                           "Expected a unit name",
+	"Имя модуля отличается от имени его файла":                                       "Unit name differs from its file name",
+	"Файл подключаемого модуля не найден в пути поиска":                              "Used unit file not found on the search path",
+	"Модули подключают друг друга по кругу":                                          "Units use each other in a cycle",
+	"Повторное подключение модуля в списке uses":                                     "Unit listed twice in a uses clause",
+	"Реализация подпрограммы модуля отличается от её заголовка в интерфейсе":         "Unit subprogram implementation differs from its interface heading",
+	"Подпрограмма интерфейса модуля без реализации":                                  "Unit interface subprogram without an implementation",
+	"Файл модуля не удалось прочитать":                                               "Unit file could not be read",
+	"Целая константа вне диапазона 64-битных целых":                                  "Integer constant outside the 64-bit range",
+	"Вещественная константа вне диапазона float":                                     "Float constant outside the float range",
+	"Метка case вне диапазона 64-битных целых":                                       "Case label outside the 64-bit range",
+	"Токен не допускается грамматикой tfi.ebnf в этом месте (анализаторы ll и lalr)": "Token not allowed by the tfi.ebnf grammar here (ll and lalr parsers)",
+	"Массив с числом элементов больше допустимого":                                   "Array with more elements than allowed",
+
+	// Чтение дерева разбора из JSON
+	"некорректный JSON: %v":                   "invalid JSON: %v",
+	"ожидался узел Program, получен '%s'":     "expected Program node, got '%s'",
+	"некорректный узел '%s' на строке %d":     "invalid node '%s' at line %d",
+	"некорректный узел null в списке '%s'":    "invalid null node in list '%s'",
+	"дерево содержит некорректный токен: %v":  "tree contains an invalid token: %v",
+	"отсутствует обязательный узел оператора": "missing required statement node",
+	"отсутствует обязательный узел выражения": "missing required expression node",
+	"неизвестный тип '%s' на строке %d":       "unknown type '%s' at line %d",
+	"неизвестная константа '%s' на строке %d": "unknown constant '%s' at line %d",
+
+	// Выполнение
+	"Ошибка выполнения: %s на строке %d столбце %d":                  "Runtime error: %s at line %d column %d",
+	"необъявленная переменная '%s'":                                  "undeclared variable '%s'",
+	"неподдерживаемый оператор":                                      "unsupported statement",
+	"неподдерживаемое выражение":                                     "unsupported expression",
+	"нельзя присвоить значение %s переменной '%s' типа %s":           "cannot assign value %s to variable '%s' of type %s",
+	"не удалось прочитать значение '%s': %v":                         "cannot read value of '%s': %v",
+	"ожидалось true или false, прочитано '%s'":                       "expected true or false, read '%s'",
+	"ожидалось логическое значение, получено %s":                     "expected boolean value, got %s",
+	"операция '~' неприменима к %s":                                  "operation '~' cannot be applied to %s",
+	"операция '%s' применима только к логическим значениям":          "operation '%s' applies only to boolean values",
+	"операция '%s' неприменима к логическим значениям":               "operation '%s' cannot be applied to boolean values",
+	"операция '%s' неприменима к %s и %s":                            "operation '%s' cannot be applied to %s and %s",
+	"неизвестная операция '%s'":                                      "unknown operation '%s'",
+	"деление на ноль":                                                "division by zero",
+	"целочисленное переполнение: %d %s %d не помещается в 64 бита":   "integer overflow: %d %s %d does not fit in 64 bits",
+	"необъявленная подпрограмма '%s'":                                "undeclared subprogram '%s'",
+	"функция '%s' завершилась без возврата значения":                 "function '%s' ended without returning a value",
+	"слишком глубокая рекурсия: более %d вложенных вызовов":          "recursion too deep: more than %d nested calls",
+	"индекс %d вне границ массива '%s' [%d..%d]":                     "index %d is out of bounds of array '%s' [%d..%d]",
+	"индекс массива должен быть целым, получено %s":                  "array index must be an integer, got %s",
+	"некорректный тип массива %s":                                    "invalid array type %s",
+	"массив слишком велик: %s элементов (не более %d)":               "array too large: %s elements (at most %d)",
+	"операция '%s' неприменима к массивам":                           "operation '%s' cannot be applied to arrays",
+	"нельзя прочитать переменную '%s' типа %s":                       "cannot read variable '%s' of type %s",
+	"операция '%s' неприменима к записям":                            "operation '%s' cannot be applied to records",
+	"запись '%s' не содержит поля '%s'":                              "record '%s' has no field '%s'",
+	"'%s' не является константой":                                    "'%s' is not a constant",
+	"нельзя изменить константу '%s'":                                 "cannot modify constant '%s'",
+	"оператор %s вне цикла":                                          "%s statement outside a loop",
+	"переменная цикла for должна быть целой, получено %s":            "for loop variable must be an integer, got %s",
+	"граница цикла for должна быть целой, получено %s":               "for loop bound must be an integer, got %s",
+	"шаг цикла for должен быть положительным целым, получено %s":     "for loop step must be a positive integer, got %s",
+	"некорректная метка case '%s'":                                   "invalid case label '%s'",
+	"выражение выбора должно быть целым или логическим, получено %s": "case selector must be an integer or boolean, got %s",
+	"метка case '%s' типа %s не соответствует значению %s":           "case label '%s' of type %s does not match the value %s",
+	"операция '%s' неприменима к строкам":                            "operation '%s' cannot be applied to strings",
+	"операция '%s' неприменима к строке и значению %s":               "operation '%s' cannot be applied to a string and value %s",
+	"аргумент %d функции '%s' должен иметь тип %s, получено %s":      "argument %d of function '%s' must have type %s, got %s",
+	"подстрока [%d, %d символов] вне строки длины %d":                "substring [%d, %d characters] is outside a string of length %d",
+
+	// Отладчик
+	"DAP: некорректный заголовок '%s'":          "DAP: invalid header '%s'",
+	"DAP: отсутствует заголовок Content-Length": "DAP: missing Content-Length header",
+	"DAP: некорректное сообщение: %v":           "DAP: invalid message: %v",
+	"неподдерживаемый запрос '%s'":              "unsupported request '%s'",
+	"программа не остановлена":                  "program is not stopped",
+	"неизвестный кадр":                          "unknown frame",
+	"неизвестная ссылка на переменные":          "unknown variables reference",
+	"Ошибка при открытии файла: %v":             "Error opening file: %v",
+	"Ошибка лексического анализа: %v":           "Lexical analysis error: %v",
+	"Ошибка синтаксического анализа: %v":        "Syntax analysis error: %v",
+
+	// Командная строка
+	"Ошибка при открытии файла: %v\n":                                        "Error opening file: %v\n",
+	"Ошибка сервера отладки: %v\n":                                           "Debug server error: %v\n",
+	"Неизвестная команда '%s'\n":                                             "Unknown command '%s'\n",
+	"Неизвестный язык '%s'\n":                                                "Unknown language '%s'\n",
+	"Команда '%s' не поддерживает формат '%s'\n":                             "Command '%s' does not support format '%s'\n",
+	"формат вывода: ":                                                        "output format: ",
+	"язык сообщений: ru, en":                                                 "message language: ru, en",
+	"записать результат в исходный файл":                                     "write result to the source file",
+	"вывести имена файлов, форматирование которых отличается":                "list files whose formatting differs",
+	"Использование: tfi <команда> [флаги] <файл>...":                         "Usage: tfi <command> [flags] <file>...",
+	"Вместо имени файла можно указать '-' для чтения из стандартного ввода.": "Use '-' instead of a file name to read from standard input.",
+	"Команды:": "Commands:",
+	"Флаги:":   "Flags:",
+	"  -format  формат вывода (text, json, jsonl, csv, sexpr, dot, sarif)":  "  -format  output format (text, json, jsonl, csv, sexpr, dot, sarif)",
+	"  -from    вид входных данных (source, json)":                          "  -from    input kind (source, json)",
+	"вход: source — текст программы, json — дерево разбора в JSON":          "input: source — program text, json — parse tree in JSON",
+	"Неизвестный вид входных данных '%s'\n":                                 "Unknown input kind '%s'\n",
+	"%s: Ошибка чтения дерева разбора: %v\n":                                "%s: Error reading parse tree: %v\n",
+	"  -lang    язык сообщений (ru, en)":                                    "  -lang    message language (ru, en)",
+	"  -color   раскраска сообщений об ошибках (auto, always, never)":       "  -color   colorize error messages (auto, always, never)",
+	"раскраска сообщений об ошибках: auto, always, never":                   "colorize error messages: auto, always, never",
+	"Неизвестный режим раскраски '%s'\n":                                    "Unknown color mode '%s'\n",
+	"  -shadow  скрытие внешнего имени переменной блока (warning, error)":   "  -shadow  hiding an outer name with a block variable (warning, error)",
+	"скрытие внешнего имени переменной блока: warning, error":               "hiding an outer name with a block variable: warning, error",
+	"Неизвестный режим проверки скрытия имён '%s'\n":                        "Unknown shadowing mode '%s'\n",
+	"  -path    каталоги поиска модулей (кроме каталога программы)":         "  -path    unit search directories (besides the program directory)",
+	"  -bigint  целые числа произвольной длины":                             "  -bigint  arbitrary-precision integers",
+	"каталоги поиска модулей, разделённые ':' (';' в Windows)":              "unit search directories separated by ':' (';' on Windows)",
+	"целые числа произвольной длины вместо 64-битных":                       "arbitrary-precision integers instead of 64-bit ones",
+	"%s: формат '%s' не поддерживается для модуля\n":                        "%s: format '%s' is not supported for units\n",
+	"%s: модуль нельзя выполнить: он подключается к программе через uses\n": "%s: a unit cannot be run: it is used by a program via uses\n",
+	"вывести таблицу токенов":                                               "print the token table",
+	"вывести дерево разбора":                                                "print the parse tree",
+	"проверить программу без вывода при успехе":                             "check the program quietly",
+	"выполнить программу":                                                   "run the program",
+	"отформатировать программу":                                             "format the program",
+	"запустить сервер отладки (Debug Adapter Protocol)":                     "start the debug server (Debug Adapter Protocol)",
+	"сравнить скорость табличного и посимвольного лексического анализатора": "compare the speed of the table-driven and the character-by-character lexer",
+	"размер текста для замера в мегабайтах":                                 "size of the benchmark text in megabytes",
+	"Размер текста должен быть положительным, получено %d\n":                "Text size must be positive, got %d\n",
+	"Результаты анализаторов различаются: %v; эталон: %v\n":                 "Lexer results differ: %v; reference: %v\n",
+	"Результаты анализаторов различаются":                                   "Lexer results differ",
+	"Текст: %.1f МБ, токенов: %d; результаты анализаторов совпадают\n":      "Text: %.1f MB, %d tokens; lexer results match\n",
+	"анализатор":                "lexer",
+	"мс/проход":                 "ms/op",
+	"МБ/с":                      "MB/s",
+	"выделений":                 "allocs/op",
+	"байт":                      "B/op",
+	"  (быстрее в %.1f раза)\n": "  (%.1fx faster)\n",
+	"построить таблицы лексического анализатора по спецификации":                                     "build lexer tables from a specification",
+	"записать таблицы в файл вместо стандартного вывода":                                             "write the tables to a file instead of standard output",
+	"сравнить сгенерированный анализатор с текущим на указанных файлах":                              "compare the generated lexer with the current one on the given files",
+	"Использование: tfi lexgen [-o файл] <спецификация>":                                             "Usage: tfi lexgen [-o file] <specification>",
+	"               tfi lexgen -check <спецификация> [файл...]":                                      "       tfi lexgen -check <specification> [file...]",
+	"%s: ожидалось 'имя = выражение' или 'класс приоритет выражение'":                                "%s: expected 'name = expression' or 'class priority expression'",
+	"%s: повторное определение '%s'":                                                                 "%s: '%s' is defined twice",
+	"%s: неизвестный класс токенов '%s'":                                                             "%s: unknown token class '%s'",
+	"%s: выражение класса '%s' допускает пустую строку":                                              "%s: the expression of class '%s' matches the empty string",
+	"%s: нет ни одного правила":                                                                      "%s: no rules",
+	"%s: слишком много правил: %d":                                                                   "%s: too many rules: %d",
+	"неожиданный символ '%c'":                                                                        "unexpected character '%c'",
+	"незакрытая скобка '%c'":                                                                         "unclosed '%c'",
+	"неизвестное определение '%s'":                                                                   "unknown definition '%s'",
+	"некорректный диапазон символов":                                                                 "invalid character range",
+	"символ '%c' вне ASCII: используйте \\p{L}, \\p{Nd} или \\s":                                     "character '%c' is outside ASCII: use \\p{L}, \\p{Nd} or \\s",
+	"выражение оканчивается на '\\'":                                                                 "expression ends with '\\'",
+	"ожидалось \\p{L} или \\p{Nd}":                                                                   "expected \\p{L} or \\p{Nd}",
+	"неизвестный класс символов '\\p{%s}'":                                                           "unknown character class '\\p{%s}'",
+	"неизвестная escape-последовательность '\\%c'":                                                   "unknown escape sequence '\\%c'",
+	"%s: правил: %d; НКА: %d состояний; ДКА: %d, после минимизации: %d; столбцов таблицы: %d\n":      "%s: %d rules; NFA: %d states; DFA: %d, minimized: %d; table columns: %d\n",
+	"Таблицы lexgen_tables.go соответствуют спецификации":                                            "Tables in lexgen_tables.go match the specification",
+	"Таблицы lexgen_tables.go построены не по этой спецификации или устарели: выполните go generate": "Tables in lexgen_tables.go were built from a different specification or are out of date: run go generate",
+	"(пример)": "(sample)",
+	"%s: различие: %s, ожидалось: %s\n":               "%s: mismatch: %s, expected: %s\n",
+	"нет ошибки":                                      "no error",
+	"%s: различие: %s\n":                              "%s: mismatch: %s\n",
+	"%s: совпадает (ошибка: %v)\n":                    "%s: match (error: %v)\n",
+	"%s: совпадает (токенов: %d, комментариев: %d)\n": "%s: match (%d tokens, %d comments)\n",
+	"строка %d, столбец %d: %s '%s', ожидался %s '%s' (строка %d, столбец %d)": "line %d, column %d: %s '%s', expected %s '%s' (line %d, column %d)",
+	"токенов: %d, ожидалось %d":                                         "%d tokens, expected %d",
+	"исправить ошибки, для которых известно исправление":                "apply known fixes to errors",
+	"вывести изменения в виде diff, не изменяя файлы":                   "print changes as a diff without modifying files",
+	"%s: команда fix работает только с текстом программы\n":             "%s: the fix command works only with program text\n",
+	"%s:%d:%d: исправлено: %s\n":                                        "%s:%d:%d: fixed: %s\n",
+	"построить LL(1)-анализатор по грамматике и проверить им программы": "build an LL(1) parser from the grammar and check programs with it",
+	"идентификатор": "identifier",
+	"число":         "number",
+	"строка":        "string",
+	"символ":        "character",
+	"конец текста":  "end of text",
+	" или ":         " or ",
+	"принять":       "accept",
+	"сдвиг %s":      "shift %s",
+	"файл грамматики EBNF вместо встроенной":                                   "EBNF grammar file instead of the built-in one",
+	"напечатать части анализатора через запятую: bnf, first, follow, table":    "print parser parts, comma-separated: bnf, first, follow, table",
+	"печатать шаги разбора":                                                    "print parsing steps",
+	"Неизвестная часть анализатора '%s': ожидалось %s\n":                       "Unknown parser part '%s': expected %s\n",
+	"%s:%d: левая рекурсия %s: грамматика не подходит для анализатора LL(1)\n": "%s:%d: left recursion %s: the grammar is not suitable for an LL(1) parser\n",
+	"%s: модуль пропущен: грамматика описывает только программы\n":             "%s: unit skipped: the grammar describes programs only\n",
+	"%s: различие: ParseProgram принимает программу\n":                         "%s: mismatch: ParseProgram accepts the program\n",
+	"%s: принят; ParseProgram: %v\n":                                           "%s: accepted; ParseProgram: %v\n",
+	"%s: принят\n":                                                             "%s: accepted\n",
+	"%s:%d:%d: незакрытая строка":                                              "%s:%d:%d: unterminated string",
+	"%s:%d:%d: пустой терминал":                                                "%s:%d:%d: empty terminal",
+	"%s:%d:%d: неожиданный символ '%c'":                                        "%s:%d:%d: unexpected character '%c'",
+	"ожидалось '%s', получено '%s'":                                            "expected '%s', got '%s'",
+	"ожидалось '.' в конце правила":                                            "expected '.' at the end of the rule",
+	"ожидалось имя правила с заглавной буквы, получено '%s'":                   "expected a capitalized rule name, got '%s'",
+	"повторное определение правила '%s'":                                       "rule '%s' is defined twice",
+	"%s:%d: неизвестное правило '%s'":                                          "%s:%d: unknown rule '%s'",
+	"%s:%d: %s не является ключевым словом, операцией или разделителем":        "%s:%d: %s is not a keyword, operator or delimiter",
+	"%s:%d: неизвестный класс токенов '%s'":                                    "%s:%d: unknown token class '%s'",
+	"%s: нетерминалов: %d (вспомогательных: %d), терминалов: %d, продукций: %d; конфликтов LL(1): %d\n": "%s: %d nonterminals (%d auxiliary), %d terminals, %d productions; %d LL(1) conflicts\n",
+	"%s: правило %s недостижимо из %s\n":                                                            "%s: rule %s is unreachable from %s\n",
+	"%s:%d: конфликт LL(1) в %s по %s:\n":                                                           "%s:%d: LL(1) conflict in %s on %s:\n",
+	"    выбрана продукция %d\n":                                                                    "    production %d chosen\n",
+	"построить LALR(1)-анализатор по грамматике и проверить им программы":                           "build an LALR(1) parser from the grammar and check programs with it",
+	"%s: продукций: %d, состояний LALR(1): %d; конфликтов сдвиг/свёртка: %d, свёртка/свёртка: %d\n": "%s: %d productions, %d LALR(1) states; %d shift/reduce conflicts, %d reduce/reduce conflicts\n",
+	"%s:%d: конфликт сдвиг/свёртка в состоянии %d по %s:\n":                                         "%s:%d: shift/reduce conflict in state %d on %s:\n",
+	"    сдвиг       %s\n": "    shift       %s\n",
+	"%s:%d: конфликт свёртка/свёртка в состоянии %d по %s:\n": "%s:%d: reduce/reduce conflict in state %d on %s:\n",
+	"    свёртка %3d %s\n":     "    reduce  %3d %s\n",
+	"    пример: %s\n":         "    example: %s\n",
+	"    выбран сдвиг":         "    shift chosen",
+	"    выбрана свёртка %d\n": "    reduction %d chosen\n",
+	"состояние %d\n":           "state %d\n",
+	"сдвиг %s, состояние %d":   "shift %s, state %d",
+	"свёртка %d  %s":           "reduce %d  %s",
+	"напечатать части анализатора через запятую: bnf, states, table": "print parser parts, comma-separated: bnf, states, table",
+}
+
+// Перевод строки сообщения на выбранный язык
+func tr(msg string) string {
+	if lang == "en" {
+		if s, ok := messagesEn[msg]; ok {
+			return s
+		}
+	}
+	return msg
+}
+
+func errorf(format string, args ...any) error {
+	return fmt.Errorf(tr(format), args...)
+}
+
+func sprintf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
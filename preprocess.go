@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Директивы препроцессора записываются в фигурных скобках, как комментарии,
+// но начинаются с '$':
+//
+//	{$define X}  {$undef X}            — объявить или отменить имя
+//	{$ifdef X} ... [{$else} ...] {$endif} — условная компиляция ({$ifndef X} — обратное условие)
+//	{$include 'file.txt'}              — вставить токены файла на место директивы
+//
+// Директивы обрабатываются после лексического анализа: токены исключённых
+// фрагментов отбрасываются, токены включённого файла сохраняют его имя (Token.File)
+// и свои позиции, поэтому сообщения об ошибках указывают на исходный файл.
+
+type preprocessor struct {
+	defines  map[string]bool
+	files    []string // разбираемый и включаемые файлы, обрабатываемые в данный момент
+	included []string // все включённые файлы в порядке включения
+}
+
+// Место включения файла (директива {$include}) по его абсолютному пути; если файл
+// включается несколько раз, хранится последнее. Нужно для примечаний к ошибкам
+// во включённых файлах (см. includeNotes)
+var includedFrom = make(map[string]Token)
+
+func newPreprocessor(name string) *preprocessor {
+	pp := &preprocessor{defines: make(map[string]bool)}
+	if name != "" && name != "-" {
+		abs, err := filepath.Abs(name)
+		if err == nil {
+			pp.files = append(pp.files, abs)
+		}
+	}
+	return pp
+}
+
+// Открытая директива {$ifdef} или {$ifndef}
+type ppCondition struct {
+	tok    Token
+	name   string // ifdef или ifndef
+	outer  bool   // объемлющий фрагмент включён
+	value  bool   // условие директивы выполнено
+	inElse bool   // встречена {$else}
+}
+
+// Имя директивы и её аргумент: {$include 'a.txt'} -> "include", "'a.txt'"
+func parseDirective(lexeme string) (string, string) {
+	text := strings.TrimSuffix(strings.TrimPrefix(lexeme, "{$"), "}")
+	name, arg, _ := strings.Cut(strings.TrimSpace(text), " ")
+	return strings.ToLower(name), strings.TrimSpace(arg)
+}
+
+// Обработка директив в токенах файла name
+func (pp *preprocessor) run(tokens []Token, name string) ([]Token, error) {
+	var out []Token
+	var conds []ppCondition
+	active := true
+	for _, tok := range tokens {
+		if tok.Type != TokenDirective {
+			if active {
+				out = append(out, tok)
+			}
+			continue
+		}
+		directive, arg := parseDirective(tok.Lexeme)
+		switch directive {
+		case "define", "undef", "ifdef", "ifndef", "include":
+			if arg == "" {
+				return nil, newDiagnostic(StageLexical, tok, "Директива {$%s} требует аргумента", directive)
+			}
+		}
+		switch directive {
+		case "define":
+			if active {
+				pp.defines[arg] = true
+			}
+		case "undef":
+			if active {
+				delete(pp.defines, arg)
+			}
+		case "ifdef", "ifndef":
+			value := pp.defines[arg] == (directive == "ifdef")
+			conds = append(conds, ppCondition{tok: tok, name: directive, outer: active, value: value})
+			active = active && value
+		case "else", "endif":
+			if len(conds) == 0 {
+				return nil, newDiagnostic(StageLexical, tok, "Директива {$%s} без {$ifdef}", directive)
+			}
+			top := &conds[len(conds)-1]
+			if directive == "endif" {
+				active = top.outer
+				conds = conds[:len(conds)-1]
+				break
+			}
+			if top.inElse {
+				d := newDiagnostic(StageLexical, tok, "Повторная директива {$else}")
+				return nil, withLabel(d, top.tok, tr("к этой директиве"))
+			}
+			top.inElse = true
+			active = top.outer && !top.value
+		case "include":
+			if !active {
+				break
+			}
+			included, err := pp.include(tok, arg, name)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, included...)
+		default:
+			return nil, newDiagnostic(StageLexical, tok, "Неизвестная директива '%s'", directive)
+		}
+	}
+	if len(conds) > 0 {
+		top := conds[len(conds)-1]
+		return nil, newDiagnostic(StageLexical, top.tok, "Не закрыта директива {$%s}: ожидалась {$endif}", top.name)
+	}
+	return out, nil
+}
+
+// Токены файла из директивы {$include}; путь берётся относительно каталога файла,
+// содержащего директиву
+func (pp *preprocessor) include(tok Token, arg, name string) ([]Token, error) {
+	file, err := decodeString(arg)
+	if err != nil {
+		file = arg
+	}
+	from := name
+	if tok.File != "" {
+		from = tok.File
+	}
+	path := file
+	if !filepath.IsAbs(path) {
+		dir := "."
+		if from != "" && from != "-" {
+			dir = filepath.Dir(from)
+		}
+		path = filepath.Join(dir, file)
+	}
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		path = abs
+	}
+	for _, f := range pp.files {
+		if f == path {
+			return nil, newDiagnostic(StageLexical, tok, "Рекурсивное включение файла '%s'", file)
+		}
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newDiagnostic(StageLexical, tok, "Не удалось включить файл '%s': %v", file, err)
+	}
+	site := tok
+	if site.File == "" && filepath.IsAbs(name) {
+		// Директива в тексте модуля: его токены помечаются файлом уже после анализа
+		site.File = name
+	}
+	includedFrom[path] = site
+	pp.included = append(pp.included, path)
+	tokens, err := scan(bytes.NewReader(src), nil)
+	if d := asDiagnostic(err); d != nil {
+		d.Tok.File = path
+		return nil, withLabel(d, tok, tr("файл включён здесь"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range tokens {
+		tokens[i].File = path
+	}
+
+	pp.files = append(pp.files, path)
+	defer func() { pp.files = pp.files[:len(pp.files)-1] }()
+	tokens, err = pp.run(tokens, path)
+	if d := asDiagnostic(err); d != nil && d.Tok.File != tok.File {
+		return nil, withLabel(d, tok, tr("файл включён здесь"))
+	}
+	return tokens, err
+}
@@ -0,0 +1,696 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Форматирование программы в каноническом виде (tfi fmt).
+// Комментарии переносятся на место перед ближайшим следующим объявлением
+// или оператором; комментарий в конце строки остаётся в конце строки.
+
+const indentUnit = "    "
+
+type printer struct {
+	sb       strings.Builder
+	indent   int
+	comments []Token
+}
+
+func Format(prog *Program, comments []Token) string {
+	p := &printer{comments: comments}
+
+	p.write("program")
+	p.uses(prog.Uses)
+	p.sections(prog.Consts, prog.Types)
+	p.newline()
+	p.write("var")
+	p.decls(prog.Decls)
+	for _, sub := range prog.Subprograms {
+		p.sb.WriteString("\n")
+		p.subprogram(sub)
+	}
+	if len(prog.Subprograms) > 0 {
+		p.sb.WriteString("\n")
+	}
+	p.newline()
+	p.write("begin")
+	p.indent++
+	p.stmtList(prog.Body, 0, ";", true)
+	p.indent--
+	p.newline()
+	p.flush(prog.End)
+	p.write("end.")
+	p.trailing(prog.End.LineNum)
+	for _, c := range p.comments {
+		p.newline()
+		p.write(c.Lexeme)
+	}
+	p.sb.WriteString("\n")
+	return p.sb.String()
+}
+
+// Форматирование модуля: разделы interface и implementation отделяются пустыми строками
+func FormatUnit(unit *Unit, comments []Token) string {
+	p := &printer{comments: comments}
+
+	p.write("unit " + unit.Name.Lexeme + ";")
+	p.uses(unit.Uses)
+	p.newline()
+	p.write("interface")
+	p.sections(unit.Consts, unit.Types)
+	for _, sub := range unit.Headings {
+		p.newline()
+		p.flush(sub.Pos())
+		p.write(subprogramHeader(sub) + ";")
+		p.trailing(sub.Pos().LineNum)
+	}
+	p.sb.WriteString("\n")
+	p.newline()
+	p.flush(unit.Impl)
+	p.write("implementation")
+	if len(unit.Decls) > 0 {
+		p.newline()
+		p.write("var")
+		p.decls(unit.Decls)
+	}
+	for _, sub := range unit.Subprograms {
+		p.sb.WriteString("\n")
+		p.subprogram(sub)
+	}
+	p.sb.WriteString("\n")
+	p.newline()
+	p.flush(unit.End)
+	p.write("end.")
+	p.trailing(unit.End.LineNum)
+	for _, c := range p.comments {
+		p.newline()
+		p.write(c.Lexeme)
+	}
+	p.sb.WriteString("\n")
+	return p.sb.String()
+}
+
+// Список подключённых модулей на отдельной строке
+func (p *printer) uses(names []Token) {
+	if len(names) == 0 {
+		return
+	}
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = name.Lexeme
+	}
+	p.newline()
+	p.write("uses " + strings.Join(list, ", ") + ";")
+}
+
+// Необязательные разделы констант и типов
+func (p *printer) sections(consts []*ConstDecl, types []*TypeDecl) {
+	if len(consts) > 0 {
+		p.newline()
+		p.write("const")
+		p.constDecls(consts)
+	}
+	if len(types) > 0 {
+		p.newline()
+		p.write("type")
+		p.typeDecls(types)
+	}
+}
+
+// Объявления переменных с отступом после 'var'
+func (p *printer) decls(decls []*VarDecl) {
+	p.indent++
+	prevLine := 0
+	for i, decl := range decls {
+		p.blankLine(prevLine, decl.Pos())
+		p.newline()
+		p.flush(decl.Pos())
+		p.write(declString(decl) + ";")
+		prevLine = typeEndLine(decl.Type)
+		if i == len(decls)-1 || decls[i+1].Pos().LineNum > prevLine {
+			p.trailing(prevLine)
+		}
+	}
+	p.indent--
+}
+
+// Объявления констант с отступом после 'const'
+func (p *printer) constDecls(decls []*ConstDecl) {
+	p.indent++
+	prevLine := 0
+	for i, decl := range decls {
+		p.blankLine(prevLine, decl.Pos())
+		p.newline()
+		p.flush(decl.Pos())
+		p.write(decl.Name.Lexeme + " = " + exprString(decl.Value) + ";")
+		prevLine = exprEndLine(decl.Value)
+		if i == len(decls)-1 || decls[i+1].Pos().LineNum > prevLine {
+			p.trailing(prevLine)
+		}
+	}
+	p.indent--
+}
+
+// Объявления типов с отступом после 'type'
+func (p *printer) typeDecls(decls []*TypeDecl) {
+	p.indent++
+	prevLine := 0
+	for i, decl := range decls {
+		p.blankLine(prevLine, decl.Pos())
+		p.newline()
+		p.flush(decl.Pos())
+		p.write(decl.Name.Lexeme + " = " + typeString(decl.Type) + ";")
+		prevLine = typeEndLine(decl.Type)
+		if i == len(decls)-1 || decls[i+1].Pos().LineNum > prevLine {
+			p.trailing(prevLine)
+		}
+	}
+	p.indent--
+}
+
+// Подпрограмма отделяется от соседних конструкций пустой строкой
+func (p *printer) subprogram(sub *Subprogram) {
+	p.newline()
+	p.flush(sub.Pos())
+	p.write(subprogramHeader(sub) + ";")
+	if len(sub.Decls) > 0 {
+		p.newline()
+		p.write("var")
+		p.decls(sub.Decls)
+	}
+	p.newline()
+	p.write("begin")
+	p.indent++
+	p.stmtList(sub.Body, 0, ";", true)
+	p.indent--
+	p.newline()
+	p.flush(sub.End)
+	p.write("end;")
+	p.trailing(sub.End.LineNum)
+}
+
+func (p *printer) write(s string) {
+	p.sb.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.sb.WriteString("\n")
+	p.sb.WriteString(strings.Repeat(indentUnit, p.indent))
+}
+
+// Печать комментариев, расположенных до позиции pos
+func (p *printer) flush(pos Token) {
+	for len(p.comments) > 0 && before(p.comments[0], pos) {
+		p.write(p.comments[0].Lexeme)
+		p.newline()
+		p.comments = p.comments[1:]
+	}
+}
+
+// Комментарий на той же строке, что и конец предыдущей конструкции
+// (вызывается, только если следующая конструкция начинается на другой строке)
+func (p *printer) trailing(line int) {
+	if len(p.comments) > 0 && p.comments[0].LineNum == line {
+		p.write(" " + p.comments[0].Lexeme)
+		p.comments = p.comments[1:]
+	}
+}
+
+// Сохранение одной пустой строки между конструкциями, если она была в исходном тексте
+func (p *printer) blankLine(prevLine int, next Token) {
+	if len(p.comments) > 0 && before(p.comments[0], next) {
+		next = p.comments[0]
+	}
+	if prevLine > 0 && next.LineNum > prevLine+1 {
+		p.sb.WriteString("\n")
+	}
+}
+
+func before(a, b Token) bool {
+	return a.LineNum < b.LineNum || (a.LineNum == b.LineNum && a.ColNum < b.ColNum)
+}
+
+// Печать последовательности операторов; sepAfterLast — ставить ли разделитель после последнего
+func (p *printer) stmtList(stmts []Stmt, prevLine int, sep string, sepAfterLast bool) {
+	for i, s := range stmts {
+		p.blankLine(prevLine, s.Pos())
+		p.newline()
+		p.stmt(s)
+		if i < len(stmts)-1 || sepAfterLast {
+			p.write(sep)
+		}
+		prevLine = endLine(s)
+		if i == len(stmts)-1 || stmts[i+1].Pos().LineNum > prevLine {
+			p.trailing(prevLine)
+		}
+	}
+}
+
+func (p *printer) stmt(s Stmt) {
+	p.flush(s.Pos())
+	switch s := s.(type) {
+	case *AssignStmt:
+		p.write(exprString(s.Target) + " as " + exprString(s.Value))
+	case *IfStmt:
+		p.write("if " + exprString(s.Cond) + " then")
+		p.body(s.Then)
+		if s.Else != nil {
+			p.newline()
+			p.write("else")
+			p.body(s.Else)
+		}
+	case *ForStmt:
+		header := "for " + exprString(s.Init.Target) + " as " + exprString(s.Init.Value) + " " + forDir(s) + " " + exprString(s.To)
+		if s.Step != nil {
+			header += " step " + exprString(s.Step)
+		}
+		p.write(header + " do")
+		p.body(s.Body)
+	case *WhileStmt:
+		p.write("while " + exprString(s.Cond) + " do")
+		p.body(s.Body)
+	case *RepeatStmt:
+		p.write("repeat")
+		p.indent++
+		p.stmtList(s.Body, s.Tok.LineNum, ";", false)
+		p.indent--
+		p.newline()
+		p.flush(s.Until)
+		p.write("until " + exprString(s.Cond))
+	case *BreakStmt:
+		p.write("break")
+	case *ContinueStmt:
+		p.write("continue")
+	case *CaseStmt:
+		p.write("case " + exprString(s.Selector) + " of")
+		p.indent++
+		for _, branch := range s.Branches {
+			p.newline()
+			p.flush(branch.Pos())
+			p.write(caseLabelsString(branch.Labels) + ":")
+			p.body(branch.Body)
+			p.write(";")
+		}
+		p.indent--
+		if s.Else != nil {
+			p.newline()
+			p.write("else")
+			p.body(s.Else)
+		}
+		p.newline()
+		p.flush(s.End)
+		p.write("end")
+	case *ReadStmt:
+		p.write("read(" + exprList(s.Targets) + ")")
+	case *WriteStmt:
+		p.write("write(" + exprList(s.Args) + ")")
+	case *CompoundStmt:
+		p.write("[")
+		p.indent++
+		prevLine := s.Tok.LineNum
+		if len(s.Decls) > 0 {
+			p.newline()
+			p.write("var")
+			p.decls(s.Decls)
+			prevLine = typeEndLine(s.Decls[len(s.Decls)-1].Type)
+		}
+		p.stmtList(s.Body, prevLine, ";", false)
+		p.indent--
+		p.newline()
+		p.flush(s.End)
+		p.write("]")
+	case *CallStmt:
+		p.write(exprString(s.Call))
+	case *ReturnStmt:
+		if s.Value != nil {
+			p.write("return " + exprString(s.Value))
+		} else {
+			p.write("return")
+		}
+	}
+}
+
+// Тело if/for/while: составной оператор остаётся на уровне заголовка, остальные сдвигаются
+func (p *printer) body(s Stmt) {
+	if _, ok := s.(*CompoundStmt); ok {
+		p.newline()
+		p.stmt(s)
+		return
+	}
+	p.indent++
+	p.newline()
+	p.stmt(s)
+	p.indent--
+}
+
+// Заголовок подпрограммы: procedure p(a, b : int; c : float) или function f(n : int) : int
+func subprogramHeader(sub *Subprogram) string {
+	params := make([]string, len(sub.Params))
+	for i, param := range sub.Params {
+		params[i] = declString(param)
+	}
+	header := sub.Tok.Lexeme + " " + sub.Name.Lexeme + "(" + strings.Join(params, "; ") + ")"
+	if sub.IsFunction() {
+		header += " : " + typeString(sub.Result)
+	}
+	return header
+}
+
+func declString(decl *VarDecl) string {
+	names := make([]string, len(decl.Names))
+	for i, name := range decl.Names {
+		names[i] = name.Lexeme
+	}
+	return strings.Join(names, ", ") + " : " + typeString(decl.Type)
+}
+
+// Запись типа в том виде, в каком он объявлен: int, Point,
+// array [1d..10d] of int или record x, y : float end
+func typeString(t *TypeSpec) string {
+	switch {
+	case t.Elem != nil:
+		return "array [" + exprString(t.Low) + ".." + exprString(t.High) + "] of " + typeString(t.Elem)
+	case t.Fields != nil:
+		fields := make([]string, len(t.Fields))
+		for i, field := range t.Fields {
+			fields[i] = declString(field)
+		}
+		return "record " + strings.Join(fields, "; ") + " end"
+	}
+	return t.Tok.Lexeme
+}
+
+// Номер последней строки, занимаемой записью типа
+func typeEndLine(t *TypeSpec) int {
+	switch {
+	case t.Elem != nil:
+		return typeEndLine(t.Elem)
+	case t.Fields != nil && t.End.LineNum > 0:
+		return t.End.LineNum
+	}
+	return t.Tok.LineNum
+}
+
+// Выражения через запятую
+func exprList(list []Expr) string {
+	items := make([]string, len(list))
+	for i, e := range list {
+		items[i] = exprString(e)
+	}
+	return strings.Join(items, ", ")
+}
+
+// Приоритет операции: 1 — отношения, 2 — сложения, 3 — умножения, 4 — множители
+func precedence(e Expr) int {
+	if b, ok := e.(*BinaryExpr); ok {
+		switch {
+		case isRelationOperator(b.Op.Lexeme):
+			return 1
+		case isAdditionOperator(b.Op.Lexeme):
+			return 2
+		default:
+			return 3
+		}
+	}
+	return 4
+}
+
+// Запись выражения с минимально необходимыми скобками
+func exprString(e Expr) string {
+	switch e := e.(type) {
+	case *BinaryExpr:
+		left, right := exprString(e.Left), exprString(e.Right)
+		if precedence(e.Left) < precedence(e) {
+			left = "(" + left + ")"
+		}
+		if precedence(e.Right) <= precedence(e) {
+			right = "(" + right + ")"
+		}
+		return left + " " + e.Op.Lexeme + " " + right
+	case *UnaryExpr:
+		x := exprString(e.X)
+		if precedence(e.X) < 4 {
+			x = "(" + x + ")"
+		}
+		return e.Op.Lexeme + x
+	case *Ident:
+		return e.Tok.Lexeme
+	case *IndexExpr:
+		return exprString(e.X) + "[" + exprString(e.Index) + "]"
+	case *FieldExpr:
+		return exprString(e.X) + "." + e.Name.Lexeme
+	case *NumberLit:
+		return e.Tok.Lexeme
+	case *BoolLit:
+		return e.Tok.Lexeme
+	case *StringLit:
+		return e.Tok.Lexeme
+	case *CallExpr:
+		return e.Name.Lexeme + "(" + exprList(e.Args) + ")"
+	}
+	return ""
+}
+
+// Направление цикла for: to или downto
+func forDir(s *ForStmt) string {
+	if s.Down() {
+		return "downto"
+	}
+	return "to"
+}
+
+// Метки ветви оператора case через запятую
+func caseLabelsString(labels []*CaseLabel) string {
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = caseLabelString(label)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Номер последней строки, занимаемой оператором
+func endLine(s Stmt) int {
+	switch s := s.(type) {
+	case *AssignStmt:
+		return exprEndLine(s.Value)
+	case *IfStmt:
+		if s.Else != nil {
+			return endLine(s.Else)
+		}
+		return endLine(s.Then)
+	case *ForStmt:
+		return endLine(s.Body)
+	case *WhileStmt:
+		return endLine(s.Body)
+	case *CaseStmt:
+		return s.End.LineNum
+	case *RepeatStmt:
+		return exprEndLine(s.Cond)
+	case *ReadStmt:
+		return exprEndLine(s.Targets[len(s.Targets)-1])
+	case *WriteStmt:
+		return exprEndLine(s.Args[len(s.Args)-1])
+	case *CompoundStmt:
+		return s.End.LineNum
+	case *CallStmt:
+		return exprEndLine(s.Call)
+	case *ReturnStmt:
+		if s.Value != nil {
+			return exprEndLine(s.Value)
+		}
+	}
+	return s.Pos().LineNum
+}
+
+func exprEndLine(e Expr) int {
+	switch e := e.(type) {
+	case *BinaryExpr:
+		return exprEndLine(e.Right)
+	case *UnaryExpr:
+		return exprEndLine(e.X)
+	case *CallExpr:
+		if e.End.LineNum > 0 {
+			return e.End.LineNum
+		}
+	case *IndexExpr:
+		if e.End.LineNum > 0 {
+			return e.End.LineNum
+		}
+	case *FieldExpr:
+		return e.Name.LineNum
+	}
+	return e.Pos().LineNum
+}
+
+// Печать дерева разбора в текстовом виде (tfi parse)
+func dumpTree(w io.Writer, prog *Program) {
+	fmt.Fprintf(w, "Program (%d:%d)\n", prog.Tok.LineNum, prog.Tok.ColNum)
+	dumpUses(w, prog.Uses)
+	dumpDecls(w, prog.Consts, prog.Types, prog.Decls)
+	for _, sub := range prog.Subprograms {
+		dumpSubprogram(w, sub)
+	}
+	for _, s := range prog.Body {
+		dumpStmt(w, s, 1)
+	}
+}
+
+func dumpUnit(w io.Writer, unit *Unit) {
+	fmt.Fprintf(w, "Unit %s (%d:%d)\n", unit.Name.Lexeme, unit.Tok.LineNum, unit.Tok.ColNum)
+	dumpUses(w, unit.Uses)
+	dumpDecls(w, unit.Consts, unit.Types, nil)
+	for _, sub := range unit.Headings {
+		fmt.Fprintf(w, "%sHeading %s (%d:%d)\n", indentUnit, subprogramHeader(sub), sub.Pos().LineNum, sub.Pos().ColNum)
+	}
+	fmt.Fprintf(w, "%sImplementation (%d:%d)\n", indentUnit, unit.Impl.LineNum, unit.Impl.ColNum)
+	dumpDecls(w, nil, nil, unit.Decls)
+	for _, sub := range unit.Subprograms {
+		dumpSubprogram(w, sub)
+	}
+}
+
+func dumpUses(w io.Writer, names []Token) {
+	for _, name := range names {
+		fmt.Fprintf(w, "%sUses %s (%d:%d)\n", indentUnit, name.Lexeme, name.LineNum, name.ColNum)
+	}
+}
+
+func dumpDecls(w io.Writer, consts []*ConstDecl, types []*TypeDecl, decls []*VarDecl) {
+	for _, decl := range consts {
+		fmt.Fprintf(w, "%sConstDecl %s = %s (%d:%d)\n", indentUnit, decl.Name.Lexeme, exprString(decl.Value), decl.Pos().LineNum, decl.Pos().ColNum)
+	}
+	for _, decl := range types {
+		fmt.Fprintf(w, "%sTypeDecl %s = %s (%d:%d)\n", indentUnit, decl.Name.Lexeme, typeString(decl.Type), decl.Pos().LineNum, decl.Pos().ColNum)
+	}
+	for _, decl := range decls {
+		fmt.Fprintf(w, "%sVarDecl %s (%d:%d)\n", indentUnit, declString(decl), decl.Pos().LineNum, decl.Pos().ColNum)
+	}
+}
+
+func dumpSubprogram(w io.Writer, sub *Subprogram) {
+	kind := "Procedure"
+	if sub.IsFunction() {
+		kind = "Function"
+	}
+	fmt.Fprintf(w, "%s%s %s (%d:%d)\n", indentUnit, kind, strings.TrimPrefix(subprogramHeader(sub), sub.Tok.Lexeme+" "), sub.Pos().LineNum, sub.Pos().ColNum)
+	for _, decl := range sub.Decls {
+		fmt.Fprintf(w, "%sVarDecl %s (%d:%d)\n", strings.Repeat(indentUnit, 2), declString(decl), decl.Pos().LineNum, decl.Pos().ColNum)
+	}
+	for _, s := range sub.Body {
+		dumpStmt(w, s, 2)
+	}
+}
+
+func dumpStmt(w io.Writer, s Stmt, depth int) {
+	pad := strings.Repeat(indentUnit, depth)
+	pos := s.Pos()
+	switch s := s.(type) {
+	case *AssignStmt:
+		fmt.Fprintf(w, "%sAssign %s (%d:%d)\n", pad, exprString(s.Target), pos.LineNum, pos.ColNum)
+		dumpExpr(w, s.Value, depth+1)
+	case *IfStmt:
+		fmt.Fprintf(w, "%sIf (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+		dumpExpr(w, s.Cond, depth+1)
+		dumpStmt(w, s.Then, depth+1)
+		if s.Else != nil {
+			dumpStmt(w, s.Else, depth+1)
+		}
+	case *ForStmt:
+		fmt.Fprintf(w, "%sFor %s (%d:%d)\n", pad, forDir(s), pos.LineNum, pos.ColNum)
+		dumpStmt(w, s.Init, depth+1)
+		dumpExpr(w, s.To, depth+1)
+		if s.Step != nil {
+			fmt.Fprintf(w, "%s%sStep\n", pad, indentUnit)
+			dumpExpr(w, s.Step, depth+2)
+		}
+		dumpStmt(w, s.Body, depth+1)
+	case *WhileStmt:
+		fmt.Fprintf(w, "%sWhile (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+		dumpExpr(w, s.Cond, depth+1)
+		dumpStmt(w, s.Body, depth+1)
+	case *RepeatStmt:
+		fmt.Fprintf(w, "%sRepeat (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+		for _, st := range s.Body {
+			dumpStmt(w, st, depth+1)
+		}
+		dumpExpr(w, s.Cond, depth+1)
+	case *BreakStmt:
+		fmt.Fprintf(w, "%sBreak (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+	case *ContinueStmt:
+		fmt.Fprintf(w, "%sContinue (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+	case *CaseStmt:
+		fmt.Fprintf(w, "%sCase (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+		dumpExpr(w, s.Selector, depth+1)
+		for _, branch := range s.Branches {
+			bpos := branch.Pos()
+			fmt.Fprintf(w, "%s%sBranch %s (%d:%d)\n", pad, indentUnit, caseLabelsString(branch.Labels), bpos.LineNum, bpos.ColNum)
+			dumpStmt(w, branch.Body, depth+2)
+		}
+		if s.Else != nil {
+			fmt.Fprintf(w, "%s%sElse\n", pad, indentUnit)
+			dumpStmt(w, s.Else, depth+2)
+		}
+	case *ReadStmt:
+		fmt.Fprintf(w, "%sRead %s (%d:%d)\n", pad, exprList(s.Targets), pos.LineNum, pos.ColNum)
+	case *WriteStmt:
+		fmt.Fprintf(w, "%sWrite (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+		for _, arg := range s.Args {
+			dumpExpr(w, arg, depth+1)
+		}
+	case *CompoundStmt:
+		fmt.Fprintf(w, "%sCompound (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+		for _, decl := range s.Decls {
+			fmt.Fprintf(w, "%s%sVarDecl %s (%d:%d)\n", pad, indentUnit, declString(decl), decl.Pos().LineNum, decl.Pos().ColNum)
+		}
+		for _, st := range s.Body {
+			dumpStmt(w, st, depth+1)
+		}
+	case *CallStmt:
+		dumpExpr(w, s.Call, depth)
+	case *ReturnStmt:
+		fmt.Fprintf(w, "%sReturn (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+		if s.Value != nil {
+			dumpExpr(w, s.Value, depth+1)
+		}
+	}
+}
+
+func dumpExpr(w io.Writer, e Expr, depth int) {
+	pad := strings.Repeat(indentUnit, depth)
+	pos := e.Pos()
+	switch e := e.(type) {
+	case *BinaryExpr:
+		fmt.Fprintf(w, "%sBinary %s (%d:%d)\n", pad, e.Op.Lexeme, e.Op.LineNum, e.Op.ColNum)
+		dumpExpr(w, e.Left, depth+1)
+		dumpExpr(w, e.Right, depth+1)
+	case *UnaryExpr:
+		fmt.Fprintf(w, "%sUnary %s (%d:%d)\n", pad, e.Op.Lexeme, pos.LineNum, pos.ColNum)
+		dumpExpr(w, e.X, depth+1)
+	case *Ident:
+		kind := "Ident"
+		if e.Const != nil {
+			kind = "Const"
+		}
+		fmt.Fprintf(w, "%s%s %s (%d:%d)\n", pad, kind, e.Tok.Lexeme, pos.LineNum, pos.ColNum)
+	case *IndexExpr:
+		fmt.Fprintf(w, "%sIndex (%d:%d)\n", pad, pos.LineNum, pos.ColNum)
+		dumpExpr(w, e.X, depth+1)
+		dumpExpr(w, e.Index, depth+1)
+	case *FieldExpr:
+		fmt.Fprintf(w, "%sField %s (%d:%d)\n", pad, e.Name.Lexeme, e.Name.LineNum, e.Name.ColNum)
+		dumpExpr(w, e.X, depth+1)
+	case *NumberLit:
+		fmt.Fprintf(w, "%sNumber %s (%d:%d)\n", pad, e.Tok.Lexeme, pos.LineNum, pos.ColNum)
+	case *BoolLit:
+		fmt.Fprintf(w, "%sBool %s (%d:%d)\n", pad, e.Tok.Lexeme, pos.LineNum, pos.ColNum)
+	case *StringLit:
+		fmt.Fprintf(w, "%s%s %s (%d:%d)\n", pad, TokenTypeToString(e.Tok.Type), e.Tok.Lexeme, pos.LineNum, pos.ColNum)
+	case *CallExpr:
+		fmt.Fprintf(w, "%sCall %s (%d:%d)\n", pad, e.Name.Lexeme, pos.LineNum, pos.ColNum)
+		for _, arg := range e.Args {
+			dumpExpr(w, arg, depth+1)
+		}
+	}
+}
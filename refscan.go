@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Посимвольный лексический анализатор, который использовался до табличного
+// (scanner.go). Сохранён как эталон: команда bench сравнивает с ним результат
+// и скорость табличного анализатора.
+func scanReference(reader io.Reader, comments *[]Token) ([]Token, error) {
+	var tokens []Token
+	// Столбцы считаются в символах (рунах), начиная с 1
+	var lineNum, colNum int = 1, 0
+	// offset — число прочитанных байт; start и startCol — начало текущей лексемы
+	var offset, start, startCol int
+
+	bufReader := bufio.NewReader(reader)
+	var sb strings.Builder
+
+	// Токен для текущей лексемы (тип уточняется по таблицам ключевых слов и операторов)
+	word := func(lexeme string) Token {
+		typ := TokenIdentifier
+		if isKeyword(lexeme) {
+			typ = TokenKeyword
+		} else if isOperator(lexeme) {
+			typ = TokenOperator
+		}
+		return Token{Type: typ, Lexeme: lexeme, LineNum: lineNum, ColNum: startCol, Offset: start}
+	}
+	lexError := func(lexeme string, format string, args ...any) error {
+		tok := Token{Lexeme: lexeme, LineNum: lineNum, ColNum: startCol, Offset: start}
+		return newDiagnostic(StageLexical, tok, format, args...)
+	}
+	// Токен числовой константы с вычисленным значением; ошибка указывает
+	// на символ, из-за которого запись некорректна
+	number := func(lexeme string) (Token, error) {
+		val, base, err := scanNumber(lexeme)
+		if ne, ok := err.(*numberError); ok {
+			// Символы записи числа однобайтовые, поэтому смещение считается как номер символа
+			bad := ""
+			if runes := []rune(lexeme); ne.Pos < len(runes) {
+				bad = string(runes[ne.Pos])
+			}
+			tok := Token{Lexeme: bad, LineNum: lineNum, ColNum: startCol + ne.Pos, Offset: start + ne.Pos}
+			return Token{}, newDiagnostic(StageLexical, tok, ne.Format, ne.Args...)
+		}
+		return Token{Type: TokenNumber, Lexeme: lexeme, LineNum: lineNum, ColNum: startCol, Offset: start, Value: val, Base: base}, nil
+	}
+
+	state := "H"
+	for {
+		ch, size, err := bufReader.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				if state == "ID" {
+					tokens = append(tokens, word(sb.String()))
+				} else if state == "NUM" {
+					tok, err := number(sb.String())
+					if err != nil {
+						return nil, err
+					}
+					tokens = append(tokens, tok)
+				} else if state == "OP" {
+					lexeme := sb.String()
+					if isOperator(lexeme) {
+						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: startCol, Offset: start})
+					} else {
+						return nil, lexError(lexeme, "Неизвестная операция '%s'", lexeme)
+					}
+				}
+				break
+			}
+			return nil, err
+		}
+
+		colNum++
+		offset += size
+
+		switch state {
+		case "H":
+			start, startCol = offset-size, colNum
+			if unicode.IsSpace(ch) {
+				if ch == '\n' {
+					lineNum++
+					colNum = 0
+				}
+				continue
+			} else if unicode.IsLetter(ch) {
+				sb.WriteRune(ch)
+				state = "ID"
+			} else if unicode.IsDigit(ch) {
+				sb.WriteRune(ch)
+				state = "NUM"
+			} else if ch == '"' || ch == '\'' {
+				// Строковая ("...") или символьная ('.') константа; лексема хранится
+				// вместе с кавычками, перевод строки внутри константы недопустим
+				sb.WriteRune(ch)
+				for {
+					c, size, err := bufReader.ReadRune()
+					if err != nil || c == '\n' {
+						return nil, lexError(string(ch), "Незакрытая константа: ожидалась %c", ch)
+					}
+					colNum++
+					offset += size
+					sb.WriteRune(c)
+					if c == ch {
+						break
+					}
+					if c != '\\' {
+						continue
+					}
+					e, size, err := bufReader.ReadRune()
+					if err != nil || e == '\n' {
+						return nil, lexError(string(ch), "Незакрытая константа: ожидалась %c", ch)
+					}
+					if _, ok := escapes[e]; !ok {
+						tok := Token{Lexeme: `\` + string(e), LineNum: lineNum, ColNum: colNum, Offset: offset - 1}
+						return nil, newDiagnostic(StageLexical, tok, "Неизвестная escape-последовательность '%s'", tok.Lexeme)
+					}
+					colNum++
+					offset += size
+					sb.WriteRune(e)
+				}
+				tok := Token{Type: TokenString, Lexeme: sb.String(), LineNum: lineNum, ColNum: startCol, Offset: start}
+				sb.Reset()
+				if ch == '\'' {
+					tok.Type = TokenChar
+				}
+				// Escape-последовательности уже проверены, поэтому ошибки быть не может
+				str, _ := decodeString(tok.Lexeme)
+				tok.Value = Value{Kind: KindString, Str: str}
+				if tok.Type == TokenChar {
+					tok.Value.Kind = KindChar
+					if n := utf8.RuneCountInString(str); n != 1 {
+						return nil, newDiagnostic(StageLexical, tok, "Символьная константа должна содержать один символ, получено %d", n)
+					}
+				}
+				tokens = append(tokens, tok)
+			} else if isDelimiter(ch) {
+				// Обработка комментариев
+				if ch == '{' {
+					comment := Token{Type: TokenComment, LineNum: lineNum, ColNum: colNum, Offset: start}
+					sb.WriteRune(ch)
+					for {
+						ch, size, err = bufReader.ReadRune()
+						if err != nil {
+							tok := Token{Lexeme: "{", LineNum: comment.LineNum, ColNum: comment.ColNum, Offset: comment.Offset}
+							return nil, newDiagnostic(StageLexical, tok, "Некорректный комментарий: ожидался '}'")
+						}
+						offset += size
+						sb.WriteRune(ch)
+						if ch == '\n' {
+							lineNum++
+							colNum = 0
+						} else {
+							colNum++
+						}
+						if ch == '}' {
+							break
+						}
+					}
+					if strings.HasPrefix(sb.String(), "{$") {
+						directive := comment
+						directive.Type, directive.Lexeme = TokenDirective, sb.String()
+						tokens = append(tokens, directive)
+					} else if comments != nil {
+						comment.Lexeme = sb.String()
+						*comments = append(*comments, comment)
+					}
+					sb.Reset()
+				} else if ch == '.' && nextIs(bufReader, '.') {
+					// Диапазон границ массива: '..'
+					bufReader.ReadRune()
+					colNum++
+					offset++
+					tokens = append(tokens, Token{Type: TokenDelimiter, Lexeme: "..", LineNum: lineNum, ColNum: startCol, Offset: start})
+				} else {
+					tokens = append(tokens, Token{Type: TokenDelimiter, Lexeme: string(ch), LineNum: lineNum, ColNum: colNum, Offset: start})
+				}
+			} else if isOperator(string(ch)) {
+				sb.WriteRune(ch)
+				state = "OP"
+			} else {
+				return nil, lexError(string(ch), "Неизвестный символ '%c'", ch)
+			}
+		case "ID":
+			if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
+				sb.WriteRune(ch)
+			} else {
+				tokens = append(tokens, word(sb.String()))
+				sb.Reset()
+				state = "H"
+				bufReader.UnreadRune()
+				colNum--
+				offset -= size
+			}
+		case "NUM":
+			if ch == '.' && nextIs(bufReader, '.') {
+				// '..' после числа — разделитель границ массива, а не десятичная точка
+				tok, err := number(sb.String())
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, tok)
+				bufReader.ReadRune()
+				tokens = append(tokens, Token{Type: TokenDelimiter, Lexeme: "..", LineNum: lineNum, ColNum: colNum, Offset: offset - size})
+				colNum++
+				offset++
+				sb.Reset()
+				state = "H"
+				continue
+			}
+			if unicode.IsDigit(ch) || ch == '.' || ch == 'e' || ch == 'E' || ch == '+' || ch == '-' ||
+				ch == 'b' || ch == 'o' || ch == 'h' || ch == 'd' ||
+				(ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F') {
+				sb.WriteRune(ch)
+			} else {
+				// Буква или цифра сразу после числа — часть некорректной записи числа
+				lexeme := sb.String()
+				if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
+					_, err := number(lexeme + string(ch))
+					if err != nil {
+						return nil, err
+					}
+				}
+				tok, err := number(lexeme)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, tok)
+				sb.Reset()
+				state = "H"
+				bufReader.UnreadRune()
+				colNum--
+				offset -= size
+			}
+
+		case "OP":
+			lexeme := sb.String()
+			if isOperator(lexeme) {
+				tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: startCol, Offset: start})
+				sb.Reset()
+				state = "H"
+				bufReader.UnreadRune()
+				colNum--
+				offset -= size
+			} else {
+				chNext, sizeNext, err := bufReader.ReadRune()
+				if err != nil && err != io.EOF {
+					return nil, err
+				}
+				if err == nil {
+					offset += sizeNext
+					sb.WriteRune(chNext)
+					lexeme = sb.String()
+					if isOperator(lexeme) {
+						colNum++
+						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: startCol, Offset: start})
+						sb.Reset()
+						state = "H"
+					} else {
+						// Если это не оператор, возвращаем последний прочитанный символ
+						sb.Reset()
+						sb.WriteRune(ch)
+						bufReader.UnreadRune()
+						offset -= sizeNext
+						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: string(ch), LineNum: lineNum, ColNum: startCol, Offset: start})
+						state = "H"
+					}
+				} else {
+					// EOF после оператора
+					if isOperator(lexeme) {
+						tokens = append(tokens, Token{Type: TokenOperator, Lexeme: lexeme, LineNum: lineNum, ColNum: startCol, Offset: start})
+						sb.Reset()
+						state = "H"
+					} else {
+						return nil, lexError(lexeme, "Неизвестная операция '%s'", lexeme)
+					}
+				}
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+// Проверка следующего символа без его чтения
+func nextIs(r *bufio.Reader, c byte) bool {
+	b, err := r.Peek(1)
+	return err == nil && b[0] == c
+}
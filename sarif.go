@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Вывод диагностик в формате SARIF 2.1.0 (tfi check -format=sarif) для систем
+// анализа кода (GitHub code scanning, GitLab). Все файлы попадают в один запуск
+// (run); столбцы считаются в символах Unicode, как и в сообщениях tfi.
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool       `json:"tool"`
+	ColumnKind string          `json:"columnKind"`
+	Artifacts  []sarifArtifact `json:"artifacts"`
+	Results    []sarifResult   `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	Name                 string       `json:"name"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifArtifact struct {
+	Location sarifArtifactLocation `json:"location"`
+}
+
+type sarifArtifactLocation struct {
+	URI   string `json:"uri"`
+	Index int    `json:"index"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	RuleIndex        *int            `json:"ruleIndex,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+	ByteOffset  int `json:"byteOffset"`
+	ByteLength  int `json:"byteLength"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+func newSarifLog() *sarifLog {
+	driver := sarifDriver{Name: "tfi"}
+	for _, r := range rules {
+		rule := sarifRule{ID: r.Code, Name: r.Name, ShortDescription: sarifMessage{tr(r.Description)}}
+		rule.DefaultConfiguration.Level = "error"
+		if strings.HasPrefix(r.Code, "W") {
+			rule.DefaultConfiguration.Level = "warning"
+		}
+		driver.Rules = append(driver.Rules, rule)
+	}
+	return &sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: driver},
+			ColumnKind: "unicodeCodePoints",
+			Artifacts:  []sarifArtifact{},
+			Results:    []sarifResult{},
+		}},
+	}
+}
+
+// Добавление файла и его диагностик (diags может быть пустым)
+func (l *sarifLog) addFile(name string, src []byte, diags []*Diagnostic) {
+	run := &l.Runs[0]
+	uri := name
+	if name == "-" {
+		uri = "stdin"
+	}
+	artifact := sarifArtifactLocation{URI: uri, Index: len(run.Artifacts)}
+	run.Artifacts = append(run.Artifacts, sarifArtifact{Location: artifact})
+
+	// Позиции в подключённых модулях относятся к файлам модулей
+	artifactOf := func(tok Token) (sarifArtifactLocation, []byte) {
+		if tok.File == "" {
+			return artifact, src
+		}
+		path, text := sourceOf(tok, name, src)
+		uri := displayPath(path)
+		for _, a := range run.Artifacts {
+			if a.Location.URI == uri {
+				return a.Location, text
+			}
+		}
+		unit := sarifArtifactLocation{URI: uri, Index: len(run.Artifacts)}
+		run.Artifacts = append(run.Artifacts, sarifArtifact{Location: unit})
+		return unit, text
+	}
+	location := func(tok Token) sarifPhysicalLocation {
+		artifact, text := artifactOf(tok)
+		return sarifPhysicalLocation{ArtifactLocation: artifact, Region: sarifRegionAt(text, tok.Offset, len(tok.Lexeme))}
+	}
+	for _, d := range diags {
+		level := "error"
+		if d.Warning {
+			level = "warning"
+		}
+		result := sarifResult{
+			RuleID:    d.Code,
+			Level:     level,
+			Message:   sarifMessage{d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location(d.Tok)}},
+		}
+		for i, r := range rules {
+			if r.Code == d.Code {
+				index := i
+				result.RuleIndex = &index
+			}
+		}
+		if d.Help != "" {
+			result.Message.Text += " (" + d.Help + ")"
+		}
+		for i, label := range d.Labels {
+			id := i + 1
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				ID:               &id,
+				PhysicalLocation: location(label.Tok),
+				Message:          &sarifMessage{label.Message},
+			})
+		}
+		for _, fix := range d.Fixes {
+			if fix.Manual {
+				continue
+			}
+			// Правки относятся к тому же файлу, что и диагностика
+			artifact, text := artifactOf(d.Tok)
+			change := sarifArtifactChange{ArtifactLocation: artifact}
+			for _, edit := range fix.Edits {
+				change.Replacements = append(change.Replacements, sarifReplacement{
+					DeletedRegion:   sarifRegionAt(text, edit.Offset, edit.Length),
+					InsertedContent: sarifMessage{edit.NewText},
+				})
+			}
+			result.Fixes = append(result.Fixes, sarifFix{
+				Description:     sarifMessage{fix.Message},
+				ArtifactChanges: []sarifArtifactChange{change},
+			})
+		}
+		run.Results = append(run.Results, result)
+	}
+}
+
+func (l *sarifLog) write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+// Область текста по смещению и длине в байтах
+func sarifRegionAt(src []byte, offset, length int) sarifRegion {
+	startLine, startColumn := positionAt(src, offset)
+	endLine, endColumn := positionAt(src, offset+length)
+	return sarifRegion{
+		StartLine: startLine, StartColumn: startColumn,
+		EndLine: endLine, EndColumn: endColumn,
+		ByteOffset: offset, ByteLength: length,
+	}
+}
+
+// Строка и столбец (с 1, столбцы в символах) для смещения в байтах
+func positionAt(src []byte, offset int) (int, int) {
+	line, col := 1, 1
+	for i := 0; i < offset && i < len(src); {
+		r, size := utf8.DecodeRune(src[i:])
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+		i += size
+	}
+	return line, col
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Отчёт check -format=sarif проверяется по снимку схемы SARIF 2.1.0 из
+// testdata (OASIS, errata01). Схема записана в JSON Schema draft-04; валидатор
+// ниже поддерживает ключевые слова, которые в ней встречаются.
+
+type jsonSchema struct {
+	root map[string]any
+}
+
+func loadSchema(t *testing.T, path string) *jsonSchema {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return &jsonSchema{root: root}
+}
+
+// Ошибки соответствия значения v схеме node; path — путь к значению в документе
+func (s *jsonSchema) validate(node map[string]any, v any, path string) []string {
+	if ref, ok := node["$ref"].(string); ok {
+		name, ok := strings.CutPrefix(ref, "#/definitions/")
+		def, _ := s.root["definitions"].(map[string]any)[name].(map[string]any)
+		if !ok || def == nil {
+			return []string{fmt.Sprintf("%s: неизвестная ссылка %s", path, ref)}
+		}
+		return s.validate(def, v, path)
+	}
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	if typ, ok := node["type"]; ok && !matchesType(typ, v) {
+		fail("значение %v не типа %v", v, typ)
+		return errs
+	}
+	if enum, ok := node["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if e == v {
+				found = true
+			}
+		}
+		if !found {
+			fail("значение %v не из %v", v, enum)
+		}
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		list, ok := node[key].([]any)
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, alt := range list {
+			if len(s.validate(alt.(map[string]any), v, path)) == 0 {
+				matched++
+			}
+		}
+		if matched == 0 || (key == "oneOf" && matched > 1) {
+			fail("%s: подходит вариантов %d", key, matched)
+		}
+	}
+	switch v := v.(type) {
+	case float64:
+		if min, ok := node["minimum"].(float64); ok && v < min {
+			fail("%v меньше %v", v, min)
+		}
+		if max, ok := node["maximum"].(float64); ok && v > max {
+			fail("%v больше %v", v, max)
+		}
+	case string:
+		if pattern, ok := node["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(v) {
+			fail("%q не соответствует %s", v, pattern)
+		}
+	case []any:
+		if min, ok := node["minItems"].(float64); ok && float64(len(v)) < min {
+			fail("элементов %d, нужно не меньше %v", len(v), min)
+		}
+		if node["uniqueItems"] == true {
+			seen := make(map[string]bool)
+			for _, item := range v {
+				key, _ := json.Marshal(item)
+				if seen[string(key)] {
+					fail("повторяющийся элемент %s", key)
+				}
+				seen[string(key)] = true
+			}
+		}
+		if items, ok := node["items"].(map[string]any); ok {
+			for i, item := range v {
+				errs = append(errs, s.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]any:
+		props, _ := node["properties"].(map[string]any)
+		if required, ok := node["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := v[name.(string)]; !ok {
+					fail("нет обязательного свойства '%s'", name)
+				}
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := props[name].(map[string]any); ok {
+				errs = append(errs, s.validate(prop, v[name], path+"."+name)...)
+				continue
+			}
+			switch extra := node["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					fail("лишнее свойство '%s'", name)
+				}
+			case map[string]any:
+				errs = append(errs, s.validate(extra, v[name], path+"."+name)...)
+			}
+		}
+	}
+	return errs
+}
+
+func matchesType(typ, v any) bool {
+	if list, ok := typ.([]any); ok {
+		for _, t := range list {
+			if matchesType(t, v) {
+				return true
+			}
+		}
+		return false
+	}
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+func TestCheckSarifMatchesSchema(t *testing.T) {
+	schema := loadSchema(t, filepath.Join("testdata", "sarif-schema-2.1.0.json"))
+	dir := t.TempDir()
+	files := []struct{ name, text string }{
+		{"ok.txt", "program var a : int;\nbegin\n    a as 1d\nend.\n"},
+		// Предупреждение W001: case по логическому выражению без ветви false
+		{"warn.txt", "program var f : bool;\nbegin\n    case f of true: write(1d) end\nend.\n"},
+		// Ошибка S006 с исправлением: пропущена ';' после объявления
+		{"fix.txt", "program var a : int\nbegin\n    a as 1d\nend.\n"},
+	}
+	opts := &options{format: "sarif", from: "source"}
+	for _, f := range files {
+		path := writeFile(t, dir, f.name, f.text)
+		runCheck(opts, path, []byte(f.text))
+	}
+	var buf bytes.Buffer
+	if err := opts.sarif.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var log map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range schema.validate(schema.root, log, "$") {
+		t.Error(err)
+	}
+
+	// Отчёт содержит и предупреждение, и исправление, иначе проверка по схеме их не покрывает
+	levels := make(map[string]bool)
+	fixes := 0
+	for _, r := range log["runs"].([]any)[0].(map[string]any)["results"].([]any) {
+		result := r.(map[string]any)
+		levels[result["level"].(string)] = true
+		if list, ok := result["fixes"].([]any); ok {
+			fixes += len(list)
+		}
+	}
+	if !levels["warning"] || !levels["error"] || fixes == 0 {
+		t.Errorf("уровни результатов %v, исправлений %d: нужны warning, error и исправление", levels, fixes)
+	}
+}
+
+// Валидатор находит нарушения схемы, а не пропускает любой документ
+func TestSarifSchemaRejectsInvalidLog(t *testing.T) {
+	schema := loadSchema(t, filepath.Join("testdata", "sarif-schema-2.1.0.json"))
+	cases := map[string]string{
+		"version":  `{"version":"2.0.0","runs":[]}`,
+		"required": `{"version":"2.1.0"}`,
+		"extra":    `{"version":"2.1.0","runs":[],"unknown":1}`,
+		"level":    `{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"tfi"}},"results":[{"message":{"text":"x"},"level":"fatal"}]}]}`,
+		"minimum":  `{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"tfi"}},"results":[{"message":{"text":"x"},"locations":[{"physicalLocation":{"region":{"startLine":0}}}]}]}]}`,
+	}
+	for name, text := range cases {
+		var log any
+		if err := json.Unmarshal([]byte(text), &log); err != nil {
+			t.Fatal(err)
+		}
+		if len(schema.validate(schema.root, log, "$")) == 0 {
+			t.Errorf("%s: нарушение схемы не найдено", name)
+		}
+	}
+}
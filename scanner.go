@@ -0,0 +1,418 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Табличный лексический анализатор. Все классы токенов распознаются одним
+// детерминированным конечным автоматом: переход из состояния определяется
+// таблицей по классу очередного символа. Классы символов — сами байты ASCII
+// и четыре класса для остальных символов Unicode (буква, цифра, пробельный
+// символ, прочие). Ключевые слова и слова-операции (plus, EQ, ...) входят
+// в автомат как бор поверх состояний идентификатора, поэтому отдельный поиск
+// по спискам не нужен.
+//
+// Автомат читает самую длинную лексему: запоминается последнее допускающее
+// состояние, и при отсутствии перехода токен заканчивается в нём. Вид
+// допускающего состояния определяет, какой токен получится. Состояния без
+// допуска (незакрытые строки и комментарии) при остановке дают ошибку.
+
+// Классы символов, не входящих в ASCII
+const (
+	classLetter = 128 + iota
+	classDigit
+	classSpace
+	classOther
+	numClasses
+)
+
+// Вид допускающего состояния автомата
+type acceptKind uint8
+
+const (
+	acceptNone        acceptKind = iota
+	acceptSpace                  // пробельные символы, токена нет
+	acceptIdentifier             // идентификатор
+	acceptKeyword                // ключевое слово
+	acceptOperator               // операция (слово или ~)
+	acceptNumber                 // число
+	acceptNumberRange            // число и '..' за ним: 1..5
+	acceptBadNumber              // число, за которым сразу идёт буква: ошибка записи числа
+	acceptDelimiter              // разделитель
+	acceptString                 // строковая константа
+	acceptChar                   // символьная константа
+	acceptComment                // комментарий
+	acceptDirective              // директива препроцессора {$...}
+)
+
+// Ошибка при остановке в недопускающем состоянии
+type stuckKind uint8
+
+const (
+	stuckUnknownChar stuckKind = iota // неизвестный символ в начале токена
+	stuckString                       // незакрытая константа или неизвестная escape-последовательность в ней
+	stuckComment                      // незакрытый комментарий
+)
+
+type scannerDFA struct {
+	next   [][numClasses]uint16 // переходы; 0 — перехода нет
+	accept []acceptKind
+	stuck  []stuckKind
+	words  []string // лексема ключевого слова или операции, распознаваемой состоянием ("" — берётся из текста)
+}
+
+// Начальное состояние; состояние 0 означает отсутствие перехода
+const dfaStart = 1
+
+var dfa = buildScannerDFA()
+
+func (d *scannerDFA) state(accept acceptKind, stuck stuckKind) uint16 {
+	d.next = append(d.next, [numClasses]uint16{})
+	d.accept = append(d.accept, accept)
+	d.stuck = append(d.stuck, stuck)
+	d.words = append(d.words, "")
+	return uint16(len(d.next) - 1)
+}
+
+// Переходы из состояния from в to по всем символам строки chars
+func (d *scannerDFA) on(from uint16, chars string, to uint16) {
+	for i := 0; i < len(chars); i++ {
+		d.next[from][chars[i]] = to
+	}
+}
+
+// Переходы по всем классам символов, кроме перечисленных в except
+func (d *scannerDFA) onAll(from uint16, except string, to uint16) {
+	for c := 0; c < numClasses; c++ {
+		if c >= 128 || !containsByte(except, byte(c)) {
+			d.next[from][c] = to
+		}
+	}
+}
+
+func containsByte(s string, c byte) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	asciiLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	asciiDigits  = "0123456789"
+	asciiSpaces  = " \t\n\v\f\r"
+	// Символы, из которых может состоять запись числа (кроме '.')
+	numberChars = asciiDigits + "abcdefABCDEF" + "oh+-"
+)
+
+func buildScannerDFA() *scannerDFA {
+	d := &scannerDFA{}
+	d.state(acceptNone, stuckUnknownChar) // 0: перехода нет
+	start := d.state(acceptNone, stuckUnknownChar)
+
+	space := d.state(acceptSpace, 0)
+	d.on(start, asciiSpaces, space)
+	d.on(space, asciiSpaces, space)
+	d.next[start][classSpace] = space
+	d.next[space][classSpace] = space
+
+	// Идентификаторы: буква, затем буквы и цифры
+	ident := d.state(acceptIdentifier, 0)
+	identTail := func(s uint16) {
+		d.on(s, asciiLetters+asciiDigits, ident)
+		d.next[s][classLetter] = ident
+		d.next[s][classDigit] = ident
+	}
+	d.on(start, asciiLetters, ident)
+	d.next[start][classLetter] = ident
+	identTail(ident)
+	// Бор ключевых слов и слов-операций; ключевые слова добавляются последними,
+	// поэтому and и or, входящие в оба списка, распознаются как ключевые слова
+	words := func(list []string, kind acceptKind) {
+		for _, w := range list {
+			if !isLetter(w[0]) {
+				continue
+			}
+			s := start
+			for i := 0; i < len(w); i++ {
+				n := d.next[s][w[i]]
+				if n == ident || n == 0 {
+					n = d.state(acceptIdentifier, 0)
+					identTail(n)
+					d.next[s][w[i]] = n
+				}
+				s = n
+			}
+			d.accept[s], d.words[s] = kind, w
+		}
+	}
+	words(operators, acceptOperator)
+	words(keyWords, acceptKeyword)
+
+	// Числа: цифра, затем символы записи числа; проверку записи и значение
+	// даёт scanNumber. '..' после числа — разделитель диапазона
+	number := d.state(acceptNumber, 0)
+	numberDot := d.state(acceptNumber, 0)
+	numberRange := d.state(acceptNumberRange, 0)
+	badNumber := d.state(acceptBadNumber, 0)
+	d.on(start, asciiDigits, number)
+	d.next[start][classDigit] = number
+	for _, s := range []uint16{number, numberDot} {
+		d.on(s, asciiLetters, badNumber)
+		d.next[s][classLetter] = badNumber
+		d.next[s][classDigit] = number
+		d.on(s, numberChars, number)
+	}
+	d.on(number, ".", numberDot)
+	d.on(numberDot, ".", numberRange)
+
+	// Разделители и операция ~
+	delimiter := d.state(acceptDelimiter, 0)
+	d.on(start, ";:,()=[]}", delimiter)
+	dot := d.state(acceptDelimiter, 0)
+	d.on(start, ".", dot)
+	d.on(dot, ".", d.state(acceptDelimiter, 0))
+	tilde := d.state(acceptOperator, 0)
+	d.on(start, "~", tilde)
+	d.words[tilde] = "~"
+
+	// Комментарии { ... } и директивы {$ ... }
+	commentStart := d.state(acceptNone, stuckComment)
+	comment := d.state(acceptNone, stuckComment)
+	directive := d.state(acceptNone, stuckComment)
+	commentEnd := d.state(acceptComment, 0)
+	directiveEnd := d.state(acceptDirective, 0)
+	d.on(start, "{", commentStart)
+	d.onAll(commentStart, "}$", comment)
+	d.on(commentStart, "$", directive)
+	d.on(commentStart, "}", commentEnd)
+	d.onAll(comment, "}", comment)
+	d.on(comment, "}", commentEnd)
+	d.onAll(directive, "}", directive)
+	d.on(directive, "}", directiveEnd)
+
+	// Строковые и символьные константы: перевод строки внутри не допускается
+	for _, q := range []struct {
+		quote  string
+		accept acceptKind
+	}{{`"`, acceptString}, {`'`, acceptChar}} {
+		body := d.state(acceptNone, stuckString)
+		escape := d.state(acceptNone, stuckString)
+		d.on(start, q.quote, body)
+		d.onAll(body, q.quote+"\\\n", body)
+		d.on(body, q.quote, d.state(q.accept, 0))
+		d.on(body, "\\", escape)
+		for e := range escapes {
+			d.next[escape][e] = body
+		}
+	}
+	return d
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Класс символа в позиции i и его длина в байтах
+func charClass(src []byte, i int) (int, int) {
+	c := src[i]
+	if c < utf8.RuneSelf {
+		return int(c), 1
+	}
+	r, size := utf8.DecodeRune(src[i:])
+	switch {
+	case unicode.IsLetter(r):
+		return classLetter, size
+	case unicode.IsDigit(r):
+		return classDigit, size
+	case unicode.IsSpace(r):
+		return classSpace, size
+	}
+	return classOther, size
+}
+
+// Лексический анализ; если comments не nil, в него собираются комментарии
+// (директивы препроцессора всегда попадают в список токенов)
+func scan(reader io.Reader, comments *[]Token) ([]Token, error) {
+	return dfa.scan(reader, comments)
+}
+
+// Лексический анализ по таблицам автомата d
+func (d *scannerDFA) scan(reader io.Reader, comments *[]Token) ([]Token, error) {
+	src, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]Token, 0, len(src)/6+1)
+	// Позиция начала текущего токена; столбцы считаются в символах (рунах), начиная с 1
+	lineNum, colNum := 1, 1
+	// Сдвиг позиции на текст src[from:to]
+	advance := func(from, to int) {
+		for i := from; i < to; {
+			c := src[i]
+			if c >= utf8.RuneSelf {
+				_, size := utf8.DecodeRune(src[i:])
+				colNum++
+				i += size
+				continue
+			}
+			if c == '\n' {
+				lineNum++
+				colNum = 1
+			} else {
+				colNum++
+			}
+			i++
+		}
+	}
+
+	for pos := 0; pos < len(src); {
+		// Самая длинная лексема, допускаемая автоматом
+		state, i := uint16(dfaStart), pos
+		end, kind, final := -1, acceptNone, uint16(0)
+		for i < len(src) {
+			class, size := charClass(src, i)
+			next := d.next[state][class]
+			if next == 0 {
+				break
+			}
+			state, i = next, i+size
+			if d.accept[state] != acceptNone {
+				end, kind, final = i, d.accept[state], state
+			}
+		}
+		if end < 0 {
+			return nil, scanError(src, pos, i, d.stuck[state], lineNum, colNum)
+		}
+
+		lexeme := src[pos:end]
+		tok := Token{LineNum: lineNum, ColNum: colNum, Offset: pos}
+		switch kind {
+		case acceptIdentifier:
+			tok.Type, tok.Lexeme = TokenIdentifier, string(lexeme)
+		case acceptKeyword, acceptOperator:
+			tok.Type, tok.Lexeme = TokenKeyword, d.words[final]
+			if kind == acceptOperator {
+				tok.Type = TokenOperator
+			}
+			if tok.Lexeme == "" {
+				tok.Lexeme = string(lexeme)
+			}
+		case acceptDelimiter:
+			tok.Type, tok.Lexeme = TokenDelimiter, string(lexeme)
+		case acceptNumber, acceptBadNumber:
+			tok, err = numberToken(tok, string(lexeme))
+			if err != nil {
+				return nil, err
+			}
+		case acceptNumberRange:
+			// Число и разделитель '..' — два токена
+			tok, err = numberToken(tok, string(lexeme[:len(lexeme)-2]))
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			n := len(lexeme) - 2
+			tok = Token{Type: TokenDelimiter, Lexeme: "..", LineNum: lineNum, ColNum: colNum + utf8.RuneCount(lexeme[:n]), Offset: pos + n}
+		case acceptString, acceptChar:
+			tok, err = stringToken(tok, textLexeme(lexeme), kind == acceptChar)
+			if err != nil {
+				return nil, err
+			}
+		case acceptComment:
+			tok.Type, tok.Lexeme = TokenComment, textLexeme(lexeme)
+		case acceptDirective:
+			tok.Type, tok.Lexeme = TokenDirective, textLexeme(lexeme)
+		}
+		switch {
+		case kind == acceptSpace:
+		case kind == acceptComment:
+			if comments != nil {
+				*comments = append(*comments, tok)
+			}
+		default:
+			tokens = append(tokens, tok)
+		}
+		advance(pos, end)
+		pos = end
+	}
+	return tokens, nil
+}
+
+// Лексема строки или комментария; каждый байт, не образующий символ UTF-8,
+// заменяется на U+FFFD, как при посимвольном чтении
+func textLexeme(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	var sb strings.Builder
+	for _, r := range string(b) {
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Токен числовой константы с вычисленным значением; ошибка указывает
+// на символ, из-за которого запись некорректна
+func numberToken(tok Token, lexeme string) (Token, error) {
+	val, base, err := scanNumber(lexeme)
+	if ne, ok := err.(*numberError); ok {
+		// Символы записи числа, кроме, возможно, последнего, однобайтовые
+		bad := ""
+		if runes := []rune(lexeme); ne.Pos < len(runes) {
+			bad = string(runes[ne.Pos])
+		}
+		errTok := Token{Lexeme: bad, LineNum: tok.LineNum, ColNum: tok.ColNum + ne.Pos, Offset: tok.Offset + ne.Pos}
+		return Token{}, newDiagnostic(StageLexical, errTok, ne.Format, ne.Args...)
+	}
+	tok.Type, tok.Lexeme, tok.Value, tok.Base = TokenNumber, lexeme, val, base
+	return tok, nil
+}
+
+// Токен строковой или символьной константы с вычисленным значением
+func stringToken(tok Token, lexeme string, char bool) (Token, error) {
+	// Escape-последовательности уже проверены автоматом, поэтому ошибки быть не может
+	str, _ := decodeString(lexeme)
+	tok.Type, tok.Lexeme, tok.Value = TokenString, lexeme, Value{Kind: KindString, Str: str}
+	if char {
+		tok.Type, tok.Value.Kind = TokenChar, KindChar
+		if n := utf8.RuneCountInString(str); n != 1 {
+			return Token{}, newDiagnostic(StageLexical, tok, "Символьная константа должна содержать один символ, получено %d", n)
+		}
+	}
+	return tok, nil
+}
+
+// Ошибка при остановке автомата в недопускающем состоянии вида stuck: токен
+// начинается в позиции pos (строка line, столбец col), автомат прочитал src[pos:stop]
+func scanError(src []byte, pos, stop int, stuck stuckKind, line, col int) error {
+	tok := Token{LineNum: line, ColNum: col, Offset: pos}
+	switch stuck {
+	case stuckString:
+		quote := rune(src[pos])
+		// Автомат остановился сразу после обратной косой черты, начинающей escape-последовательность
+		escape := false
+		for i := pos + 1; i < stop; i++ {
+			escape = !escape && src[i] == '\\'
+		}
+		if escape && stop < len(src) && src[stop] != '\n' {
+			// Неизвестная escape-последовательность: позиция обратной косой черты
+			e, _ := utf8.DecodeRune(src[stop:])
+			esc := Token{Lexeme: `\` + string(e), LineNum: line, ColNum: col + utf8.RuneCount(src[pos:stop-1]), Offset: stop - 1}
+			return newDiagnostic(StageLexical, esc, "Неизвестная escape-последовательность '%s'", esc.Lexeme)
+		}
+		tok.Lexeme = string(quote)
+		return newDiagnostic(StageLexical, tok, "Незакрытая константа: ожидалась %c", quote)
+	case stuckComment:
+		tok.Lexeme = "{"
+		return newDiagnostic(StageLexical, tok, "Некорректный комментарий: ожидался '}'")
+	}
+	ch, _ := utf8.DecodeRune(src[pos:])
+	tok.Lexeme = string(ch)
+	return newDiagnostic(StageLexical, tok, "Неизвестный символ '%c'", ch)
+}
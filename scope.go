@@ -0,0 +1,82 @@
+package main
+
+import "sort"
+
+// Области видимости синтаксического анализатора. Глобальная область содержит
+// константы, типы, переменные программы и подпрограммы; у каждой подпрограммы своя область
+// для параметров и локальных переменных, вложенная в глобальную. Глобальная область
+// программы или модуля вложена в область интерфейсов подключённых модулей.
+
+type symbolKind int
+
+const (
+	symVar symbolKind = iota
+	symSubprogram
+	symType
+	symConst
+)
+
+// Объявленное имя
+type symbol struct {
+	Kind  symbolKind
+	Tok   Token     // место объявления
+	Type  *TypeSpec // тип переменной или тип из объявления типа
+	Sub   *Subprogram
+	Const *ConstDecl
+}
+
+type scope struct {
+	parent  *scope
+	symbols map[string]*symbol
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, symbols: make(map[string]*symbol)}
+}
+
+// Поиск имени в области и объемлющих областях
+func (s *scope) lookup(name string) *symbol {
+	for ; s != nil; s = s.parent {
+		if sym, ok := s.symbols[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// Имена видимых объявлений заданного вида в алфавитном порядке
+func (s *scope) names(kind symbolKind) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for ; s != nil; s = s.parent {
+		for name, sym := range s.symbols {
+			if sym.Kind == kind && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *Syntax) lookup(name string) *symbol {
+	if p.scope == nil {
+		return nil
+	}
+	return p.scope.lookup(name)
+}
+
+// Объявление имени в текущей области; повторное объявление в той же области — ошибка
+func (p *Syntax) declare(sym *symbol, format string) error {
+	if p.scope == nil {
+		p.scope = newScope(nil)
+	}
+	name := sym.Tok.Lexeme
+	if prev, ok := p.scope.symbols[name]; ok {
+		err := p.errorAt(sym.Tok, format, name)
+		return withLabel(err, prev.Tok, tr("первое объявление"))
+	}
+	p.scope.symbols[name] = sym
+	return nil
+}
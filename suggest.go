@@ -0,0 +1,94 @@
+package main
+
+import "strings"
+
+// Подсказки "возможно, имелось в виду" для опечаток в ключевых словах,
+// операциях и именах переменных
+
+// Расстояние редактирования между строками (вставка, удаление, замена
+// и перестановка соседних символов), без учёта регистра
+func editDistance(a, b string) int {
+	s, t := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+// Ближайший к слову кандидат; допускается одна правка на каждые 3 символа
+// (для однобуквенных слов подсказок нет). При равных расстояниях выбирается
+// первый кандидат.
+func suggest(word string, candidates []string) (string, bool) {
+	limit := (len([]rune(word)) + 1) / 3
+	best, bestDist := "", limit+1
+	for _, c := range candidates {
+		if c == word {
+			continue
+		}
+		dist := editDistance(word, c)
+		if dist < bestDist {
+			best, bestDist = c, dist
+		}
+	}
+	return best, best != ""
+}
+
+// Добавление к диагностике подсказки и исправления, заменяющего токен
+// на ближайшего кандидата; если подходящего кандидата нет, ошибка не меняется
+func withSuggestion(err error, tok Token, candidates []string) error {
+	return suggestFix(err, tok, candidates, false)
+}
+
+// То же для имён переменных, полей и подпрограмм: замена на другое объявленное
+// имя только предлагается
+func withNameSuggestion(err error, tok Token, candidates []string) error {
+	return suggestFix(err, tok, candidates, true)
+}
+
+func suggestFix(err error, tok Token, candidates []string, manual bool) error {
+	d, ok := err.(*Diagnostic)
+	if !ok || tok.Lexeme == "" {
+		return err
+	}
+	s, ok := suggest(tok.Lexeme, candidates)
+	if !ok {
+		return err
+	}
+	d.Help = sprintf("возможно, имелось в виду '%s'", s)
+	return withFix(err, tok, Fix{
+		Message: sprintf("заменить '%s' на '%s'", tok.Lexeme, s),
+		Edits:   []TextEdit{{Offset: tok.Offset, Length: len(tok.Lexeme), NewText: s}},
+		Manual:  manual,
+	})
+}
+
+// Имена видимых переменных в алфавитном порядке
+func (p *Syntax) declaredNames() []string {
+	if p.scope == nil {
+		return nil
+	}
+	return p.scope.names(symVar)
+}
+
+// Ключевые слова, с которых начинается оператор, и 'end', завершающее список операторов
+var statementKeywords = []string{"if", "for", "while", "repeat", "case", "read", "write", "break", "continue", "end"}
+
+// Ключевые слова, с которых начинается запись типа
+var typeKeywords = []string{"int", "float", "bool", "string", "char", "array", "record"}
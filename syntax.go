@@ -1,21 +1,62 @@
 package main
 
 import (
-	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // Структура парсера
 type Syntax struct {
 	tokens []Token
 	pos    int
-	vars   map[string]bool
+	scope  *scope      // текущая область видимости
+	sub    *Subprogram // разбираемая подпрограмма (nil в теле программы)
+	// Границы раздела объявлений (для исправления "объявить переменную")
+	varTok, declStart, declEnd Token
+	Warnings                   []*Diagnostic // предупреждения, найденные при разборе
+	loops                      int           // глубина вложенности циклов (для break и continue)
+	loopVars                   []loopVar     // переменные объемлющих циклов for
+	// Имя разбираемого файла: подключаемые модули ищутся прежде всего в его каталоге
+	file     string
+	unit     *Unit                  // разбираемый модуль (nil при разборе программы)
+	headings map[string]*Subprogram // подпрограммы интерфейса модуля, ещё не реализованные
+}
+
+// Синтаксическая ошибка в позиции токена
+func (p *Syntax) errorAt(tok Token, format string, args ...any) error {
+	return newDiagnostic(StageSyntax, tok, format, args...)
+}
+
+// Предупреждение в позиции токена; разбор продолжается
+func (p *Syntax) warnAt(tok Token, format string, args ...any) *Diagnostic {
+	d := newDiagnostic(StageSyntax, tok, format, args...)
+	d.Warning = true
+	p.Warnings = append(p.Warnings, d)
+	return d
 }
 
 func (p *Syntax) currentToken() Token {
 	if p.pos < len(p.tokens) {
 		return p.tokens[p.pos]
 	}
-	return Token{Type: TokenEOF, Lexeme: "", LineNum: 0, ColNum: 0}
+	// Конец текста: позиция сразу после последнего токена
+	if len(p.tokens) > 0 {
+		last := p.tokens[len(p.tokens)-1]
+		return Token{Type: TokenEOF, Lexeme: "", LineNum: last.LineNum,
+			ColNum: last.ColNum + utf8.RuneCountInString(last.Lexeme), Offset: last.Offset + len(last.Lexeme), File: last.File}
+	}
+	return Token{Type: TokenEOF, Lexeme: "", LineNum: 1, ColNum: 1}
+}
+
+// Токен, следующий за текущим
+func (p *Syntax) peekToken() Token {
+	p.pos++
+	token := p.currentToken()
+	p.pos--
+	return token
 }
 
 func (p *Syntax) nextToken() {
@@ -30,364 +71,1687 @@ func (p *Syntax) matchToken(expectedType TokenType, expectedLexeme string) error
 		p.nextToken()
 		return nil
 	}
-	return fmt.Errorf("Ожидалось %s '%s', получено %s '%s' на строке %d столбце %d",
+	err := p.errorAt(token, "Ожидалось %s '%s', получено %s '%s'",
 		TokenTypeToString(expectedType), expectedLexeme,
-		TokenTypeToString(token.Type), token.Lexeme,
-		token.LineNum, token.ColNum)
+		TokenTypeToString(token.Type), token.Lexeme)
+	if expectedType == TokenKeyword {
+		err = p.suggestWord(err, token, expectedLexeme)
+	} else {
+		err = p.suggestWord(err, token)
+	}
+	if hasFixes(err) {
+		return err
+	}
+	switch {
+	case expectedLexeme == "as" && token.Type == TokenDelimiter && token.Lexeme == "=":
+		// '=' вместо 'as'
+		return replaceFix(err, token, "as")
+	case expectedLexeme == "then" || expectedLexeme == "do":
+		return p.insertFix(err, " "+expectedLexeme)
+	case expectedLexeme == "." || expectedLexeme == ";":
+		return p.insertFix(err, expectedLexeme)
+	}
+	return err
+}
+
+// Подсказка для слова, стоящего на месте ожидаемых ключевых слов:
+// кандидатами служат ожидаемые слова, а для идентификаторов также знаки операций
+func (p *Syntax) suggestWord(err error, token Token, expected ...string) error {
+	if token.Type != TokenIdentifier && token.Type != TokenKeyword {
+		return err
+	}
+	candidates := expected
+	if token.Type == TokenIdentifier {
+		candidates = append(candidates[:len(candidates):len(candidates)], operators...)
+	}
+	return withSuggestion(err, token, candidates)
 }
 
 // Функция синтаксического анализа
-func (p *Syntax) ParseProgram() error {
+func (p *Syntax) ParseProgram() (*Program, error) {
+	prog := &Program{Tok: p.currentToken()}
+	p.scope = newScope(nil)
+
 	// program
 	err := p.matchToken(TokenKeyword, "program")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// uses (необязательный список модулей); имена из интерфейсов модулей
+	// видны во всей программе, но объявления программы их скрывают
+	token := p.currentToken()
+	if token.Type == TokenKeyword && token.Lexeme == "uses" {
+		var deps []*Unit
+		prog.Uses, deps, err = p.parseUses()
+		if err != nil {
+			return nil, err
+		}
+		prog.Units = unitOrder(deps)
+		p.scope = newScope(importScope(deps))
+		token = p.currentToken()
+	}
+
+	// const (необязательный раздел констант)
+	if token.Type == TokenKeyword && token.Lexeme == "const" {
+		prog.Consts, err = p.parseConstSection("type", "var")
+		if err != nil {
+			return nil, err
+		}
+		token = p.currentToken()
+	}
+
+	// type (необязательный раздел объявлений типов)
+	if token.Type == TokenKeyword && token.Lexeme == "type" {
+		prog.Types, err = p.parseTypeSection("var")
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// var
+	p.varTok = p.currentToken()
 	err = p.matchToken(TokenKeyword, "var")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// parse declarations
+	for {
+		decl, err := p.parseDeclaration()
+		if err != nil {
+			return nil, err
+		}
+		prog.Decls = append(prog.Decls, decl)
+		// Проверяем, есть ли еще объявления
+		token := p.currentToken()
+		if token.Type == TokenKeyword && (token.Lexeme == "begin" || token.Lexeme == "procedure" || token.Lexeme == "function") {
+			break
+		}
+		// Опечатка в 'begin' иначе была бы принята за начало объявления
+		next := p.peekToken()
+		if _, ok := suggest(token.Lexeme, []string{"begin"}); ok && token.Type == TokenIdentifier &&
+			!(next.Type == TokenDelimiter && (next.Lexeme == "," || next.Lexeme == ":")) {
+			return nil, p.matchToken(TokenKeyword, "begin")
+		}
+	}
+
+	// Процедуры и функции
+	for {
+		token := p.currentToken()
+		if token.Type != TokenKeyword || (token.Lexeme != "procedure" && token.Lexeme != "function") {
+			break
+		}
+		sub, err := p.parseSubprogram()
+		if err != nil {
+			return nil, err
+		}
+		prog.Subprograms = append(prog.Subprograms, sub)
+	}
+
+	// begin
+	begin := p.currentToken()
+	err = p.matchToken(TokenKeyword, "begin")
+	if err != nil {
+		return nil, err
+	}
+
+	// parse operations
+	prog.Body, err = p.parseOperations()
+	if err != nil {
+		return nil, err
+	}
+
+	// end
+	prog.End = p.currentToken()
+	err = p.matchToken(TokenKeyword, "end")
+	if err != nil {
+		return nil, withLabel(err, begin, tr("тело программы начато здесь"))
+	}
+
+	// '.'
+	err = p.matchToken(TokenDelimiter, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	return prog, nil
+}
+
+// Разбор модуля
+func (p *Syntax) ParseUnit() (*Unit, error) {
+	unit := &Unit{Tok: p.currentToken()}
+	p.scope = newScope(nil)
+	p.unit = unit
+	p.headings = make(map[string]*Subprogram)
+
+	// unit <имя> ;
+	err := p.matchToken(TokenKeyword, "unit")
+	if err != nil {
+		return nil, err
+	}
+	name := p.currentToken()
+	if name.Type != TokenIdentifier {
+		return nil, p.errorAt(name, "Ожидалось имя модуля, получено %s '%s'",
+			TokenTypeToString(name.Type), name.Lexeme)
+	}
+	if p.file != "" && p.file != "-" {
+		base := strings.TrimSuffix(filepath.Base(p.file), filepath.Ext(p.file))
+		if base != name.Lexeme {
+			err := p.errorAt(name, "Имя модуля '%s' не совпадает с именем файла '%s'", name.Lexeme, filepath.Base(p.file))
+			return nil, replaceFix(err, name, base)
+		}
+	}
+	unit.Name = name
+	p.nextToken()
+	err = p.matchToken(TokenDelimiter, ";")
+	if err != nil {
+		return nil, err
+	}
+
+	// uses
+	token := p.currentToken()
+	if token.Type == TokenKeyword && token.Lexeme == "uses" {
+		unit.Uses, unit.Deps, err = p.parseUses()
+		if err != nil {
+			return nil, err
+		}
+		p.scope = newScope(importScope(unit.Deps))
+	}
+
+	// interface: константы, типы и заголовки подпрограмм
+	err = p.matchToken(TokenKeyword, "interface")
+	if err != nil {
+		return nil, err
+	}
+	token = p.currentToken()
+	if token.Type == TokenKeyword && token.Lexeme == "const" {
+		unit.Consts, err = p.parseConstSection("type", "procedure", "function", "implementation")
+		if err != nil {
+			return nil, err
+		}
+		token = p.currentToken()
+	}
+	if token.Type == TokenKeyword && token.Lexeme == "type" {
+		unit.Types, err = p.parseTypeSection("procedure", "function", "implementation")
+		if err != nil {
+			return nil, err
+		}
+	}
+	for {
+		token := p.currentToken()
+		if token.Type != TokenKeyword || (token.Lexeme != "procedure" && token.Lexeme != "function") {
+			break
+		}
+		sub, err := p.parseInterfaceHeading()
+		if err != nil {
+			return nil, err
+		}
+		unit.Headings = append(unit.Headings, sub)
+	}
+
+	// implementation: переменные и подпрограммы модуля
+	unit.Impl = p.currentToken()
+	err = p.matchToken(TokenKeyword, "implementation")
+	if err != nil {
+		return nil, err
+	}
+	token = p.currentToken()
+	if token.Type == TokenKeyword && token.Lexeme == "var" {
+		p.varTok, p.declStart = token, Token{}
+		p.nextToken()
+		for {
+			decl, err := p.parseDeclaration()
+			if err != nil {
+				return nil, err
+			}
+			unit.Decls = append(unit.Decls, decl)
+			token = p.currentToken()
+			if token.Type == TokenKeyword && (token.Lexeme == "procedure" || token.Lexeme == "function" || token.Lexeme == "end") {
+				break
+			}
+		}
+	}
+	for {
+		token := p.currentToken()
+		if token.Type != TokenKeyword || (token.Lexeme != "procedure" && token.Lexeme != "function") {
+			break
+		}
+		sub, err := p.parseSubprogram()
+		if err != nil {
+			return nil, err
+		}
+		unit.Subprograms = append(unit.Subprograms, sub)
+	}
+
+	// end .
+	unit.End = p.currentToken()
+	err = p.matchToken(TokenKeyword, "end")
+	if err != nil {
+		return nil, withLabel(err, unit.Impl, tr("раздел implementation начат здесь"))
+	}
+	for _, sub := range unit.Headings {
+		if p.headings[sub.Name.Lexeme] == sub {
+			err := p.errorAt(sub.Name, "Подпрограмма '%s' объявлена в интерфейсе, но не реализована", sub.Name.Lexeme)
+			return nil, withLabel(err, unit.End, tr("конец модуля"))
+		}
+	}
+	err = p.matchToken(TokenDelimiter, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	return unit, nil
+}
+
+// Парсинг списка модулей: uses <имя> { , <имя> } ;
+// Модули загружаются сразу, чтобы их интерфейсы были видны при дальнейшем разборе
+func (p *Syntax) parseUses() ([]Token, []*Unit, error) {
+	p.nextToken()
+	var names []Token
+	var units []*Unit
+	for {
+		name := p.currentToken()
+		if name.Type != TokenIdentifier {
+			return nil, nil, p.errorAt(name, "Ожидалось имя модуля, получено %s '%s'",
+				TokenTypeToString(name.Type), name.Lexeme)
+		}
+		for _, prev := range names {
+			if prev.Lexeme == name.Lexeme {
+				err := p.errorAt(name, "Модуль '%s' уже подключён", name.Lexeme)
+				return nil, nil, withLabel(err, prev, tr("первое подключение"))
+			}
+		}
+		unit, err := loader.load(p.file, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, name)
+		units = append(units, unit)
+		p.nextToken()
+
+		token := p.currentToken()
+		if token.Type != TokenDelimiter || token.Lexeme != "," {
+			break
+		}
+		p.nextToken()
+	}
+	err := p.matchToken(TokenDelimiter, ";")
+	if err != nil {
+		return nil, nil, err
+	}
+	return names, units, nil
+}
+
+// Область видимости с интерфейсами подключённых модулей; при совпадении имён
+// действует объявление модуля, подключённого позже
+func importScope(units []*Unit) *scope {
+	s := newScope(nil)
+	for _, unit := range units {
+		for _, decl := range unit.Consts {
+			s.symbols[decl.Name.Lexeme] = &symbol{Kind: symConst, Tok: decl.Name, Const: decl}
+		}
+		for _, decl := range unit.Types {
+			s.symbols[decl.Name.Lexeme] = &symbol{Kind: symType, Tok: decl.Name, Type: decl.Type}
+		}
+		for _, sub := range unit.Headings {
+			s.symbols[sub.Name.Lexeme] = &symbol{Kind: symSubprogram, Tok: sub.Name, Sub: sub}
+		}
+	}
+	return s
+}
+
+// Раздел констант после 'const'; заканчивается перед одним из ключевых слов stop
+func (p *Syntax) parseConstSection(stop ...string) ([]*ConstDecl, error) {
+	p.nextToken()
+	var decls []*ConstDecl
+	for {
+		decl, err := p.parseConstDecl()
+		if err != nil {
+			return nil, err
+		}
+		decls = append(decls, decl)
+		token := p.currentToken()
+		if token.Type == TokenKeyword && contains(stop, token.Lexeme) {
+			return decls, nil
+		}
+	}
+}
+
+// Раздел объявлений типов после 'type'; заканчивается перед одним из ключевых слов stop
+func (p *Syntax) parseTypeSection(stop ...string) ([]*TypeDecl, error) {
+	p.nextToken()
+	var decls []*TypeDecl
+	for {
+		decl, err := p.parseTypeDecl()
+		if err != nil {
+			return nil, err
+		}
+		decls = append(decls, decl)
+		token := p.currentToken()
+		if token.Type == TokenKeyword && contains(stop, token.Lexeme) {
+			return decls, nil
+		}
+	}
+}
+
+// Парсинг объявления переменных
+func (p *Syntax) parseDeclaration() (*VarDecl, error) {
+	decl, err := p.parseVarList("Переменная '%s' уже объявлена")
+	if err != nil {
+		return nil, err
+	}
+
+	// ';'
+	token := p.currentToken()
+	if token.Type != TokenDelimiter || token.Lexeme != ";" {
+		err := p.errorAt(token, "Ожидалось ';', получено %s '%s'",
+			TokenTypeToString(token.Type), token.Lexeme)
+		return nil, p.insertFix(err, ";")
+	}
+	if p.declStart.Lexeme == "" {
+		p.declStart = decl.Names[0]
+	}
+	p.declEnd = token
+	p.nextToken()
+
+	return decl, nil
+}
+
+// Парсинг списка переменных с типом: <идентификатор> { , <идентификатор> } : <тип>
+// (объявление без ';', группа параметров подпрограммы или полей записи);
+// format — сообщение о повторном объявлении
+func (p *Syntax) parseVarList(format string) (*VarDecl, error) {
+	decl := &VarDecl{}
+	var syms []*symbol
+	for {
+		token := p.currentToken()
+		if token.Type != TokenIdentifier {
+			return nil, p.errorAt(token, "Ожидался идентификатор, получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+		}
+		sym := &symbol{Kind: symVar, Tok: token}
+		err := p.declare(sym, format)
+		if err != nil {
+			return nil, err
+		}
+		syms = append(syms, sym)
+		decl.Names = append(decl.Names, token)
+		p.nextToken()
+
+		token = p.currentToken()
+		if token.Type == TokenDelimiter && token.Lexeme == "," {
+			p.nextToken()
+			continue
+		} else if token.Type == TokenDelimiter && token.Lexeme == ":" {
+			p.nextToken()
+			break
+		} else {
+			return nil, p.errorAt(token, "Ожидалось ',' или ':', получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+		}
+	}
+
+	// Тип
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	decl.Type = typ
+	for _, sym := range syms {
+		sym.Type = typ
+	}
+
+	return decl, nil
+}
+
+// Парсинг объявления константы: <идентификатор> = <константное выражение> ;
+func (p *Syntax) parseConstDecl() (*ConstDecl, error) {
+	name := p.currentToken()
+	if name.Type != TokenIdentifier {
+		return nil, p.errorAt(name, "Ожидался идентификатор, получено %s '%s'",
+			TokenTypeToString(name.Type), name.Lexeme)
+	}
+	p.nextToken()
+
+	err := p.matchToken(TokenDelimiter, "=")
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	err = p.checkConst(value)
+	if err != nil {
+		return nil, err
+	}
+	// Имя объявляется после разбора значения, поэтому константа не может ссылаться сама на себя
+	decl := &ConstDecl{Name: name, Value: value}
+	err = p.declare(&symbol{Kind: symConst, Tok: name, Const: decl}, "Имя '%s' уже объявлено")
+	if err != nil {
+		return nil, err
+	}
+	err = p.matchToken(TokenDelimiter, ";")
+	if err != nil {
+		return nil, err
+	}
+
+	return decl, nil
+}
+
+// Проверка, что выражение вычисляется при компиляции: переменные, вызовы
+// Ошибка для числовой константы, значение которой вне допустимого диапазона
+func (p *Syntax) numberError(tok Token) error {
+	if tok.Value.Kind == KindFloat {
+		return p.errorAt(tok, "Вещественное число '%s' вне допустимого диапазона", tok.Lexeme)
+	}
+	d := newDiagnostic(StageSyntax, tok, "Целое число '%s' не помещается в 64 бита", tok.Lexeme)
+	d.Help = tr("для целых чисел произвольной длины используйте флаг -bigint")
+	return d
+}
+
+// и ошибки вычисления (деление на ноль, несовместимые операнды) — ошибки разбора
+func (p *Syntax) checkConst(e Expr) error {
+	_, err := evalConst(e)
+	if rt, ok := err.(*RuntimeError); ok {
+		return p.errorAt(rt.Tok, "Некорректное константное выражение: %s", rt.Msg)
+	}
+	return err
+}
+
+// Парсинг объявления типа: <идентификатор> = <тип> ;
+func (p *Syntax) parseTypeDecl() (*TypeDecl, error) {
+	name := p.currentToken()
+	if name.Type != TokenIdentifier {
+		return nil, p.errorAt(name, "Ожидался идентификатор, получено %s '%s'",
+			TokenTypeToString(name.Type), name.Lexeme)
+	}
+	p.nextToken()
+
+	err := p.matchToken(TokenDelimiter, "=")
+	if err != nil {
+		return nil, err
+	}
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	// Имя объявляется после разбора типа, поэтому тип не может ссылаться сам на себя
+	err = p.declare(&symbol{Kind: symType, Tok: name, Type: typ}, "Имя '%s' уже объявлено")
+	if err != nil {
+		return nil, err
+	}
+	err = p.matchToken(TokenDelimiter, ";")
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypeDecl{Name: name, Type: typ}, nil
+}
+
+// Парсинг типа: int | float | bool | <имя типа> | array ... | record ...
+func (p *Syntax) parseType() (*TypeSpec, error) {
+	token := p.currentToken()
+	switch {
+	case token.Type == TokenKeyword && token.Lexeme == "array":
+		return p.parseArrayType()
+	case token.Type == TokenKeyword && token.Lexeme == "record":
+		return p.parseRecordType()
+	case token.Type == TokenKeyword && isScalarType(token.Lexeme):
+		p.nextToken()
+		return &TypeSpec{Tok: token}, nil
+	case token.Type == TokenIdentifier:
+		sym := p.lookup(token.Lexeme)
+		if sym == nil {
+			err := p.errorAt(token, "Неизвестный тип '%s'", token.Lexeme)
+			return nil, withSuggestion(err, token, append(p.scope.names(symType), typeKeywords...))
+		}
+		if sym.Kind != symType {
+			err := p.errorAt(token, "'%s' не является типом", token.Lexeme)
+			return nil, withLabel(err, sym.Tok, tr("объявлено здесь"))
+		}
+		p.nextToken()
+		return &TypeSpec{Tok: token, Def: sym.Type}, nil
+	}
+	err := p.errorAt(token, "Ожидался тип, получено %s '%s'",
+		TokenTypeToString(token.Type), token.Lexeme)
+	return nil, p.suggestWord(err, token, typeKeywords...)
+}
+
+// Парсинг типа записи: record <поля> { ; <поля> } [ ; ] end
+// Встроенные простые типы
+func isScalarType(name string) bool {
+	switch name {
+	case "int", "float", "bool", "string", "char":
+		return true
+	}
+	return false
+}
+
+func (p *Syntax) parseRecordType() (*TypeSpec, error) {
+	typ := &TypeSpec{Tok: p.currentToken()}
+	p.nextToken()
+
+	// Поля объявляются во вложенной области видимости, чтобы найти повторы;
+	// после разбора записи область отбрасывается
+	outer := p.scope
+	p.scope = newScope(outer)
+	defer func() { p.scope = outer }()
+
+	for {
+		field, err := p.parseVarList("Поле '%s' уже объявлено")
+		if err != nil {
+			return nil, err
+		}
+		typ.Fields = append(typ.Fields, field)
+
+		token := p.currentToken()
+		separated := token.Type == TokenDelimiter && token.Lexeme == ";"
+		if separated {
+			p.nextToken()
+			token = p.currentToken()
+		}
+		if token.Type == TokenKeyword && token.Lexeme == "end" {
+			typ.End = token
+			p.nextToken()
+			return typ, nil
+		}
+		if !separated {
+			err := p.errorAt(token, "Ожидалось ';' или 'end', получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+			err = p.suggestWord(err, token, "end")
+			return nil, withLabel(err, typ.Tok, tr("запись начата здесь"))
+		}
+	}
+}
+
+// Парсинг типа массива: array [ <граница> .. <граница> ] of <тип>
+func (p *Syntax) parseArrayType() (*TypeSpec, error) {
+	typ := &TypeSpec{Tok: p.currentToken()}
+	p.nextToken()
+
+	open := p.currentToken()
+	err := p.matchToken(TokenDelimiter, "[")
+	if err != nil {
+		return nil, err
+	}
+	typ.Low, err = p.parseBound()
+	if err != nil {
+		return nil, err
+	}
+	err = p.matchToken(TokenDelimiter, "..")
+	if err != nil {
+		return nil, err
+	}
+	typ.High, err = p.parseBound()
+	if err != nil {
+		return nil, err
+	}
+	err = p.matchToken(TokenDelimiter, "]")
+	if err != nil {
+		return nil, withLabel(err, open, tr("открывающая квадратная скобка"))
+	}
+	low, high, _ := arrayBounds(typ)
+	if low > high {
+		return nil, p.errorAt(typ.Low.Pos(), "Нижняя граница массива %d больше верхней %d", low, high)
+	}
+	if _, ok := arrayLen(low, high); !ok {
+		return nil, p.errorAt(typ.Low.Pos(), "Массив из %s элементов слишком велик (не более %d)", arrayLenText(low, high), maxArrayLen)
+	}
+
+	err = p.matchToken(TokenKeyword, "of")
+	if err != nil {
+		return nil, err
+	}
+	typ.Elem, err = p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+// Граница индекса массива — целое константное выражение
+func (p *Syntax) parseBound() (Expr, error) {
+	token := p.currentToken()
+	bound, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	err = p.checkConst(bound)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := constInt(bound); !ok {
+		return nil, p.errorAt(token, "Ожидалась целая константа в границе массива, получено %s '%s'",
+			TokenTypeToString(token.Type), exprString(bound))
+	}
+	return bound, nil
+}
+
+// Парсинг процедуры или функции
+func (p *Syntax) parseSubprogram() (*Subprogram, error) {
+	sub := &Subprogram{Tok: p.currentToken(), Unit: p.unit}
+	p.nextToken()
+
+	name := p.currentToken()
+	if name.Type != TokenIdentifier {
+		return nil, p.errorAt(name, "Ожидалось имя подпрограммы, получено %s '%s'",
+			TokenTypeToString(name.Type), name.Lexeme)
+	}
+	// Реализация подпрограммы из интерфейса модуля: имя уже объявлено заголовком
+	heading := p.headings[name.Lexeme]
+	if heading == nil {
+		// Имя объявляется до разбора тела, чтобы подпрограмма могла вызывать саму себя
+		err := p.declare(&symbol{Kind: symSubprogram, Tok: name, Sub: sub}, "Имя '%s' уже объявлено")
+		if err != nil {
+			return nil, err
+		}
+	}
+	sub.Name = name
+	p.nextToken()
+
+	// Параметры и локальные переменные объявляются в собственной области видимости
+	global := p.scope
+	p.scope = newScope(global)
+	varTok, declStart, declEnd := p.varTok, p.declStart, p.declEnd
+	defer func() {
+		p.scope, p.sub = global, nil
+		p.varTok, p.declStart, p.declEnd = varTok, declStart, declEnd
+	}()
+
+	err := p.parseHeading(sub)
+	if err != nil {
+		return nil, err
+	}
+	if heading != nil {
+		if !sameHeading(heading, sub) {
+			err := p.errorAt(name, "Заголовок подпрограммы '%s' не совпадает с объявлением в интерфейсе", name.Lexeme)
+			d := asDiagnostic(withLabel(err, heading.Name, tr("объявлена в интерфейсе")))
+			d.Help = sprintf("ожидалось '%s'", subprogramHeader(heading))
+			return nil, d
+		}
+		delete(p.headings, name.Lexeme)
+	}
+
+	// Локальные переменные
+	token := p.currentToken()
+	if token.Type == TokenKeyword && token.Lexeme == "var" {
+		p.varTok, p.declStart = token, Token{}
+		p.nextToken()
+		for {
+			decl, err := p.parseDeclaration()
+			if err != nil {
+				return nil, err
+			}
+			sub.Decls = append(sub.Decls, decl)
+			token = p.currentToken()
+			if token.Type == TokenKeyword && token.Lexeme == "begin" {
+				break
+			}
+		}
+	}
+
+	// begin <операторы> end ;
+	begin := p.currentToken()
+	err = p.matchToken(TokenKeyword, "begin")
+	if err != nil {
+		return nil, err
+	}
+	p.sub = sub
+	sub.Body, err = p.parseOperations()
+	if err != nil {
+		return nil, err
+	}
+	sub.End = p.currentToken()
+	err = p.matchToken(TokenKeyword, "end")
+	if err != nil {
+		return nil, withLabel(err, begin, tr("тело подпрограммы начато здесь"))
+	}
+	err = p.matchToken(TokenDelimiter, ";")
+	if err != nil {
+		return nil, err
+	}
+
+	if heading != nil {
+		// Вызовы ссылаются на заголовок из интерфейса
+		heading.Impl = sub
+	}
+	return sub, nil
+}
+
+// Парсинг параметров и типа результата подпрограммы в текущей области видимости:
+// ( [ <параметры> { ; <параметры> } ] ) [ : <тип> ] ;
+func (p *Syntax) parseHeading(sub *Subprogram) error {
+	err := p.matchToken(TokenDelimiter, "(")
+	if err != nil {
+		return err
+	}
+	token := p.currentToken()
+	if token.Type == TokenDelimiter && token.Lexeme == ")" {
+		p.nextToken()
+	} else {
+		for {
+			param, err := p.parseVarList("Переменная '%s' уже объявлена")
+			if err != nil {
+				return err
+			}
+			sub.Params = append(sub.Params, param)
+			token = p.currentToken()
+			if token.Type == TokenDelimiter && token.Lexeme == ";" {
+				p.nextToken()
+				continue
+			} else if token.Type == TokenDelimiter && token.Lexeme == ")" {
+				p.nextToken()
+				break
+			} else {
+				return p.errorAt(token, "Ожидалось ';' или ')', получено %s '%s'",
+					TokenTypeToString(token.Type), token.Lexeme)
+			}
+		}
+	}
+
+	// : <тип> (только для функции)
+	if sub.IsFunction() {
+		err = p.matchToken(TokenDelimiter, ":")
+		if err != nil {
+			return err
+		}
+		sub.Result, err = p.parseType()
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.matchToken(TokenDelimiter, ";")
+}
+
+// Парсинг заголовка подпрограммы в интерфейсе модуля:
+// procedure <имя> ( [ <параметры> ] ) ; | function <имя> ( [ <параметры> ] ) : <тип> ;
+func (p *Syntax) parseInterfaceHeading() (*Subprogram, error) {
+	sub := &Subprogram{Tok: p.currentToken(), Unit: p.unit}
+	p.nextToken()
+
+	name := p.currentToken()
+	if name.Type != TokenIdentifier {
+		return nil, p.errorAt(name, "Ожидалось имя подпрограммы, получено %s '%s'",
+			TokenTypeToString(name.Type), name.Lexeme)
+	}
+	err := p.declare(&symbol{Kind: symSubprogram, Tok: name, Sub: sub}, "Имя '%s' уже объявлено")
+	if err != nil {
+		return nil, err
+	}
+	sub.Name = name
+	p.nextToken()
+
+	outer := p.scope
+	p.scope = newScope(outer)
+	defer func() { p.scope = outer }()
+	err = p.parseHeading(sub)
+	if err != nil {
+		return nil, err
+	}
+	p.headings[name.Lexeme] = sub
+	return sub, nil
+}
+
+// Заголовки совпадают: тот же вид подпрограммы, те же имена и типы параметров и тип результата
+func sameHeading(a, b *Subprogram) bool {
+	if a.Tok.Lexeme != b.Tok.Lexeme {
+		return false
+	}
+	an, bn := a.ParamNames(), b.ParamNames()
+	if len(an) != len(bn) {
+		return false
+	}
+	for i := range an {
+		if an[i].Lexeme != bn[i].Lexeme || typeString(a.ParamType(i)) != typeString(b.ParamType(i)) {
+			return false
+		}
+	}
+	return !a.IsFunction() || typeString(a.Result) == typeString(b.Result)
+}
+
+// Парсинг списка операций
+func (p *Syntax) parseOperations() ([]Stmt, error) {
+	var stmts []Stmt
+	for {
+		token := p.currentToken()
+		if token.Type == TokenKeyword && token.Lexeme == "end" {
+			// Если мы встретили 'end', выходим из цикла операций
+			break
+		}
+
+		stmt, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+
+		token = p.currentToken()
+		if token.Type == TokenDelimiter && token.Lexeme == ";" {
+			p.nextToken()
+			continue
+		} else if token.Type == TokenKeyword && token.Lexeme == "end" {
+			// Если после операции нет ';', но есть 'end', завершаем парсинг операций
+			break
+		} else {
+			err := p.errorAt(token, "Ожидалось ';' или 'end', получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+			err = p.suggestWord(err, token, "end")
+			if hasFixes(err) {
+				return nil, err
+			}
+			return nil, p.insertFix(err, ";")
+		}
+	}
+	return stmts, nil
+}
+
+// Парсинг одной операции
+func (p *Syntax) parseOperation() (Stmt, error) {
+	token := p.currentToken()
+	if token.Type == TokenKeyword {
+		switch token.Lexeme {
+		case "if":
+			return p.parseIf()
+		case "for":
+			return p.parseFor()
+		case "while":
+			return p.parseWhile()
+		case "repeat":
+			return p.parseRepeat()
+		case "case":
+			return p.parseCase()
+		case "break", "continue":
+			return p.parseLoopJump()
+		case "read":
+			return p.Syntaxead()
+		case "write":
+			return p.parseWrite()
+		case "begin":
+			return p.parseCompositeOperation()
+		case "return":
+			return p.parseReturn()
+		default:
+			return nil, p.errorAt(token, "Неизвестный оператор '%s'", token.Lexeme)
+		}
+	} else if token.Type == TokenIdentifier {
+		next := p.peekToken()
+		if next.Type == TokenDelimiter && next.Lexeme == "(" {
+			// Вызов процедуры
+			call, err := p.parseCall()
+			if err != nil {
+				return nil, err
+			}
+			return &CallStmt{Call: call}, nil
+		}
+		// Необъявленное имя, за которым не следует 'as', — скорее всего опечатка в ключевом слове
+		if p.lookup(token.Lexeme) == nil && !(next.Type == TokenKeyword && next.Lexeme == "as") {
+			if _, ok := suggest(token.Lexeme, statementKeywords); ok {
+				err := p.errorAt(token, "Неизвестный оператор '%s'", token.Lexeme)
+				return nil, withSuggestion(err, token, statementKeywords)
+			}
+		}
+		// Присваивание
+		return p.parseAssignment()
+	} else if token.Type == TokenDelimiter && token.Lexeme == "[" {
+		// Составной оператор
+		return p.parseCompositeOperation()
+	} else {
+		return nil, p.errorAt(token, "Ожидался оператор, получено %s '%s'",
+			TokenTypeToString(token.Type), token.Lexeme)
+	}
+}
+
+// Парсинг составного оператора
+func (p *Syntax) parseCompositeOperation() (Stmt, error) {
+	// '[' [ var <объявление> { <объявление> } ] <оператор> { (: | ';') <оператор> } ']'
+	block := &CompoundStmt{Tok: p.currentToken()}
+	err := p.matchToken(TokenDelimiter, "[")
+	if err != nil {
+		return nil, err
+	}
+
+	// Блок образует собственную область видимости
+	outer := p.scope
+	p.scope = newScope(outer)
+	varTok, declStart, declEnd := p.varTok, p.declStart, p.declEnd
+	defer func() {
+		p.scope = outer
+		p.varTok, p.declStart, p.declEnd = varTok, declStart, declEnd
+	}()
+
+	token := p.currentToken()
+	if token.Type == TokenKeyword && token.Lexeme == "var" {
+		p.varTok, p.declStart = token, Token{}
+		p.nextToken()
+		for {
+			decl, err := p.parseDeclaration()
+			if err != nil {
+				return nil, err
+			}
+			err = p.checkShadowing(decl.Names)
+			if err != nil {
+				return nil, err
+			}
+			block.Decls = append(block.Decls, decl)
+			// Объявление начинается с имени, за которым следует ',' или ':'
+			// (оператор не может начинаться так)
+			token, next := p.currentToken(), p.peekToken()
+			if token.Type != TokenIdentifier || next.Type != TokenDelimiter || (next.Lexeme != "," && next.Lexeme != ":") {
+				break
+			}
+		}
+	}
+
+	for {
+		stmt, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		block.Body = append(block.Body, stmt)
+		token := p.currentToken()
+		if token.Type == TokenDelimiter && (token.Lexeme == ":" || token.Lexeme == ";") {
+			p.nextToken()
+			continue
+		} else if token.Type == TokenDelimiter && token.Lexeme == "]" {
+			block.End = token
+			p.nextToken()
+			break
+		} else {
+			err := p.errorAt(token, "Ожидалось ':' или ']' в составном операторе, получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+			err = p.suggestWord(err, token)
+			return nil, withLabel(err, block.Tok, tr("составной оператор начат здесь"))
+		}
+	}
+
+	return block, nil
+}
+
+// Реакция на скрытие внешнего имени переменной блока: "warning" (предупреждение)
+// или "error" (ошибка); задаётся флагом -shadow
+var shadowMode = "warning"
+
+// Проверка, что переменные блока не скрывают имена из объемлющих областей
+func (p *Syntax) checkShadowing(names []Token) error {
+	for _, name := range names {
+		prev := p.scope.parent.lookup(name.Lexeme)
+		if prev == nil {
+			continue
+		}
+		if shadowMode == "error" {
+			err := p.errorAt(name, "Имя '%s' скрывает внешнее объявление", name.Lexeme)
+			return withLabel(err, prev.Tok, tr("внешнее объявление"))
+		}
+		withLabel(p.warnAt(name, "Имя '%s' скрывает внешнее объявление", name.Lexeme), prev.Tok, tr("внешнее объявление"))
+	}
+	return nil
+}
+
+// Парсинг операции присваивания
+func (p *Syntax) parseAssignment() (*AssignStmt, error) {
+	// <переменная> as <выражение>
+	token := p.currentToken()
+	if token.Type != TokenIdentifier {
+		return nil, p.errorAt(token, "Ожидался идентификатор в присваивании, получено %s '%s'",
+			TokenTypeToString(token.Type), token.Lexeme)
+	}
+	target, _, err := p.parseVariable(p.guessType())
+	if err != nil {
+		return nil, err
+	}
+	err = p.checkLoopVar(target)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.matchToken(TokenKeyword, "as")
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AssignStmt{Target: target, Value: value}, nil
+}
+
+// Парсинг переменной: <идентификатор> { [ <выражение> ] | . <идентификатор> };
+// возвращает переменную и её тип. '[' сразу после переменной всегда означает
+// индекс: составной оператор не может начинаться в этом месте, так как
+// операторы разделяются ';' или ':'. Точка после переменной — выбор поля записи
+// (точка в конце программы стоит после 'end', а не после переменной).
+func (p *Syntax) parseVariable(typ string) (Expr, *TypeSpec, error) {
+	token := p.currentToken()
+	err := p.checkVariable(token, typ)
+	if err != nil {
+		return nil, nil, err
+	}
+	sym := p.lookup(token.Lexeme)
+	p.nextToken()
+
+	var x Expr = &Ident{Tok: token}
+	t := sym.Type
+	for {
+		open := p.currentToken()
+		if open.Type == TokenDelimiter && open.Lexeme == "." {
+			x, t, err = p.parseField(x, t, sym)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if open.Type != TokenDelimiter || open.Lexeme != "[" {
+			return x, t, nil
+		}
+		if !t.IsArray() {
+			err := p.errorAt(open, "'%s' не является массивом", exprString(x))
+			return nil, nil, withLabel(err, sym.Tok, tr("объявлено здесь"))
+		}
+		p.nextToken()
+		index, err := p.parseExpression()
+		if err != nil {
+			return nil, nil, err
+		}
+		end := p.currentToken()
+		err = p.matchToken(TokenDelimiter, "]")
+		if err != nil {
+			return nil, nil, withLabel(err, open, tr("открывающая квадратная скобка"))
+		}
+		err = p.checkIndex(x, index, t)
+		if err != nil {
+			return nil, nil, err
+		}
+		x = &IndexExpr{X: x, Index: index, End: end}
+		t = t.Underlying().Elem
+	}
+}
+
+// Парсинг выбора поля: . <идентификатор>; sym — переменная, с которой начинается запись x
+func (p *Syntax) parseField(x Expr, t *TypeSpec, sym *symbol) (Expr, *TypeSpec, error) {
+	dot := p.currentToken()
+	if !t.IsRecord() {
+		err := p.errorAt(dot, "'%s' не является записью", exprString(x))
+		return nil, nil, withLabel(err, sym.Tok, tr("объявлено здесь"))
+	}
+	p.nextToken()
+
+	name := p.currentToken()
+	if name.Type != TokenIdentifier {
+		return nil, nil, p.errorAt(name, "Ожидалось имя поля, получено %s '%s'",
+			TokenTypeToString(name.Type), name.Lexeme)
+	}
+	i, ft := t.Field(name.Lexeme)
+	if i < 0 {
+		err := p.errorAt(name, "Запись '%s' не содержит поля '%s'", exprString(x), name.Lexeme)
+		err = withNameSuggestion(err, name, t.FieldNames())
+		return nil, nil, withLabel(err, t.Underlying().Tok, tr("запись объявлена здесь"))
+	}
+	p.nextToken()
+	return &FieldExpr{X: x, Name: name}, ft, nil
+}
+
+// Проверка константного индекса на выход за границы массива
+func (p *Syntax) checkIndex(x, index Expr, t *TypeSpec) error {
+	i, ok := constInt(index)
+	if !ok {
+		return nil
+	}
+	low, high, _ := arrayBounds(t)
+	if i < low || i > high {
+		err := p.errorAt(index.Pos(), "Индекс %d вне границ массива '%s' [%d..%d]", i, exprString(x), low, high)
+		return withLabel(err, t.Tok, tr("границы объявлены здесь"))
+	}
+	return nil
+}
+
+// Проверка, что имя обозначает видимую переменную; typ — тип для исправления,
+// объявляющего необъявленную переменную
+func (p *Syntax) checkVariable(token Token, typ string) error {
+	sym := p.lookup(token.Lexeme)
+	if sym == nil {
+		err := p.errorAt(token, "Необъявленная переменная '%s'", token.Lexeme)
+		err = p.declareFix(err, token, typ)
+		return withNameSuggestion(err, token, p.declaredNames())
+	}
+	if sym.Kind == symConst {
+		err := p.errorAt(token, "Нельзя изменить константу '%s'", token.Lexeme)
+		return withLabel(err, sym.Tok, tr("константа объявлена здесь"))
+	}
+	if sym.Kind != symVar {
+		err := p.errorAt(token, "'%s' не является переменной", token.Lexeme)
+		return withLabel(err, sym.Tok, tr("объявлено здесь"))
+	}
+	return nil
+}
+
+// Проверка, что изменяемая переменная не является переменной объемлющего цикла for
+func (p *Syntax) checkLoopVar(target Expr) error {
+	id, ok := target.(*Ident)
+	if !ok {
+		return nil
+	}
+	sym := p.lookup(id.Tok.Lexeme)
+	for _, lv := range p.loopVars {
+		if lv.sym == sym {
+			err := p.errorAt(id.Tok, "Нельзя изменить переменную цикла '%s' в теле цикла", id.Tok.Lexeme)
+			return withLabel(err, lv.tok, tr("переменная цикла"))
+		}
+	}
+	return nil
+}
+
+// Парсинг вызова подпрограммы: <имя> ( [ <выражение> { , <выражение> } ] )
+func (p *Syntax) parseCall() (*CallExpr, error) {
+	call := &CallExpr{Name: p.currentToken()}
+	sym := p.lookup(call.Name.Lexeme)
+	b := builtins[call.Name.Lexeme]
+	if sym == nil && b == nil {
+		err := p.errorAt(call.Name, "Необъявленная подпрограмма '%s'", call.Name.Lexeme)
+		return nil, withNameSuggestion(err, call.Name, append(p.scope.names(symSubprogram), builtinNames()...))
+	}
+	if sym != nil && sym.Kind != symSubprogram {
+		err := p.errorAt(call.Name, "'%s' не является процедурой или функцией", call.Name.Lexeme)
+		return nil, withLabel(err, sym.Tok, tr("объявлено здесь"))
+	}
+	p.nextToken()
+
+	open := p.currentToken()
+	err := p.matchToken(TokenDelimiter, "(")
+	if err != nil {
+		return nil, err
+	}
+	token := p.currentToken()
+	if !(token.Type == TokenDelimiter && token.Lexeme == ")") {
+		for {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			token = p.currentToken()
+			if token.Type == TokenDelimiter && token.Lexeme == "," {
+				p.nextToken()
+				continue
+			} else if token.Type == TokenDelimiter && token.Lexeme == ")" {
+				break
+			} else {
+				err := p.errorAt(token, "Ожидалось ',' или ')', получено %s '%s'",
+					TokenTypeToString(token.Type), token.Lexeme)
+				err = p.suggestWord(err, token)
+				return nil, withLabel(err, open, tr("открывающая скобка"))
+			}
+		}
+	}
+	call.End = token
+	p.nextToken()
+
+	if sym == nil {
+		// Встроенная функция
+		if len(call.Args) != b.Params {
+			return nil, p.errorAt(call.Name, "Подпрограмма '%s' ожидает аргументов: %d, передано: %d",
+				call.Name.Lexeme, b.Params, len(call.Args))
+		}
+		return call, nil
+	}
+	if want := len(sym.Sub.ParamNames()); len(call.Args) != want {
+		err := p.errorAt(call.Name, "Подпрограмма '%s' ожидает аргументов: %d, передано: %d",
+			call.Name.Lexeme, want, len(call.Args))
+		return nil, withLabel(err, sym.Sub.Name, tr("объявлена здесь"))
+	}
+	call.Sub = sym.Sub
+	return call, nil
+}
+
+// Парсинг оператора return
+func (p *Syntax) parseReturn() (Stmt, error) {
+	// return [ <выражение> ]
+	stmt := &ReturnStmt{Tok: p.currentToken()}
+	if p.sub == nil {
+		return nil, p.errorAt(stmt.Tok, "Оператор return допустим только в процедуре или функции")
+	}
+	p.nextToken()
+	if p.sub.IsFunction() {
+		next := p.currentToken()
+		missing := (next.Type == TokenDelimiter && (next.Lexeme == ";" || next.Lexeme == "]")) ||
+			(next.Type == TokenKeyword && next.Lexeme == "end")
+		value, err := p.parseExpression()
+		if err != nil {
+			if missing {
+				// Выражение пропущено: ошибка относится к объявлению функции
+				err = withLabel(err, p.sub.Name, tr("функция должна вернуть значение"))
+			}
+			return nil, err
+		}
+		stmt.Value = value
+	}
+	return stmt, nil
+}
+
+// Парсинг конструкции if
+func (p *Syntax) parseIf() (Stmt, error) {
+	// if <выражение> then <оператор> [ else <оператор> ]
+	stmt := &IfStmt{Tok: p.currentToken()}
+	err := p.matchToken(TokenKeyword, "if")
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.Cond, err = p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.matchToken(TokenKeyword, "then")
+	if err != nil {
+		return nil, withLabel(err, stmt.Tok, tr("к этому оператору if"))
+	}
+
+	stmt.Then, err = p.parseOperation()
+	if err != nil {
+		return nil, err
+	}
+
+	token := p.currentToken()
+	if token.Type == TokenKeyword && token.Lexeme == "else" {
+		p.nextToken()
+		stmt.Else, err = p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, nil
+}
+
+// Переменная цикла for, которую нельзя изменять в теле цикла
+type loopVar struct {
+	sym *symbol
+	tok Token // имя переменной в заголовке цикла
+}
+
+// Парсинг цикла for
+func (p *Syntax) parseFor() (Stmt, error) {
+	// for <присваивание> (to | downto) <выражение> [ step <выражение> ] do <оператор>
+	stmt := &ForStmt{Tok: p.currentToken()}
+	err := p.matchToken(TokenKeyword, "for")
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.Init, err = p.parseAssignment()
+	if err != nil {
+		return nil, err
+	}
+	target, ok := stmt.Init.Target.(*Ident)
+	if !ok {
+		return nil, p.errorAt(stmt.Init.Target.Pos(), "Переменной цикла for должна быть простая переменная, получено '%s'",
+			exprString(stmt.Init.Target))
+	}
+	sym := p.lookup(target.Tok.Lexeme)
+	if t := sym.Type.Underlying(); t.Elem != nil || t.Fields != nil || t.Tok.Lexeme != "int" {
+		err := p.errorAt(target.Tok, "Переменная цикла for '%s' должна быть целой, а не %s",
+			target.Tok.Lexeme, typeString(sym.Type))
+		return nil, withLabel(err, sym.Tok, tr("объявлено здесь"))
 	}
 
-	// parse declarations
-	for {
-		err = p.parseDeclaration()
+	stmt.Dir = p.currentToken()
+	if stmt.Dir.Type == TokenKeyword && stmt.Dir.Lexeme == "downto" {
+		p.nextToken()
+	} else {
+		err = p.matchToken(TokenKeyword, "to")
 		if err != nil {
-			return err
-		}
-		// Проверяем, есть ли еще объявления
-		token := p.currentToken()
-		if token.Type == TokenKeyword && token.Lexeme == "begin" {
-			break
+			return nil, withLabel(err, stmt.Tok, tr("к этому циклу for"))
 		}
 	}
 
-	// begin
-	err = p.matchToken(TokenKeyword, "begin")
+	stmt.To, err = p.parseExpression()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// parse operations
-	err = p.parseOperations()
-	if err != nil {
-		return err
+	token := p.currentToken()
+	if token.Type == TokenKeyword && token.Lexeme == "step" {
+		p.nextToken()
+		stmt.Step, err = p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		// Константный шаг проверяется при разборе, остальные — при выполнении
+		step, err := evalConst(stmt.Step)
+		if err == nil && (step.Kind != KindInt || step.bigInt().Sign() <= 0) {
+			return nil, p.errorAt(stmt.Step.Pos(), "Шаг цикла for должен быть положительным целым, получено %s",
+				exprString(stmt.Step))
+		}
 	}
 
-	// end
-	err = p.matchToken(TokenKeyword, "end")
+	err = p.matchToken(TokenKeyword, "do")
 	if err != nil {
-		return err
+		return nil, withLabel(err, stmt.Tok, tr("к этому циклу for"))
 	}
 
-	// '.'
-	err = p.matchToken(TokenDelimiter, ".")
+	p.loopVars = append(p.loopVars, loopVar{sym: sym, tok: target.Tok})
+	defer func() { p.loopVars = p.loopVars[:len(p.loopVars)-1] }()
+	stmt.Body, err = p.parseLoopBody()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return stmt, nil
 }
 
-// Парсинг объявления переменных
-func (p *Syntax) parseDeclaration() error {
-	for {
-		token := p.currentToken()
-		if token.Type != TokenIdentifier {
-			return fmt.Errorf("Ожидался идентификатор, получено %s '%s' на строке %d столбце %d",
-				TokenTypeToString(token.Type), token.Lexeme,
-				token.LineNum, token.ColNum)
-		}
-		if p.vars == nil {
-			p.vars = make(map[string]bool)
-		}
-		if p.vars[token.Lexeme] {
-			return fmt.Errorf("Переменная '%s' уже объявлена на строке %d столбце %d",
-				token.Lexeme, token.LineNum, token.ColNum)
-		}
-		p.vars[token.Lexeme] = true
-		p.nextToken()
+// Парсинг цикла while
+func (p *Syntax) parseWhile() (Stmt, error) {
+	// while <выражение> do <оператор>
+	stmt := &WhileStmt{Tok: p.currentToken()}
+	err := p.matchToken(TokenKeyword, "while")
+	if err != nil {
+		return nil, err
+	}
 
-		token = p.currentToken()
-		if token.Type == TokenDelimiter && token.Lexeme == "," {
-			p.nextToken()
-			continue
-		} else if token.Type == TokenDelimiter && token.Lexeme == ":" {
-			p.nextToken()
-			break
-		} else {
-			return fmt.Errorf("Ожидалось ',' или ':', получено %s '%s' на строке %d столбце %d",
-				TokenTypeToString(token.Type), token.Lexeme,
-				token.LineNum, token.ColNum)
-		}
+	stmt.Cond, err = p.parseExpression()
+	if err != nil {
+		return nil, err
 	}
 
-	// Тип
-	token := p.currentToken()
-	if token.Type != TokenKeyword || (token.Lexeme != "int" && token.Lexeme != "float" && token.Lexeme != "bool") {
-		return fmt.Errorf("Ожидался тип 'int', 'float' или 'bool', получено %s '%s' на строке %d столбце %d",
-			TokenTypeToString(token.Type), token.Lexeme,
-			token.LineNum, token.ColNum)
+	err = p.matchToken(TokenKeyword, "do")
+	if err != nil {
+		return nil, withLabel(err, stmt.Tok, tr("к этому циклу while"))
 	}
-	p.nextToken()
 
-	// ';'
-	token = p.currentToken()
-	if token.Type != TokenDelimiter || token.Lexeme != ";" {
-		return fmt.Errorf("Ожидалось ';', получено %s '%s' на строке %d столбце %d",
-			TokenTypeToString(token.Type), token.Lexeme,
-			token.LineNum, token.ColNum)
+	stmt.Body, err = p.parseLoopBody()
+	if err != nil {
+		return nil, err
 	}
-	p.nextToken()
 
-	return nil
+	return stmt, nil
 }
 
-// Парсинг списка операций
-func (p *Syntax) parseOperations() error {
+// Тело цикла: внутри него допустимы break и continue
+func (p *Syntax) parseLoopBody() (Stmt, error) {
+	p.loops++
+	defer func() { p.loops-- }()
+	return p.parseOperation()
+}
+
+// Парсинг цикла repeat
+func (p *Syntax) parseRepeat() (Stmt, error) {
+	// repeat <оператор> { ; <оператор> } until <выражение>
+	stmt := &RepeatStmt{Tok: p.currentToken()}
+	err := p.matchToken(TokenKeyword, "repeat")
+	if err != nil {
+		return nil, err
+	}
+
+	p.loops++
+	defer func() { p.loops-- }()
 	for {
 		token := p.currentToken()
-		if token.Type == TokenKeyword && token.Lexeme == "end" {
-			// Если мы встретили 'end', выходим из цикла операций
+		if token.Type == TokenKeyword && token.Lexeme == "until" {
 			break
 		}
-
-		err := p.parseOperation()
+		body, err := p.parseOperation()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		stmt.Body = append(stmt.Body, body)
 
 		token = p.currentToken()
 		if token.Type == TokenDelimiter && token.Lexeme == ";" {
 			p.nextToken()
-			continue
-		} else if token.Type == TokenKeyword && token.Lexeme == "end" {
-			// Если после операции нет ';', но есть 'end', завершаем парсинг операций
-			break
-		} else {
-			return fmt.Errorf("Ожидалось ';' или 'end', получено %s '%s' на строке %d столбце %d",
-				TokenTypeToString(token.Type), token.Lexeme,
-				token.LineNum, token.ColNum)
+		} else if !(token.Type == TokenKeyword && token.Lexeme == "until") {
+			err := p.errorAt(token, "Ожидалось ';' или 'until', получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+			err = p.suggestWord(err, token, "until")
+			if !hasFixes(err) {
+				err = p.insertFix(err, ";")
+			}
+			return nil, withLabel(err, stmt.Tok, tr("к этому циклу repeat"))
 		}
 	}
-	return nil
+
+	stmt.Until = p.currentToken()
+	p.nextToken()
+	stmt.Cond, err = p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
 }
 
-// Парсинг одной операции
-func (p *Syntax) parseOperation() error {
+// Парсинг операторов break и continue
+func (p *Syntax) parseLoopJump() (Stmt, error) {
 	token := p.currentToken()
-	if token.Type == TokenKeyword {
-		switch token.Lexeme {
-		case "if":
-			return p.parseIf()
-		case "for":
-			return p.parseFor()
-		case "while":
-			return p.parseWhile()
-		case "read":
-			return p.Syntaxead()
-		case "write":
-			return p.parseWrite()
-		case "begin":
-			return p.parseCompositeOperation()
-		default:
-			return fmt.Errorf("Неизвестный оператор '%s' на строке %d столбце %d",
-				token.Lexeme, token.LineNum, token.ColNum)
-		}
-	} else if token.Type == TokenIdentifier {
-		// Присваивание
-		return p.parseAssignment()
-	} else if token.Type == TokenDelimiter && token.Lexeme == "[" {
-		// Составной оператор
-		return p.parseCompositeOperation()
-	} else {
-		return fmt.Errorf("Ожидался оператор, получено %s '%s' на строке %d столбце %d",
-			TokenTypeToString(token.Type), token.Lexeme,
-			token.LineNum, token.ColNum)
+	if p.loops == 0 {
+		return nil, p.errorAt(token, "Оператор %s допустим только внутри цикла", token.Lexeme)
+	}
+	p.nextToken()
+	if token.Lexeme == "break" {
+		return &BreakStmt{Tok: token}, nil
 	}
+	return &ContinueStmt{Tok: token}, nil
 }
 
-// Парсинг составного оператора
-func (p *Syntax) parseCompositeOperation() error {
-	// '[' <оператор> { (: | ';') <оператор> } ']'
-	err := p.matchToken(TokenDelimiter, "[")
+// Уже встреченные значения меток оператора case
+type caseValue struct {
+	low, high int64
+	label     *CaseLabel
+}
+
+// Парсинг оператора выбора
+func (p *Syntax) parseCase() (Stmt, error) {
+	// case <выражение> of <ветвь> { ; <ветвь> } [ ; ] [ else <оператор> [ ; ] ] end
+	stmt := &CaseStmt{Tok: p.currentToken()}
+	err := p.matchToken(TokenKeyword, "case")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	stmt.Selector, err = p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.matchToken(TokenKeyword, "of")
+	if err != nil {
+		return nil, withLabel(err, stmt.Tok, tr("к этому оператору case"))
 	}
 
+	var seen []caseValue
+	kind := ValueKind(-1)
 	for {
-		err = p.parseOperation()
+		branch, err := p.parseCaseBranch(&seen, &kind)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		stmt.Branches = append(stmt.Branches, branch)
+
 		token := p.currentToken()
-		if token.Type == TokenDelimiter && (token.Lexeme == ":" || token.Lexeme == ";") {
-			p.nextToken()
-			continue
-		} else if token.Type == TokenDelimiter && token.Lexeme == "]" {
+		if token.Type == TokenDelimiter && token.Lexeme == ";" {
 			p.nextToken()
+			token = p.currentToken()
+		} else if !(token.Type == TokenKeyword && (token.Lexeme == "else" || token.Lexeme == "end")) {
+			err := p.errorAt(token, "Ожидалось ';', 'else' или 'end' в операторе case, получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+			return nil, withLabel(p.insertFix(err, ";"), stmt.Tok, tr("оператор case начат здесь"))
+		}
+		if token.Type == TokenKeyword && (token.Lexeme == "else" || token.Lexeme == "end") {
 			break
-		} else {
-			return fmt.Errorf("Ожидалось ':' или ']' в составном операторе, получено %s '%s' на строке %d столбце %d",
-				TokenTypeToString(token.Type), token.Lexeme,
-				token.LineNum, token.ColNum)
 		}
 	}
 
-	return nil
-}
-
-// Парсинг операции присваивания
-func (p *Syntax) parseAssignment() error {
-	// <идентификатор> as <выражение>
 	token := p.currentToken()
-	if token.Type != TokenIdentifier {
-		return fmt.Errorf("Ожидался идентификатор в присваивании, получено %s '%s' на строке %d столбце %d",
-			TokenTypeToString(token.Type), token.Lexeme,
-			token.LineNum, token.ColNum)
-	}
-	if !p.vars[token.Lexeme] {
-		return fmt.Errorf("Необъявленная переменная '%s' на строке %d столбце %d",
-			token.Lexeme, token.LineNum, token.ColNum)
+	if token.Type == TokenKeyword && token.Lexeme == "else" {
+		p.nextToken()
+		stmt.Else, err = p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		token = p.currentToken()
+		if token.Type == TokenDelimiter && token.Lexeme == ";" {
+			p.nextToken()
+		}
 	}
-	p.nextToken()
 
-	err := p.matchToken(TokenKeyword, "as")
+	stmt.End = p.currentToken()
+	err = p.matchToken(TokenKeyword, "end")
 	if err != nil {
-		return err
+		return nil, withLabel(err, stmt.Tok, tr("оператор case начат здесь"))
 	}
 
-	err = p.parseExpression()
-	if err != nil {
-		return err
+	// Выбор по логическому выражению без else должен охватывать оба значения
+	if kind == KindBool && stmt.Else == nil {
+		for _, v := range []int64{0, 1} {
+			covered := false
+			for _, s := range seen {
+				covered = covered || (s.low <= v && v <= s.high)
+			}
+			if !covered {
+				p.warnAt(stmt.Tok, "Оператор case по логическому выражению не охватывает значение %s", Value{Kind: KindBool, Bool: v == 1})
+			}
+		}
 	}
 
-	return nil
+	return stmt, nil
 }
 
-// Парсинг конструкции if
-func (p *Syntax) parseIf() error {
-	// if <выражение> then <оператор> [ else <оператор> ]
-	err := p.matchToken(TokenKeyword, "if")
-	if err != nil {
-		return err
+// Парсинг ветви оператора выбора: <метка> { , <метка> } : <оператор>
+func (p *Syntax) parseCaseBranch(seen *[]caseValue, kind *ValueKind) (*CaseBranch, error) {
+	branch := &CaseBranch{}
+	for {
+		label, err := p.parseCaseLabel(seen, kind)
+		if err != nil {
+			return nil, err
+		}
+		branch.Labels = append(branch.Labels, label)
+		token := p.currentToken()
+		if token.Type == TokenDelimiter && token.Lexeme == "," {
+			p.nextToken()
+			continue
+		}
+		break
 	}
 
-	err = p.parseExpression()
+	err := p.matchToken(TokenDelimiter, ":")
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	err = p.matchToken(TokenKeyword, "then")
+	branch.Body, err = p.parseOperation()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return branch, nil
+}
 
-	err = p.parseOperation()
+// Парсинг метки: <константа> [ .. <константа> ]; проверяются тип метки,
+// пустые диапазоны и повторяющиеся значения
+func (p *Syntax) parseCaseLabel(seen *[]caseValue, kind *ValueKind) (*CaseLabel, error) {
+	label := &CaseLabel{}
+	low, lowKind, err := p.parseCaseConst()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
+	label.Low = low
 	token := p.currentToken()
-	if token.Type == TokenKeyword && token.Lexeme == "else" {
+	if token.Type == TokenDelimiter && token.Lexeme == ".." {
 		p.nextToken()
-		err = p.parseOperation()
+		high, highKind, err := p.parseCaseConst()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		if highKind != lowKind {
+			return nil, p.errorAt(high.Pos(), "Метка case '%s' имеет тип %s, а первая метка — тип %s",
+				exprString(high), ordinalTypeName(highKind), ordinalTypeName(lowKind))
+		}
+		label.High = high
 	}
 
-	return nil
-}
-
-// Парсинг цикла for
-func (p *Syntax) parseFor() error {
-	// for <присваивание> to <выражение> do <оператор>
-	err := p.matchToken(TokenKeyword, "for")
-	if err != nil {
-		return err
-	}
-
-	err = p.parseAssignment()
-	if err != nil {
-		return err
-	}
-
-	err = p.matchToken(TokenKeyword, "to")
+	lowNum, highNum, labelKind, err := label.Bounds()
 	if err != nil {
-		return err
+		return nil, p.checkConst(label.Low)
 	}
-
-	err = p.parseExpression()
-	if err != nil {
-		return err
+	if *kind == ValueKind(-1) {
+		*kind = labelKind
+	} else if labelKind != *kind {
+		err := p.errorAt(label.Low.Pos(), "Метка case '%s' имеет тип %s, а первая метка — тип %s",
+			caseLabelString(label), ordinalTypeName(labelKind), ordinalTypeName(*kind))
+		return nil, withLabel(err, (*seen)[0].label.Low.Pos(), tr("первая метка"))
 	}
-
-	err = p.matchToken(TokenKeyword, "do")
-	if err != nil {
-		return err
+	if lowNum > highNum {
+		return nil, p.errorAt(label.Low.Pos(), "Пустой диапазон меток %s..%s", exprString(label.Low), exprString(label.High))
 	}
-
-	err = p.parseOperation()
-	if err != nil {
-		return err
+	for _, s := range *seen {
+		if lowNum <= s.high && s.low <= highNum {
+			value := max(lowNum, s.low)
+			text := strconv.FormatInt(value, 10)
+			if labelKind == KindBool {
+				text = strconv.FormatBool(value == 1)
+			}
+			err := p.errorAt(label.Low.Pos(), "Значение %s уже встречается среди меток case", text)
+			return nil, withLabel(err, s.label.Low.Pos(), tr("первое вхождение"))
+		}
 	}
-
-	return nil
+	*seen = append(*seen, caseValue{low: lowNum, high: highNum, label: label})
+	return label, nil
 }
 
-// Парсинг цикла while
-func (p *Syntax) parseWhile() error {
-	// while <выражение> do <оператор>
-	err := p.matchToken(TokenKeyword, "while")
+// Константа в метке case: целое или логическое константное выражение
+func (p *Syntax) parseCaseConst() (Expr, ValueKind, error) {
+	e, err := p.parseExpression()
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-
-	err = p.parseExpression()
+	err = p.checkConst(e)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-
-	err = p.matchToken(TokenKeyword, "do")
-	if err != nil {
-		return err
+	v, _ := evalConst(e)
+	if v.Big != nil {
+		return nil, 0, p.errorAt(e.Pos(), "Метка case %s не помещается в 64 бита", v)
 	}
-
-	err = p.parseOperation()
-	if err != nil {
-		return err
+	if _, ok := ordinal(v); !ok {
+		return nil, 0, p.errorAt(e.Pos(), "Метка case должна быть целой или логической константой, получено %s", exprString(e))
 	}
-
-	return nil
+	return e, v.Kind, nil
 }
 
 // Парсинг оператора read
-func (p *Syntax) Syntaxead() error {
-	// read ( <идентификатор> { , <идентификатор> } )
+func (p *Syntax) Syntaxead() (Stmt, error) {
+	// read ( <переменная> { , <переменная> } )
+	stmt := &ReadStmt{Tok: p.currentToken()}
 	err := p.matchToken(TokenKeyword, "read")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = p.matchToken(TokenDelimiter, "(")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for {
 		token := p.currentToken()
 		if token.Type != TokenIdentifier {
-			return fmt.Errorf("Ожидался идентификатор в read, получено %s '%s' на строке %d столбце %d",
-				TokenTypeToString(token.Type), token.Lexeme,
-				token.LineNum, token.ColNum)
+			return nil, p.errorAt(token, "Ожидался идентификатор в read, получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
 		}
-		if !p.vars[token.Lexeme] {
-			return fmt.Errorf("Необъявленная переменная '%s' на строке %d столбце %d",
-				token.Lexeme, token.LineNum, token.ColNum)
+		target, typ, err := p.parseVariable("int")
+		if err != nil {
+			return nil, err
 		}
-		p.nextToken()
+		err = p.checkLoopVar(target)
+		if err != nil {
+			return nil, err
+		}
+		if typ.IsArray() || typ.IsRecord() {
+			return nil, p.errorAt(token, "Переменную '%s' типа %s нельзя прочитать", exprString(target), typeString(typ))
+		}
+		stmt.Targets = append(stmt.Targets, target)
 
 		token = p.currentToken()
 		if token.Type == TokenDelimiter && token.Lexeme == "," {
@@ -397,33 +1761,35 @@ func (p *Syntax) Syntaxead() error {
 			p.nextToken()
 			break
 		} else {
-			return fmt.Errorf("Ожидалось ',' или ')', получено %s '%s' на строке %d столбце %d",
-				TokenTypeToString(token.Type), token.Lexeme,
-				token.LineNum, token.ColNum)
+			err := p.errorAt(token, "Ожидалось ',' или ')', получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+			return nil, p.suggestWord(err, token)
 		}
 	}
 
-	return nil
+	return stmt, nil
 }
 
 // Парсинг оператора write
-func (p *Syntax) parseWrite() error {
+func (p *Syntax) parseWrite() (Stmt, error) {
 	// write ( <выражение> { , <выражение> } )
+	stmt := &WriteStmt{Tok: p.currentToken()}
 	err := p.matchToken(TokenKeyword, "write")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = p.matchToken(TokenDelimiter, "(")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for {
-		err = p.parseExpression()
+		arg, err := p.parseExpression()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		stmt.Args = append(stmt.Args, arg)
 
 		token := p.currentToken()
 		if token.Type == TokenDelimiter && token.Lexeme == "," {
@@ -433,35 +1799,36 @@ func (p *Syntax) parseWrite() error {
 			p.nextToken()
 			break
 		} else {
-			return fmt.Errorf("Ожидалось ',' или ')', получено %s '%s' на строке %d столбце %d",
-				TokenTypeToString(token.Type), token.Lexeme,
-				token.LineNum, token.ColNum)
+			err := p.errorAt(token, "Ожидалось ',' или ')', получено %s '%s'",
+				TokenTypeToString(token.Type), token.Lexeme)
+			return nil, p.suggestWord(err, token)
 		}
 	}
 
-	return nil
+	return stmt, nil
 }
 
 // Парсинг выражения
-func (p *Syntax) parseExpression() error {
+func (p *Syntax) parseExpression() (Expr, error) {
 	// Реализуем разбор выражений с учетом приоритетов операций
 
-	err := p.parseOperand()
+	left, err := p.parseOperand()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	token := p.currentToken()
 	for token.Type == TokenOperator && isRelationOperator(token.Lexeme) {
 		p.nextToken()
-		err = p.parseOperand()
+		right, err := p.parseOperand()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		left = &BinaryExpr{Op: token, Left: left, Right: right}
 		token = p.currentToken()
 	}
 
-	return nil
+	return left, nil
 }
 
 // Проверка, является ли оператор оператором отношения
@@ -474,23 +1841,24 @@ func isRelationOperator(op string) bool {
 }
 
 // Парсинг операнда
-func (p *Syntax) parseOperand() error {
-	err := p.parseTerm()
+func (p *Syntax) parseOperand() (Expr, error) {
+	left, err := p.parseTerm()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	token := p.currentToken()
 	for token.Type == TokenOperator && isAdditionOperator(token.Lexeme) {
 		p.nextToken()
-		err = p.parseTerm()
+		right, err := p.parseTerm()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		left = &BinaryExpr{Op: token, Left: left, Right: right}
 		token = p.currentToken()
 	}
 
-	return nil
+	return left, nil
 }
 
 // Проверка, является ли оператор оператором сложения
@@ -503,23 +1871,24 @@ func isAdditionOperator(op string) bool {
 }
 
 // Парсинг терма
-func (p *Syntax) parseTerm() error {
-	err := p.parseFactor()
+func (p *Syntax) parseTerm() (Expr, error) {
+	left, err := p.parseFactor()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	token := p.currentToken()
 	for token.Type == TokenOperator && isMultiplicationOperator(token.Lexeme) {
 		p.nextToken()
-		err = p.parseFactor()
+		right, err := p.parseFactor()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		left = &BinaryExpr{Op: token, Left: left, Right: right}
 		token = p.currentToken()
 	}
 
-	return nil
+	return left, nil
 }
 
 // Проверка, является ли оператор оператором умножения
@@ -532,39 +1901,76 @@ func isMultiplicationOperator(op string) bool {
 }
 
 // Парсинг фактора
-func (p *Syntax) parseFactor() error {
+func (p *Syntax) parseFactor() (Expr, error) {
 	token := p.currentToken()
 
 	if token.Type == TokenOperator && token.Lexeme == "~" {
 		p.nextToken()
-		return p.parseFactor()
+		x, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: token, X: x}, nil
 	} else if token.Type == TokenDelimiter && token.Lexeme == "(" {
 		p.nextToken()
-		err := p.parseExpression()
+		x, err := p.parseExpression()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		err = p.matchToken(TokenDelimiter, ")")
 		if err != nil {
-			return err
+			return nil, withLabel(err, token, tr("открывающая скобка"))
 		}
-		return nil
+		return x, nil
 	} else if token.Type == TokenIdentifier {
-		if !p.vars[token.Lexeme] {
-			return fmt.Errorf("Необъявленная переменная '%s' на строке %d столбце %d",
-				token.Lexeme, token.LineNum, token.ColNum)
+		next := p.peekToken()
+		if next.Type == TokenDelimiter && next.Lexeme == "(" {
+			// Вызов функции
+			call, err := p.parseCall()
+			if err != nil {
+				return nil, err
+			}
+			if sym := p.lookup(token.Lexeme); sym != nil && !sym.Sub.IsFunction() {
+				err := p.errorAt(token, "Процедура '%s' не возвращает значения", token.Lexeme)
+				return nil, withLabel(err, sym.Sub.Name, tr("объявлена здесь"))
+			}
+			return call, nil
 		}
-		p.nextToken()
-		return nil
+		sym := p.lookup(token.Lexeme)
+		if sym == nil {
+			err := p.errorAt(token, "Необъявленная переменная '%s'", token.Lexeme)
+			if looksLikeHex(token.Lexeme) {
+				// FFh — шестнадцатеричное число без ведущей цифры, которое читается как имя
+				err.(*Diagnostic).Help = sprintf("шестнадцатеричное число должно начинаться с цифры: '0%s'", token.Lexeme)
+				return nil, replaceFix(err, token, "0"+token.Lexeme)
+			}
+			err = p.declareFix(err, token, "int")
+			return nil, withNameSuggestion(err, token, append(p.declaredNames(), append(p.scope.names(symConst), "true", "false")...))
+		}
+		if sym.Kind == symConst {
+			p.nextToken()
+			return &Ident{Tok: token, Const: sym.Const}, nil
+		}
+		x, _, err := p.parseVariable("int")
+		if err != nil {
+			return nil, err
+		}
+		return x, nil
 	} else if token.Type == TokenNumber {
+		// Значение вне допустимого диапазона — ошибка разбора, а не выполнения
+		if (token.Value.Big != nil && !bigInts) || math.IsInf(token.Value.Float, 0) {
+			return nil, p.numberError(token)
+		}
 		p.nextToken()
-		return nil
+		return &NumberLit{Tok: token}, nil
 	} else if token.Type == TokenKeyword && (token.Lexeme == "true" || token.Lexeme == "false") {
 		p.nextToken()
-		return nil
+		return &BoolLit{Tok: token, Value: token.Lexeme == "true"}, nil
+	} else if token.Type == TokenString || token.Type == TokenChar {
+		p.nextToken()
+		return &StringLit{Tok: token}, nil
 	} else {
-		return fmt.Errorf("Ожидался фактор, получено %s '%s' на строке %d столбце %d",
-			TokenTypeToString(token.Type), token.Lexeme,
-			token.LineNum, token.ColNum)
+		return nil, p.errorAt(token, "Ожидался фактор, получено %s '%s'",
+			TokenTypeToString(token.Type), token.Lexeme)
 	}
 }
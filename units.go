@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Загрузка модулей. Модуль <имя> хранится в файле <имя>.tfu и ищется сначала
+// в каталоге подключающего файла, затем в каталогах пути поиска (флаг -path).
+// Разобранные модули кешируются по абсолютному пути: повторное подключение
+// (в другом модуле или в другой программе того же запуска) не разбирает файл заново,
+// пока не изменились ни он, ни включённые в него файлы ({$include}), ни модули,
+// от которых он зависит. Кеш хранится только в памяти: раздельной компиляции нет,
+// и каждый запуск разбирает модули заново.
+
+// Расширение файла модуля
+const unitExt = ".tfu"
+
+// Путь поиска модулей: каталоги, разделённые os.PathListSeparator; задаётся флагом -path
+var unitPath = ""
+
+type unitLoader struct {
+	cache   map[string]*cachedUnit
+	loading []*Unit // модули, разбираемые в данный момент (для обнаружения циклов)
+}
+
+// Запись кеша: исходный текст хранится и для модуля с ошибкой, чтобы её можно было показать
+type cachedUnit struct {
+	modTime  time.Time
+	size     int64
+	src      []byte
+	includes []fileStamp // файлы, включённые в текст модуля
+	unit     *Unit       // nil, если разбор завершился ошибкой
+}
+
+// Время изменения и размер файла на момент разбора
+type fileStamp struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+func stampFiles(paths []string) []fileStamp {
+	var stamps []fileStamp
+	for _, path := range paths {
+		stamp := fileStamp{path: path}
+		if info, err := os.Stat(path); err == nil {
+			stamp.modTime, stamp.size = info.ModTime(), info.Size()
+		}
+		stamps = append(stamps, stamp)
+	}
+	return stamps
+}
+
+// Ни один из файлов не изменился после разбора
+func unchanged(stamps []fileStamp) bool {
+	for _, s := range stamps {
+		info, err := os.Stat(s.path)
+		if err != nil || !info.ModTime().Equal(s.modTime) || info.Size() != s.size {
+			return false
+		}
+	}
+	return true
+}
+
+var loader = &unitLoader{cache: make(map[string]*cachedUnit)}
+
+// Текст является модулем: начинается с ключевого слова unit
+func isUnitSource(tokens []Token) bool {
+	return len(tokens) > 0 && tokens[0].Type == TokenKeyword && tokens[0].Lexeme == "unit"
+}
+
+// Каталоги поиска модулей для файла from
+func unitDirs(from string) []string {
+	dir := "."
+	if from != "" && from != "-" {
+		dir = filepath.Dir(from)
+	}
+	dirs := []string{dir}
+	for _, d := range filepath.SplitList(unitPath) {
+		if d != "" {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// Загрузка модуля, подключённого в файле from; name — имя модуля в списке uses
+func (l *unitLoader) load(from string, name Token) (*Unit, error) {
+	dirs := unitDirs(from)
+	path := ""
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, name.Lexeme+unitExt)
+		info, err := os.Stat(candidate)
+		if err == nil && !info.IsDir() {
+			path = candidate
+			break
+		}
+	}
+	if path == "" {
+		d := newDiagnostic(StageSyntax, name, "Модуль '%s' не найден", name.Lexeme)
+		d.Help = sprintf("файл %s ищется в каталогах: %s", name.Lexeme+unitExt, strings.Join(dirs, ", "))
+		return nil, d
+	}
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		path = abs
+	}
+
+	unit, err := l.loadPath(path, name)
+	if d := asDiagnostic(err); d != nil && d.Tok.File != name.File {
+		// Ошибка в тексте модуля: указывается и место подключения
+		return nil, withLabel(d, name, tr("модуль подключён здесь"))
+	}
+	return unit, err
+}
+
+// Загрузка модуля из файла path с использованием кеша
+func (l *unitLoader) loadPath(path string, name Token) (*Unit, error) {
+	for i, u := range l.loading {
+		if u.Path == path {
+			var chain []string
+			for _, u := range l.loading[i:] {
+				chain = append(chain, u.Name.Lexeme)
+			}
+			chain = append(chain, name.Lexeme)
+			return nil, newDiagnostic(StageSyntax, name, "Циклическая зависимость модулей: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, newDiagnostic(StageSyntax, name, "Ошибка чтения модуля '%s': %v", name.Lexeme, err)
+	}
+	cached := l.cache[path]
+	if cached != nil && cached.unit != nil && cached.modTime.Equal(info.ModTime()) &&
+		cached.size == info.Size() && unchanged(cached.includes) && l.fresh(cached.unit) {
+		return cached.unit, nil
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newDiagnostic(StageSyntax, name, "Ошибка чтения модуля '%s': %v", name.Lexeme, err)
+	}
+	cached = &cachedUnit{modTime: info.ModTime(), size: info.Size(), src: src}
+	l.cache[path] = cached
+
+	tokens, includes, err := lexFileIncludes(path, bytes.NewReader(src))
+	cached.includes = stampFiles(includes)
+	if err != nil {
+		if d := asDiagnostic(err); d != nil {
+			// Ошибка в тексте модуля или во включённом в него файле
+			if d.Tok.File == "" {
+				d.Tok.File = path
+			}
+			for i := range d.Labels {
+				if d.Labels[i].Tok.File == "" {
+					d.Labels[i].Tok.File = path
+				}
+			}
+		}
+		return nil, err
+	}
+	for i := range tokens {
+		// Токены включённых файлов ({$include}) уже помечены своим файлом
+		if tokens[i].File == "" {
+			tokens[i].File = path
+		}
+	}
+
+	// Модуль попадает в стек загрузки до разбора списка uses
+	l.loading = append(l.loading, &Unit{Name: name, Path: path})
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+	parser := Syntax{tokens: tokens, pos: 0, file: path}
+	unit, err := parser.ParseUnit()
+	if err != nil {
+		return nil, err
+	}
+	unit.Path = path
+	cached.unit = unit
+	return unit, nil
+}
+
+// Модули, от которых зависит закешированный модуль, не изменились
+func (l *unitLoader) fresh(unit *Unit) bool {
+	for _, dep := range unit.Deps {
+		u, err := l.loadPath(dep.Path, dep.Name)
+		if err != nil || u != dep {
+			return false
+		}
+	}
+	return true
+}
+
+// Исходный текст файла, к которому относится токен: для токенов модулей — текст модуля
+func sourceOf(tok Token, name string, src []byte) (string, []byte) {
+	if tok.File == "" {
+		return name, src
+	}
+	if cached := loader.cache[tok.File]; cached != nil {
+		return tok.File, cached.src
+	}
+	data, err := os.ReadFile(tok.File)
+	if err != nil {
+		return tok.File, nil
+	}
+	return tok.File, data
+}
+
+// Путь к файлу модуля для сообщений: относительно текущего каталога, если файл внутри него
+func displayPath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Модуль из кеша разбирается заново, если изменился включённый в него файл
+func TestUnitCacheTracksIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "mathx.tfu", `unit mathx;
+interface
+{$include 'limits.txt'}
+implementation
+end.
+`)
+	limits := writeFile(t, dir, "limits.txt", "const limit = 10d;\n")
+	path := writeFile(t, dir, "prog.txt", "program uses mathx; var r : int;\nbegin\n    write(limit)\nend.\n")
+	run := func() string {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := LexFile(path, strings.NewReader(string(src)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parser := Syntax{tokens: tokens, pos: 0, file: path}
+		prog, err := parser.ParseProgram()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		if err := NewInterpreter(strings.NewReader(""), &out).Run(prog); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	if got := run(); got != "10\n" {
+		t.Fatalf("вывод %q, ожидалось %q", got, "10\n")
+	}
+	if err := os.WriteFile(limits, []byte("const limit = 200d;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(); got != "200\n" {
+		t.Errorf("после изменения %s вывод %q, ожидалось %q", filepath.Base(limits), got, "200\n")
+	}
+}